@@ -0,0 +1,447 @@
+// Copyright 2022 Hewlett Packard Enterprise Development LP
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"fmt"
+
+	kdv1beta1 "github.com/bluek8s/kubedirector/pkg/apis/kubedirector/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+)
+
+// ConvertTo converts this KubeDirectorApp to the hub (v1beta1) version.
+func (in *KubeDirectorApp) ConvertTo(
+	dstRaw conversion.Hub,
+) error {
+
+	dst, ok := dstRaw.(*kdv1beta1.KubeDirectorApp)
+	if !ok {
+		return fmt.Errorf("unexpected conversion hub type %T", dstRaw)
+	}
+	src := in.DeepCopy()
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Spec = appSpecToV1beta1(&src.Spec)
+	return nil
+}
+
+// ConvertFrom converts from the hub (v1beta1) version to this version.
+func (in *KubeDirectorApp) ConvertFrom(
+	srcRaw conversion.Hub,
+) error {
+
+	src, ok := srcRaw.(*kdv1beta1.KubeDirectorApp)
+	if !ok {
+		return fmt.Errorf("unexpected conversion hub type %T", srcRaw)
+	}
+	src = src.DeepCopy()
+	in.ObjectMeta = src.ObjectMeta
+	in.Spec = appSpecFromV1beta1(&src.Spec)
+	return nil
+}
+
+// appSpecToV1beta1 converts a v1 app spec to v1beta1.
+func appSpecToV1beta1(
+	in *KubeDirectorAppSpec,
+) kdv1beta1.KubeDirectorAppSpec {
+
+	out := kdv1beta1.KubeDirectorAppSpec{
+		Label:                 kdv1beta1.Label(in.Label),
+		DistroID:              in.DistroID,
+		Version:               in.Version,
+		SchemaVersion:         in.SchemaVersion,
+		DefaultImageRepoTag:   in.DefaultImageRepoTag,
+		DefaultSetupPackage:   setupPackageToV1beta1(in.DefaultSetupPackage),
+		DefaultPersistDirs:    in.DefaultPersistDirs,
+		DefaultEventList:      in.DefaultEventList,
+		Capabilities:          in.Capabilities,
+		SystemdRequired:       in.SystemdRequired,
+		LogoURL:               in.LogoURL,
+		DefaultMaxLogSizeDump: in.DefaultMaxLogSizeDump,
+		UpgradableFrom:        in.UpgradableFrom,
+	}
+	if in.Services != nil {
+		out.Services = make([]kdv1beta1.Service, len(in.Services))
+		for i, service := range in.Services {
+			out.Services[i] = kdv1beta1.Service{
+				ID:              service.ID,
+				Label:           kdv1beta1.Label(service.Label),
+				Endpoint:        serviceEndpointToV1beta1(&service.Endpoint),
+				ExportedService: service.ExportedService,
+			}
+		}
+	}
+	if in.NodeRoles != nil {
+		out.NodeRoles = make([]kdv1beta1.NodeRole, len(in.NodeRoles))
+		for i := range in.NodeRoles {
+			out.NodeRoles[i] = nodeRoleToV1beta1(&in.NodeRoles[i])
+		}
+	}
+	out.Config = kdv1beta1.NodeGroupConfig{
+		RoleServices:   roleServicesToV1beta1(in.Config.RoleServices),
+		SelectedRoles:  in.Config.SelectedRoles,
+		ConfigMetadata: in.Config.ConfigMetadata,
+	}
+	if in.Config.ConfigChoices != nil {
+		out.Config.ConfigChoices = make([]kdv1beta1.ConfigChoice, len(in.Config.ConfigChoices))
+		for i, choice := range in.Config.ConfigChoices {
+			out.Config.ConfigChoices[i] = kdv1beta1.ConfigChoice{
+				ID:      choice.ID,
+				Default: choice.Default,
+			}
+			if choice.Selections != nil {
+				selections := make([]kdv1beta1.ConfigSelection, len(choice.Selections))
+				for j, selection := range choice.Selections {
+					selections[j] = kdv1beta1.ConfigSelection{
+						ID:             selection.ID,
+						RoleServices:   roleServicesToV1beta1(selection.RoleServices),
+						SelectedRoles:  selection.SelectedRoles,
+						ConfigMetadata: selection.ConfigMetadata,
+					}
+				}
+				out.Config.ConfigChoices[i].Selections = selections
+			}
+		}
+	}
+	return out
+}
+
+// appSpecFromV1beta1 converts a v1beta1 app spec to v1.
+func appSpecFromV1beta1(
+	in *kdv1beta1.KubeDirectorAppSpec,
+) KubeDirectorAppSpec {
+
+	out := KubeDirectorAppSpec{
+		Label:                 Label(in.Label),
+		DistroID:              in.DistroID,
+		Version:               in.Version,
+		SchemaVersion:         in.SchemaVersion,
+		DefaultImageRepoTag:   in.DefaultImageRepoTag,
+		DefaultSetupPackage:   setupPackageFromV1beta1(&in.DefaultSetupPackage),
+		DefaultPersistDirs:    in.DefaultPersistDirs,
+		DefaultEventList:      in.DefaultEventList,
+		Capabilities:          in.Capabilities,
+		SystemdRequired:       in.SystemdRequired,
+		LogoURL:               in.LogoURL,
+		DefaultMaxLogSizeDump: in.DefaultMaxLogSizeDump,
+		UpgradableFrom:        in.UpgradableFrom,
+	}
+	if in.Services != nil {
+		out.Services = make([]Service, len(in.Services))
+		for i, service := range in.Services {
+			out.Services[i] = Service{
+				ID:              service.ID,
+				Label:           Label(service.Label),
+				Endpoint:        serviceEndpointFromV1beta1(&service.Endpoint),
+				ExportedService: service.ExportedService,
+			}
+		}
+	}
+	if in.NodeRoles != nil {
+		out.NodeRoles = make([]NodeRole, len(in.NodeRoles))
+		for i := range in.NodeRoles {
+			out.NodeRoles[i] = nodeRoleFromV1beta1(&in.NodeRoles[i])
+		}
+	}
+	out.Config = NodeGroupConfig{
+		RoleServices:   roleServicesFromV1beta1(in.Config.RoleServices),
+		SelectedRoles:  in.Config.SelectedRoles,
+		ConfigMetadata: in.Config.ConfigMetadata,
+	}
+	if in.Config.ConfigChoices != nil {
+		out.Config.ConfigChoices = make([]ConfigChoice, len(in.Config.ConfigChoices))
+		for i, choice := range in.Config.ConfigChoices {
+			out.Config.ConfigChoices[i] = ConfigChoice{
+				ID:      choice.ID,
+				Default: choice.Default,
+			}
+			if choice.Selections != nil {
+				selections := make([]ConfigSelection, len(choice.Selections))
+				for j, selection := range choice.Selections {
+					selections[j] = ConfigSelection{
+						ID:             selection.ID,
+						RoleServices:   roleServicesFromV1beta1(selection.RoleServices),
+						SelectedRoles:  selection.SelectedRoles,
+						ConfigMetadata: selection.ConfigMetadata,
+					}
+				}
+				out.Config.ConfigChoices[i].Selections = selections
+			}
+		}
+	}
+	return out
+}
+
+// nodeRoleToV1beta1 converts a v1 app role to v1beta1.
+func nodeRoleToV1beta1(
+	in *NodeRole,
+) kdv1beta1.NodeRole {
+
+	out := kdv1beta1.NodeRole{
+		ID:             in.ID,
+		Cardinality:    in.Cardinality,
+		ImageRepoTag:   in.ImageRepoTag,
+		SetupPackage:   setupPackageToV1beta1(in.SetupPackage),
+		PersistDirs:    in.PersistDirs,
+		EventList:      in.EventList,
+		MinResources:   in.MinResources,
+		MaxLogSizeDump: in.MaxLogSizeDump,
+		DependsOn:      in.DependsOn,
+		AllowedImages:  in.AllowedImages,
+	}
+	if in.MinStorage != nil {
+		minStorage := kdv1beta1.MinStorage(*in.MinStorage)
+		out.MinStorage = &minStorage
+	}
+	if in.ContainerSpec != nil {
+		containerSpec := kdv1beta1.ContainerSpec(*in.ContainerSpec)
+		out.ContainerSpec = &containerSpec
+	}
+	if in.PersistVolumes != nil {
+		out.PersistVolumes = make([]kdv1beta1.PersistVolume, len(in.PersistVolumes))
+		for i, persistVolume := range in.PersistVolumes {
+			out.PersistVolumes[i] = kdv1beta1.PersistVolume(persistVolume)
+		}
+	}
+	if in.Sidecars != nil {
+		out.Sidecars = make([]kdv1beta1.Sidecar, len(in.Sidecars))
+		for i, sidecar := range in.Sidecars {
+			out.Sidecars[i] = kdv1beta1.Sidecar(sidecar)
+		}
+	}
+	out.SecurityContext = securityContextToV1beta1(in.SecurityContext)
+	if in.DisruptionBudget != nil {
+		disruptionBudget := kdv1beta1.DisruptionBudget(*in.DisruptionBudget)
+		out.DisruptionBudget = &disruptionBudget
+	}
+	return out
+}
+
+// nodeRoleFromV1beta1 converts a v1beta1 app role to v1.
+func nodeRoleFromV1beta1(
+	in *kdv1beta1.NodeRole,
+) NodeRole {
+
+	out := NodeRole{
+		ID:             in.ID,
+		Cardinality:    in.Cardinality,
+		ImageRepoTag:   in.ImageRepoTag,
+		SetupPackage:   setupPackageFromV1beta1(&in.SetupPackage),
+		PersistDirs:    in.PersistDirs,
+		EventList:      in.EventList,
+		MinResources:   in.MinResources,
+		MaxLogSizeDump: in.MaxLogSizeDump,
+		DependsOn:      in.DependsOn,
+		AllowedImages:  in.AllowedImages,
+	}
+	if in.MinStorage != nil {
+		minStorage := MinStorage(*in.MinStorage)
+		out.MinStorage = &minStorage
+	}
+	if in.ContainerSpec != nil {
+		containerSpec := ContainerSpec(*in.ContainerSpec)
+		out.ContainerSpec = &containerSpec
+	}
+	if in.PersistVolumes != nil {
+		out.PersistVolumes = make([]PersistVolume, len(in.PersistVolumes))
+		for i, persistVolume := range in.PersistVolumes {
+			out.PersistVolumes[i] = PersistVolume(persistVolume)
+		}
+	}
+	if in.Sidecars != nil {
+		out.Sidecars = make([]Sidecar, len(in.Sidecars))
+		for i, sidecar := range in.Sidecars {
+			out.Sidecars[i] = Sidecar(sidecar)
+		}
+	}
+	out.SecurityContext = securityContextFromV1beta1(in.SecurityContext)
+	if in.DisruptionBudget != nil {
+		disruptionBudget := DisruptionBudget(*in.DisruptionBudget)
+		out.DisruptionBudget = &disruptionBudget
+	}
+	return out
+}
+
+// serviceEndpointToV1beta1 converts a v1 service endpoint to v1beta1.
+func serviceEndpointToV1beta1(
+	in *ServiceEndpoint,
+) kdv1beta1.ServiceEndpoint {
+
+	out := kdv1beta1.ServiceEndpoint{
+		URLScheme:      in.URLScheme,
+		Port:           in.Port,
+		Path:           in.Path,
+		IsDashboard:    in.IsDashboard,
+		HasAuthToken:   in.HasAuthToken,
+		Exposure:       in.Exposure,
+		HasRoleService: in.HasRoleService,
+	}
+	if in.ReadinessProbe != nil {
+		readinessProbe := kdv1beta1.ServiceProbe(*in.ReadinessProbe)
+		out.ReadinessProbe = &readinessProbe
+	}
+	if in.LivenessProbe != nil {
+		livenessProbe := kdv1beta1.ServiceProbe(*in.LivenessProbe)
+		out.LivenessProbe = &livenessProbe
+	}
+	if in.Metrics != nil {
+		metrics := kdv1beta1.ServiceMetrics(*in.Metrics)
+		out.Metrics = &metrics
+	}
+	return out
+}
+
+// serviceEndpointFromV1beta1 converts a v1beta1 service endpoint to v1.
+func serviceEndpointFromV1beta1(
+	in *kdv1beta1.ServiceEndpoint,
+) ServiceEndpoint {
+
+	out := ServiceEndpoint{
+		URLScheme:      in.URLScheme,
+		Port:           in.Port,
+		Path:           in.Path,
+		IsDashboard:    in.IsDashboard,
+		HasAuthToken:   in.HasAuthToken,
+		Exposure:       in.Exposure,
+		HasRoleService: in.HasRoleService,
+	}
+	if in.ReadinessProbe != nil {
+		readinessProbe := ServiceProbe(*in.ReadinessProbe)
+		out.ReadinessProbe = &readinessProbe
+	}
+	if in.LivenessProbe != nil {
+		livenessProbe := ServiceProbe(*in.LivenessProbe)
+		out.LivenessProbe = &livenessProbe
+	}
+	if in.Metrics != nil {
+		metrics := ServiceMetrics(*in.Metrics)
+		out.Metrics = &metrics
+	}
+	return out
+}
+
+// securityContextToV1beta1 converts a v1 role security context to v1beta1.
+func securityContextToV1beta1(
+	in *SecurityContext,
+) *kdv1beta1.SecurityContext {
+
+	if in == nil {
+		return nil
+	}
+	out := &kdv1beta1.SecurityContext{
+		RunAsUser:              in.RunAsUser,
+		RunAsGroup:             in.RunAsGroup,
+		RunAsNonRoot:           in.RunAsNonRoot,
+		FSGroup:                in.FSGroup,
+		ReadOnlyRootFilesystem: in.ReadOnlyRootFilesystem,
+		DropCapabilities:       in.DropCapabilities,
+	}
+	if in.SeccompProfile != nil {
+		seccompProfile := kdv1beta1.SeccompProfile(*in.SeccompProfile)
+		out.SeccompProfile = &seccompProfile
+	}
+	return out
+}
+
+// securityContextFromV1beta1 converts a v1beta1 role security context to v1.
+func securityContextFromV1beta1(
+	in *kdv1beta1.SecurityContext,
+) *SecurityContext {
+
+	if in == nil {
+		return nil
+	}
+	out := &SecurityContext{
+		RunAsUser:              in.RunAsUser,
+		RunAsGroup:             in.RunAsGroup,
+		RunAsNonRoot:           in.RunAsNonRoot,
+		FSGroup:                in.FSGroup,
+		ReadOnlyRootFilesystem: in.ReadOnlyRootFilesystem,
+		DropCapabilities:       in.DropCapabilities,
+	}
+	if in.SeccompProfile != nil {
+		seccompProfile := SeccompProfile(*in.SeccompProfile)
+		out.SeccompProfile = &seccompProfile
+	}
+	return out
+}
+
+// roleServicesToV1beta1 converts a list of v1 role services to v1beta1.
+func roleServicesToV1beta1(
+	in []RoleService,
+) []kdv1beta1.RoleService {
+
+	if in == nil {
+		return nil
+	}
+	out := make([]kdv1beta1.RoleService, len(in))
+	for i, roleService := range in {
+		out[i] = kdv1beta1.RoleService(roleService)
+	}
+	return out
+}
+
+// roleServicesFromV1beta1 converts a list of v1beta1 role services to v1.
+func roleServicesFromV1beta1(
+	in []kdv1beta1.RoleService,
+) []RoleService {
+
+	if in == nil {
+		return nil
+	}
+	out := make([]RoleService, len(in))
+	for i, roleService := range in {
+		out[i] = RoleService(roleService)
+	}
+	return out
+}
+
+// setupPackageToV1beta1 converts a v1 setup package to the v1beta1 form,
+// which distinguishes "unset" from "explicitly set null" rather than using
+// a nil pointer and the Disabled flag.
+func setupPackageToV1beta1(
+	in *SetupPackage,
+) kdv1beta1.SetupPackage {
+
+	if in == nil {
+		return kdv1beta1.SetupPackage{}
+	}
+	if in.Disabled {
+		return kdv1beta1.SetupPackage{IsSet: true, IsNull: true}
+	}
+	return kdv1beta1.SetupPackage{
+		IsSet: true,
+		Info: kdv1beta1.SetupPackageInfo{
+			PackageURL:        in.PackageURL,
+			UseNewSetupLayout: in.UseNewSetupLayout,
+		},
+	}
+}
+
+// setupPackageFromV1beta1 converts a v1beta1 setup package to v1.
+func setupPackageFromV1beta1(
+	in *kdv1beta1.SetupPackage,
+) *SetupPackage {
+
+	if !in.IsSet {
+		return nil
+	}
+	if in.IsNull {
+		return &SetupPackage{Disabled: true}
+	}
+	return &SetupPackage{
+		PackageURL:        in.Info.PackageURL,
+		UseNewSetupLayout: in.Info.UseNewSetupLayout,
+	}
+}
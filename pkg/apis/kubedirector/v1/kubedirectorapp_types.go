@@ -0,0 +1,299 @@
+// Copyright 2022 Hewlett Packard Enterprise Development LP
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	// ServiceProbeHTTP is an HTTP(S) GET of the service endpoint path.
+	ServiceProbeHTTP string = "http"
+	// ServiceProbeTCP is a TCP connection to the service endpoint port.
+	ServiceProbeTCP string = "tcp"
+	// ServiceProbeExec runs a command in the app container.
+	ServiceProbeExec string = "exec"
+
+	// ServiceExposureExternal puts a service endpoint on each member's
+	// service of the role's serviceType.
+	ServiceExposureExternal string = "external"
+	// ServiceExposureInternal puts a service endpoint only on a ClusterIP
+	// service for each member.
+	ServiceExposureInternal string = "internal"
+	// ServiceExposureDashboardOnly is like ServiceExposureInternal, for a
+	// dashboard that users reach through a proxy or ingress rather than
+	// through the member's external service.
+	ServiceExposureDashboardOnly string = "dashboardOnly"
+
+	// SeccompProfileRuntimeDefault uses the container runtime's default
+	// seccomp profile.
+	SeccompProfileRuntimeDefault string = "RuntimeDefault"
+	// SeccompProfileLocalhost uses a seccomp profile file on the node.
+	SeccompProfileLocalhost string = "Localhost"
+	// SeccompProfileUnconfined runs without seccomp filtering.
+	SeccompProfileUnconfined string = "Unconfined"
+)
+
+// KubeDirectorAppSpec defines the desired state of KubeDirectorApp.
+type KubeDirectorAppSpec struct {
+	Label                 Label               `json:"label"`
+	DistroID              string              `json:"distroID"`
+	Version               string              `json:"version"`
+	SchemaVersion         int                 `json:"configSchemaVersion"`
+	DefaultImageRepoTag   *string             `json:"defaultImageRepoTag,omitempty"`
+	DefaultSetupPackage   *SetupPackage       `json:"defaultConfigPackage,omitempty"`
+	Services              []Service           `json:"services,omitempty"`
+	NodeRoles             []NodeRole          `json:"roles"`
+	Config                NodeGroupConfig     `json:"config"`
+	DefaultPersistDirs    *[]string           `json:"defaultPersistDirs,omitempty"`
+	DefaultEventList      *[]string           `json:"defaultEventList,omitempty"`
+	Capabilities          []corev1.Capability `json:"capabilities,omitempty"`
+	SystemdRequired       bool                `json:"systemdRequired,omitempty"`
+	LogoURL               string              `json:"logoURL,omitempty"`
+	DefaultMaxLogSizeDump *int32              `json:"defaultMaxLogSizeDump,omitempty"`
+	UpgradableFrom        []string            `json:"upgradableFrom,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// KubeDirectorApp is the Schema for the kubedirectorapps API.
+// +kubebuilder:resource:path=kubedirectorapps,scope=Namespaced
+type KubeDirectorApp struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              KubeDirectorAppSpec `json:"spec,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// KubeDirectorAppList contains a list of KubeDirectorApp.
+type KubeDirectorAppList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []KubeDirectorApp `json:"items"`
+}
+
+// Label is a short name and long description for the app definition.
+type Label struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// SetupPackage describes the app setup package to be used. A top-level
+// package can be specified, and/or a role-specific package that will override
+// any top-level package. An omitted role package inherits the top-level
+// package. If Disabled is set, no setup package is used (and for a role, any
+// top-level package is not inherited); PackageURL and UseNewSetupLayout are
+// ignored in that case.
+type SetupPackage struct {
+	Disabled          bool   `json:"disabled,omitempty"`
+	PackageURL        string `json:"packageURL,omitempty"`
+	UseNewSetupLayout bool   `json:"useNewSetupLayout,omitempty"`
+}
+
+// Service describes a network endpoint that should be exposed for external
+// access, and/or identified for other use by API clients or consumers
+// internal to the virtual cluster (e.g. app setup packages).
+type Service struct {
+	ID              string          `json:"id"`
+	Label           Label           `json:"label,omitempty"`
+	Endpoint        ServiceEndpoint `json:"endpoint,omitempty"`
+	ExportedService string          `json:"exported_service,omitempty"`
+}
+
+// ServiceEndpoint describes the service network address and protocol, and
+// whether it should be displayed through a web browser. Exposure is one of
+// the ServiceExposure* constants; external if unset. If HasRoleService is
+// set, the endpoint is also exposed through a single service for the whole
+// role that balances across its members. It may also declare probes that
+// K8s will use to check the health of the app containers in roles that
+// provide the service. If Metrics is set, the endpoint serves Prometheus
+// metrics.
+type ServiceEndpoint struct {
+	URLScheme      string          `json:"urlScheme,omitempty"`
+	Port           *int32          `json:"port"`
+	Path           string          `json:"path,omitempty"`
+	IsDashboard    bool            `json:"isDashboard,omitempty"`
+	HasAuthToken   bool            `json:"hasAuthToken,omitempty"`
+	Exposure       string          `json:"exposure,omitempty"`
+	HasRoleService bool            `json:"hasRoleService,omitempty"`
+	ReadinessProbe *ServiceProbe   `json:"readinessProbe,omitempty"`
+	LivenessProbe  *ServiceProbe   `json:"livenessProbe,omitempty"`
+	Metrics        *ServiceMetrics `json:"metrics,omitempty"`
+}
+
+// ServiceMetrics describes how Prometheus should scrape the metrics served
+// on a service endpoint's port. Path is "/metrics" if unset, and Scheme
+// (http or https) is "http" if unset. Interval is the scrape interval, as a
+// Prometheus duration such as "30s"; the Prometheus default if unset.
+type ServiceMetrics struct {
+	Path     string `json:"path,omitempty"`
+	Scheme   string `json:"scheme,omitempty"`
+	Interval string `json:"interval,omitempty"`
+}
+
+// ServiceProbe describes a check of a service endpoint. Type is one of the
+// ServiceProbe* constants. An http probe requests the endpoint's path (using
+// https if that is the endpoint's urlScheme), a tcp probe connects to the
+// endpoint's port, and an exec probe runs Command in the app container. The
+// other properties are as for a K8s container probe.
+type ServiceProbe struct {
+	Type                string   `json:"type"`
+	Command             []string `json:"command,omitempty"`
+	InitialDelaySeconds *int32   `json:"initialDelaySeconds,omitempty"`
+	PeriodSeconds       *int32   `json:"periodSeconds,omitempty"`
+	TimeoutSeconds      *int32   `json:"timeoutSeconds,omitempty"`
+	FailureThreshold    *int32   `json:"failureThreshold,omitempty"`
+}
+
+// NodeRole describes a subset of virtual cluster members that will provide
+// the same services. At deployment time all role members will receive
+// identical resource assignments. A role's members will not be configured
+// until all roles listed in its dependsOn property are fully configured.
+// AllowedImages lists the patterns (in path.Match syntax) that a kdcluster's
+// image override for the role must match. SecurityContext declares the
+// user, group, and other security settings that the role's containers need.
+// If DisruptionBudget is set, KubeDirector maintains a PodDisruptionBudget
+// for the role's members. PersistVolumes groups persisted directories that
+// a kdcluster may place on their own volumes, separate from the main one.
+type NodeRole struct {
+	ID               string               `json:"id"`
+	Cardinality      string               `json:"cardinality"`
+	ImageRepoTag     *string              `json:"imageRepoTag,omitempty"`
+	SetupPackage     *SetupPackage        `json:"configPackage,omitempty"`
+	PersistDirs      *[]string            `json:"persistDirs,omitempty"`
+	PersistVolumes   []PersistVolume      `json:"persistVolumes,omitempty"`
+	EventList        *[]string            `json:"eventList,omitempty"`
+	MinResources     *corev1.ResourceList `json:"minResources,omitempty"`
+	MinStorage       *MinStorage          `json:"minStorage,omitempty"`
+	ContainerSpec    *ContainerSpec       `json:"containerSpec,omitempty"`
+	MaxLogSizeDump   *int32               `json:"maxLogSizeDump,omitempty"`
+	DependsOn        []string             `json:"dependsOn,omitempty"`
+	AllowedImages    []string             `json:"allowedImages,omitempty"`
+	Sidecars         []Sidecar            `json:"sidecars,omitempty"`
+	SecurityContext  *SecurityContext     `json:"securityContext,omitempty"`
+	DisruptionBudget *DisruptionBudget    `json:"disruptionBudget,omitempty"`
+}
+
+// MinStorage describes the minimum persistent storage requirement, if any.
+type MinStorage struct {
+	Size                   string `json:"size"`
+	EphemeralModeSupported bool   `json:"ephemeralModeSupported"`
+}
+
+// PersistVolume names a volume that can hold some of a role's persisted
+// directories. If a kdcluster does not provide a volume of this name for
+// the role, the directories are persisted on the role's main volume.
+type PersistVolume struct {
+	Name        string   `json:"name"`
+	PersistDirs []string `json:"persistDirs"`
+}
+
+// DisruptionBudget describes the PodDisruptionBudget for the members of a
+// role. Exactly one of MinAvailable, MaxUnavailable, or Quorum should be
+// set. MinAvailable and MaxUnavailable are as for a K8s PodDisruptionBudget.
+// Quorum keeps a majority of the role's desired members available.
+type DisruptionBudget struct {
+	MinAvailable   *intstr.IntOrString `json:"minAvailable,omitempty"`
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+	Quorum         bool                `json:"quorum,omitempty"`
+}
+
+// SecurityContext describes the security settings for the containers of a
+// role. These are applied to the pod (runAsUser, runAsGroup, runAsNonRoot,
+// fsGroup, seccompProfile) or to each container (readOnlyRootFilesystem,
+// dropCapabilities) as for the K8s properties of the same names.
+type SecurityContext struct {
+	RunAsUser              *int64              `json:"runAsUser,omitempty"`
+	RunAsGroup             *int64              `json:"runAsGroup,omitempty"`
+	RunAsNonRoot           *bool               `json:"runAsNonRoot,omitempty"`
+	FSGroup                *int64              `json:"fsGroup,omitempty"`
+	SeccompProfile         *SeccompProfile     `json:"seccompProfile,omitempty"`
+	ReadOnlyRootFilesystem *bool               `json:"readOnlyRootFilesystem,omitempty"`
+	DropCapabilities       []corev1.Capability `json:"dropCapabilities,omitempty"`
+}
+
+// SeccompProfile selects the seccomp profile for a role's pods. Type is one
+// of the SeccompProfile* constants; LocalhostProfile is the profile path on
+// the node, and is only used with the Localhost type.
+type SeccompProfile struct {
+	Type             string  `json:"type"`
+	LocalhostProfile *string `json:"localhostProfile,omitempty"`
+}
+
+// ContainerSpec defines container runtime settings for virtual cluster members.
+type ContainerSpec struct {
+	Stdin bool `json:"stdin,omitempty"`
+	Tty   bool `json:"tty,omitempty"`
+}
+
+// Sidecar describes an additional container that runs alongside the app
+// container in each role member. KubeDirector does not run the app setup
+// package or any lifecycle events in sidecars. PersistDirs lists persisted
+// directories of the role that will also be mounted (at the same path) into
+// the sidecar, if the role has persistent storage.
+type Sidecar struct {
+	Name        string                      `json:"name"`
+	Image       string                      `json:"image"`
+	Command     []string                    `json:"command,omitempty"`
+	Args        []string                    `json:"args,omitempty"`
+	Ports       []corev1.ContainerPort      `json:"ports,omitempty"`
+	Resources   corev1.ResourceRequirements `json:"resources,omitempty"`
+	Env         []corev1.EnvVar             `json:"env,omitempty"`
+	PersistDirs []string                    `json:"persistDirs,omitempty"`
+}
+
+// NodeGroupConfig identifies a set of roles, and the services on those roles.
+// The top-level config indicates which roles and services will always be
+// active; these form nodegroup "1". Config choices introduce other
+// conditional configs, each of which forms an additional nodegroup.
+type NodeGroupConfig struct {
+	RoleServices   []RoleService     `json:"roleServices"`
+	SelectedRoles  []string          `json:"selectedRoles"`
+	ConfigMetadata map[string]string `json:"configMeta,omitempty"`
+	ConfigChoices  []ConfigChoice    `json:"configChoices,omitempty"`
+}
+
+// ConfigChoice is a named choice among alternative sets of roles, services,
+// and config metadata. A virtual cluster picks one selection for each
+// choice; Default is the ID of the selection used if the cluster does not
+// pick one.
+type ConfigChoice struct {
+	ID         string            `json:"id"`
+	Default    string            `json:"default"`
+	Selections []ConfigSelection `json:"selections"`
+}
+
+// ConfigSelection is one alternative for a config choice. The roles it
+// selects must not be selected by the top-level config or by any other
+// choice, and its roleServices can only refer to those roles.
+type ConfigSelection struct {
+	ID             string            `json:"id"`
+	RoleServices   []RoleService     `json:"roleServices,omitempty"`
+	SelectedRoles  []string          `json:"selectedRoles,omitempty"`
+	ConfigMetadata map[string]string `json:"configMeta,omitempty"`
+}
+
+// RoleService associates a service with a role.
+type RoleService struct {
+	ServiceIDs []string `json:"serviceIDs"`
+	RoleID     string   `json:"roleID"`
+}
+
+func init() {
+	SchemeBuilder.Register(&KubeDirectorApp{}, &KubeDirectorAppList{})
+}
@@ -0,0 +1,104 @@
+// Copyright 2022 Hewlett Packard Enterprise Development LP
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"fmt"
+
+	kdv1beta1 "github.com/bluek8s/kubedirector/pkg/apis/kubedirector/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+)
+
+// ConvertTo converts this KubeDirectorBackup to the hub (v1beta1) version.
+func (in *KubeDirectorBackup) ConvertTo(
+	dstRaw conversion.Hub,
+) error {
+
+	dst, ok := dstRaw.(*kdv1beta1.KubeDirectorBackup)
+	if !ok {
+		return fmt.Errorf("unexpected conversion hub type %T", dstRaw)
+	}
+	src := in.DeepCopy()
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Spec = kdv1beta1.KubeDirectorBackupSpec(src.Spec)
+	dst.Status = nil
+	if src.Status != nil {
+		dst.Status = &kdv1beta1.KubeDirectorBackupStatus{
+			State:          src.Status.State,
+			Message:        src.Status.Message,
+			StartTime:      src.Status.StartTime,
+			CompletionTime: src.Status.CompletionTime,
+			Snapshots:      backupSnapshotsToV1beta1(src.Status.Snapshots),
+		}
+	}
+	return nil
+}
+
+// ConvertFrom converts from the hub (v1beta1) version to this version.
+func (in *KubeDirectorBackup) ConvertFrom(
+	srcRaw conversion.Hub,
+) error {
+
+	src, ok := srcRaw.(*kdv1beta1.KubeDirectorBackup)
+	if !ok {
+		return fmt.Errorf("unexpected conversion hub type %T", srcRaw)
+	}
+	src = src.DeepCopy()
+	in.ObjectMeta = src.ObjectMeta
+	in.Spec = KubeDirectorBackupSpec(src.Spec)
+	in.Status = nil
+	if src.Status != nil {
+		in.Status = &KubeDirectorBackupStatus{
+			State:          src.Status.State,
+			Message:        src.Status.Message,
+			StartTime:      src.Status.StartTime,
+			CompletionTime: src.Status.CompletionTime,
+			Snapshots:      backupSnapshotsFromV1beta1(src.Status.Snapshots),
+		}
+	}
+	return nil
+}
+
+// backupSnapshotsToV1beta1 converts a list of v1 backup snapshots to
+// v1beta1.
+func backupSnapshotsToV1beta1(
+	in []BackupSnapshot,
+) []kdv1beta1.BackupSnapshot {
+
+	if in == nil {
+		return nil
+	}
+	out := make([]kdv1beta1.BackupSnapshot, len(in))
+	for i := range in {
+		out[i] = kdv1beta1.BackupSnapshot(in[i])
+	}
+	return out
+}
+
+// backupSnapshotsFromV1beta1 converts a list of v1beta1 backup snapshots to
+// v1.
+func backupSnapshotsFromV1beta1(
+	in []kdv1beta1.BackupSnapshot,
+) []BackupSnapshot {
+
+	if in == nil {
+		return nil
+	}
+	out := make([]BackupSnapshot, len(in))
+	for i := range in {
+		out[i] = BackupSnapshot(in[i])
+	}
+	return out
+}
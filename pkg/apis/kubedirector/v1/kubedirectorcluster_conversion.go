@@ -0,0 +1,583 @@
+// Copyright 2022 Hewlett Packard Enterprise Development LP
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"fmt"
+
+	kdv1beta1 "github.com/bluek8s/kubedirector/pkg/apis/kubedirector/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+)
+
+// ConvertTo converts this KubeDirectorCluster to the hub (v1beta1) version.
+func (in *KubeDirectorCluster) ConvertTo(
+	dstRaw conversion.Hub,
+) error {
+
+	dst, ok := dstRaw.(*kdv1beta1.KubeDirectorCluster)
+	if !ok {
+		return fmt.Errorf("unexpected conversion hub type %T", dstRaw)
+	}
+	src := in.DeepCopy()
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Spec = clusterSpecToV1beta1(&src.Spec)
+	dst.Status = nil
+	if src.Status != nil {
+		status := clusterStatusToV1beta1(src.Status)
+		dst.Status = &status
+	}
+	return nil
+}
+
+// ConvertFrom converts from the hub (v1beta1) version to this version.
+func (in *KubeDirectorCluster) ConvertFrom(
+	srcRaw conversion.Hub,
+) error {
+
+	src, ok := srcRaw.(*kdv1beta1.KubeDirectorCluster)
+	if !ok {
+		return fmt.Errorf("unexpected conversion hub type %T", srcRaw)
+	}
+	src = src.DeepCopy()
+	in.ObjectMeta = src.ObjectMeta
+	in.Spec = clusterSpecFromV1beta1(&src.Spec)
+	in.Status = nil
+	if src.Status != nil {
+		status := clusterStatusFromV1beta1(src.Status)
+		in.Status = &status
+	}
+	return nil
+}
+
+// clusterSpecToV1beta1 converts a v1 cluster spec to v1beta1.
+func clusterSpecToV1beta1(
+	in *KubeDirectorClusterSpec,
+) kdv1beta1.KubeDirectorClusterSpec {
+
+	out := kdv1beta1.KubeDirectorClusterSpec{
+		AppID:         in.AppID,
+		AppCatalog:    in.AppCatalog,
+		Connections:   kdv1beta1.Connections(in.Connections),
+		NamingScheme:  in.NamingScheme,
+		ConfigChoices: in.ConfigChoices,
+		Suspend:       in.Suspend,
+	}
+	if in.ServiceType != nil {
+		serviceType := string(*in.ServiceType)
+		out.ServiceType = &serviceType
+	}
+	if in.Roles != nil {
+		out.Roles = make([]kdv1beta1.Role, len(in.Roles))
+		for i := range in.Roles {
+			out.Roles[i] = roleToV1beta1(&in.Roles[i])
+		}
+	}
+	if in.DefaultSecret != nil {
+		defaultSecret := kdv1beta1.KDSecret(*in.DefaultSecret)
+		out.DefaultSecret = &defaultSecret
+	}
+	if in.CloneFrom != nil {
+		cloneFrom := kdv1beta1.CloneSource(*in.CloneFrom)
+		out.CloneFrom = &cloneFrom
+	}
+	if in.PVCRetentionPolicy != nil {
+		pvcRetentionPolicy := kdv1beta1.PVCRetentionPolicy(*in.PVCRetentionPolicy)
+		out.PVCRetentionPolicy = &pvcRetentionPolicy
+	}
+	return out
+}
+
+// clusterSpecFromV1beta1 converts a v1beta1 cluster spec to v1.
+func clusterSpecFromV1beta1(
+	in *kdv1beta1.KubeDirectorClusterSpec,
+) KubeDirectorClusterSpec {
+
+	out := KubeDirectorClusterSpec{
+		AppID:         in.AppID,
+		AppCatalog:    in.AppCatalog,
+		Connections:   Connections(in.Connections),
+		NamingScheme:  in.NamingScheme,
+		ConfigChoices: in.ConfigChoices,
+		Suspend:       in.Suspend,
+	}
+	if in.ServiceType != nil {
+		serviceType := corev1.ServiceType(*in.ServiceType)
+		out.ServiceType = &serviceType
+	}
+	if in.Roles != nil {
+		out.Roles = make([]Role, len(in.Roles))
+		for i := range in.Roles {
+			out.Roles[i] = roleFromV1beta1(&in.Roles[i])
+		}
+	}
+	if in.DefaultSecret != nil {
+		defaultSecret := KDSecret(*in.DefaultSecret)
+		out.DefaultSecret = &defaultSecret
+	}
+	if in.CloneFrom != nil {
+		cloneFrom := CloneSource(*in.CloneFrom)
+		out.CloneFrom = &cloneFrom
+	}
+	if in.PVCRetentionPolicy != nil {
+		pvcRetentionPolicy := PVCRetentionPolicy(*in.PVCRetentionPolicy)
+		out.PVCRetentionPolicy = &pvcRetentionPolicy
+	}
+	return out
+}
+
+// roleToV1beta1 converts a v1 cluster role to v1beta1.
+func roleToV1beta1(
+	in *Role,
+) kdv1beta1.Role {
+
+	out := kdv1beta1.Role{
+		Name:                      in.Name,
+		PodLabels:                 in.PodLabels,
+		PodAnnotations:            in.PodAnnotations,
+		ServiceLabels:             in.ServiceLabels,
+		ServiceAnnotations:        in.ServiceAnnotations,
+		Members:                   in.Members,
+		MaxUnavailable:            in.MaxUnavailable,
+		ImageRepoTag:              in.ImageRepoTag,
+		Resources:                 in.Resources,
+		Affinity:                  in.Affinity,
+		EnvVars:                   in.EnvVars,
+		SharedMemory:              in.SharedMemory,
+		ServiceAccountName:        in.ServiceAccountName,
+		Tolerations:               in.Tolerations,
+		NodeSelector:              in.NodeSelector,
+		PriorityClassName:         in.PriorityClassName,
+		SchedulerName:             in.SchedulerName,
+		RuntimeClassName:          in.RuntimeClassName,
+		TopologySpreadConstraints: in.TopologySpreadConstraints,
+	}
+	if in.Storage != nil {
+		out.Storage = &kdv1beta1.ClusterStorage{
+			Size:         in.Storage.Size,
+			StorageClass: in.Storage.StorageClass,
+		}
+		if in.Storage.Volumes != nil {
+			out.Storage.Volumes = make([]kdv1beta1.StorageVolume, len(in.Storage.Volumes))
+			for i, volume := range in.Storage.Volumes {
+				out.Storage.Volumes[i] = kdv1beta1.StorageVolume(volume)
+			}
+		}
+	}
+	if in.FileInjections != nil {
+		out.FileInjections = make([]kdv1beta1.FileInjections, len(in.FileInjections))
+		for i, fileInjection := range in.FileInjections {
+			out.FileInjections[i] = kdv1beta1.FileInjections{
+				SrcURL:  fileInjection.SrcURL,
+				DestDir: fileInjection.DestDir,
+			}
+			if fileInjection.Permissions != nil {
+				permissions := kdv1beta1.FilePermissions(*fileInjection.Permissions)
+				out.FileInjections[i].Permissions = &permissions
+			}
+		}
+	}
+	if in.Secret != nil {
+		secret := kdv1beta1.KDSecret(*in.Secret)
+		out.Secret = &secret
+	}
+	if in.BlockStorage != nil {
+		blockStorage := kdv1beta1.BlockStorage(*in.BlockStorage)
+		out.BlockStorage = &blockStorage
+	}
+	if in.SecretKeys != nil {
+		out.SecretKeys = make([]kdv1beta1.SecretKey, len(in.SecretKeys))
+		for i, secretKey := range in.SecretKeys {
+			out.SecretKeys[i] = kdv1beta1.SecretKey(secretKey)
+		}
+	}
+	if in.VolumeProjections != nil {
+		out.VolumeProjections = make([]kdv1beta1.VolumeProjections, len(in.VolumeProjections))
+		for i, volumeProjection := range in.VolumeProjections {
+			out.VolumeProjections[i] = kdv1beta1.VolumeProjections(volumeProjection)
+		}
+	}
+	if in.TerminationPolicy != nil {
+		terminationPolicy := kdv1beta1.TerminationPolicy(*in.TerminationPolicy)
+		out.TerminationPolicy = &terminationPolicy
+	}
+	if in.ServiceType != nil {
+		serviceType := string(*in.ServiceType)
+		out.ServiceType = &serviceType
+	}
+	out.SecurityContext = securityContextToV1beta1(in.SecurityContext)
+	return out
+}
+
+// roleFromV1beta1 converts a v1beta1 cluster role to v1.
+func roleFromV1beta1(
+	in *kdv1beta1.Role,
+) Role {
+
+	out := Role{
+		Name:                      in.Name,
+		PodLabels:                 in.PodLabels,
+		PodAnnotations:            in.PodAnnotations,
+		ServiceLabels:             in.ServiceLabels,
+		ServiceAnnotations:        in.ServiceAnnotations,
+		Members:                   in.Members,
+		MaxUnavailable:            in.MaxUnavailable,
+		ImageRepoTag:              in.ImageRepoTag,
+		Resources:                 in.Resources,
+		Affinity:                  in.Affinity,
+		EnvVars:                   in.EnvVars,
+		SharedMemory:              in.SharedMemory,
+		ServiceAccountName:        in.ServiceAccountName,
+		Tolerations:               in.Tolerations,
+		NodeSelector:              in.NodeSelector,
+		PriorityClassName:         in.PriorityClassName,
+		SchedulerName:             in.SchedulerName,
+		RuntimeClassName:          in.RuntimeClassName,
+		TopologySpreadConstraints: in.TopologySpreadConstraints,
+	}
+	if in.Storage != nil {
+		out.Storage = &ClusterStorage{
+			Size:         in.Storage.Size,
+			StorageClass: in.Storage.StorageClass,
+		}
+		if in.Storage.Volumes != nil {
+			out.Storage.Volumes = make([]StorageVolume, len(in.Storage.Volumes))
+			for i, volume := range in.Storage.Volumes {
+				out.Storage.Volumes[i] = StorageVolume(volume)
+			}
+		}
+	}
+	if in.FileInjections != nil {
+		out.FileInjections = make([]FileInjections, len(in.FileInjections))
+		for i, fileInjection := range in.FileInjections {
+			out.FileInjections[i] = FileInjections{
+				SrcURL:  fileInjection.SrcURL,
+				DestDir: fileInjection.DestDir,
+			}
+			if fileInjection.Permissions != nil {
+				permissions := FilePermissions(*fileInjection.Permissions)
+				out.FileInjections[i].Permissions = &permissions
+			}
+		}
+	}
+	if in.Secret != nil {
+		secret := KDSecret(*in.Secret)
+		out.Secret = &secret
+	}
+	if in.BlockStorage != nil {
+		blockStorage := BlockStorage(*in.BlockStorage)
+		out.BlockStorage = &blockStorage
+	}
+	if in.SecretKeys != nil {
+		out.SecretKeys = make([]SecretKey, len(in.SecretKeys))
+		for i, secretKey := range in.SecretKeys {
+			out.SecretKeys[i] = SecretKey(secretKey)
+		}
+	}
+	if in.VolumeProjections != nil {
+		out.VolumeProjections = make([]VolumeProjections, len(in.VolumeProjections))
+		for i, volumeProjection := range in.VolumeProjections {
+			out.VolumeProjections[i] = VolumeProjections(volumeProjection)
+		}
+	}
+	if in.TerminationPolicy != nil {
+		terminationPolicy := TerminationPolicy(*in.TerminationPolicy)
+		out.TerminationPolicy = &terminationPolicy
+	}
+	if in.ServiceType != nil {
+		serviceType := corev1.ServiceType(*in.ServiceType)
+		out.ServiceType = &serviceType
+	}
+	out.SecurityContext = securityContextFromV1beta1(in.SecurityContext)
+	return out
+}
+
+// clusterStatusToV1beta1 converts a v1 cluster status to v1beta1.
+func clusterStatusToV1beta1(
+	in *KubeDirectorClusterStatus,
+) kdv1beta1.KubeDirectorClusterStatus {
+
+	out := kdv1beta1.KubeDirectorClusterStatus{
+		State:                   in.State,
+		MemberStateRollup:       kdv1beta1.StateRollup(in.MemberStateRollup),
+		GenerationUID:           in.GenerationUID,
+		SpecGenerationToProcess: in.SpecGenerationToProcess,
+		ClusterService:          in.ClusterService,
+		IstioRouting:            in.IstioRouting,
+		LastNodeID:              in.LastNodeID,
+		LastConnectionHash:      in.LastConnectionHash,
+		SuspendedTime:           in.SuspendedTime,
+	}
+	if in.RestoreProgress != nil {
+		restoreProgress := kdv1beta1.RestoreProgress(*in.RestoreProgress)
+		out.RestoreProgress = &restoreProgress
+	}
+	if in.Roles != nil {
+		out.Roles = make([]kdv1beta1.RoleStatus, len(in.Roles))
+		for i := range in.Roles {
+			out.Roles[i] = roleStatusToV1beta1(&in.Roles[i])
+		}
+	}
+	if in.UpgradeProgress != nil {
+		upgradeProgress := kdv1beta1.UpgradeProgress(*in.UpgradeProgress)
+		out.UpgradeProgress = &upgradeProgress
+	}
+	if in.RetainedPVCs != nil {
+		out.RetainedPVCs = make([]kdv1beta1.RetainedPVCs, len(in.RetainedPVCs))
+		for i, retained := range in.RetainedPVCs {
+			out.RetainedPVCs[i] = kdv1beta1.RetainedPVCs(retained)
+		}
+	}
+	out.Conditions = conditionsToV1beta1(in.Conditions)
+	return out
+}
+
+// clusterStatusFromV1beta1 converts a v1beta1 cluster status to v1.
+func clusterStatusFromV1beta1(
+	in *kdv1beta1.KubeDirectorClusterStatus,
+) KubeDirectorClusterStatus {
+
+	out := KubeDirectorClusterStatus{
+		State:                   in.State,
+		MemberStateRollup:       StateRollup(in.MemberStateRollup),
+		GenerationUID:           in.GenerationUID,
+		SpecGenerationToProcess: in.SpecGenerationToProcess,
+		ClusterService:          in.ClusterService,
+		IstioRouting:            in.IstioRouting,
+		LastNodeID:              in.LastNodeID,
+		LastConnectionHash:      in.LastConnectionHash,
+		SuspendedTime:           in.SuspendedTime,
+	}
+	if in.RestoreProgress != nil {
+		restoreProgress := RestoreProgress(*in.RestoreProgress)
+		out.RestoreProgress = &restoreProgress
+	}
+	if in.Roles != nil {
+		out.Roles = make([]RoleStatus, len(in.Roles))
+		for i := range in.Roles {
+			out.Roles[i] = roleStatusFromV1beta1(&in.Roles[i])
+		}
+	}
+	if in.UpgradeProgress != nil {
+		upgradeProgress := UpgradeProgress(*in.UpgradeProgress)
+		out.UpgradeProgress = &upgradeProgress
+	}
+	if in.RetainedPVCs != nil {
+		out.RetainedPVCs = make([]RetainedPVCs, len(in.RetainedPVCs))
+		for i, retained := range in.RetainedPVCs {
+			out.RetainedPVCs[i] = RetainedPVCs(retained)
+		}
+	}
+	out.Conditions = conditionsFromV1beta1(in.Conditions)
+	return out
+}
+
+// roleStatusToV1beta1 converts a v1 role status to v1beta1.
+func roleStatusToV1beta1(
+	in *RoleStatus,
+) kdv1beta1.RoleStatus {
+
+	out := kdv1beta1.RoleStatus{
+		Name:                  in.Name,
+		StatefulSet:           in.StatefulSet,
+		EncryptedSecretKeys:   in.EncryptedSecretKeys,
+		ImageRepoTag:          in.ImageRepoTag,
+		PodDisruptionBudget:   in.PodDisruptionBudget,
+		RoleService:           in.RoleService,
+		PodMonitor:            in.PodMonitor,
+		RecreatingStatefulSet: in.RecreatingStatefulSet,
+	}
+	if in.Members != nil {
+		out.Members = make([]kdv1beta1.MemberStatus, len(in.Members))
+		for i := range in.Members {
+			out.Members[i] = memberStatusToV1beta1(&in.Members[i])
+		}
+	}
+	return out
+}
+
+// roleStatusFromV1beta1 converts a v1beta1 role status to v1.
+func roleStatusFromV1beta1(
+	in *kdv1beta1.RoleStatus,
+) RoleStatus {
+
+	out := RoleStatus{
+		Name:                  in.Name,
+		StatefulSet:           in.StatefulSet,
+		EncryptedSecretKeys:   in.EncryptedSecretKeys,
+		ImageRepoTag:          in.ImageRepoTag,
+		PodDisruptionBudget:   in.PodDisruptionBudget,
+		RoleService:           in.RoleService,
+		PodMonitor:            in.PodMonitor,
+		RecreatingStatefulSet: in.RecreatingStatefulSet,
+	}
+	if in.Members != nil {
+		out.Members = make([]MemberStatus, len(in.Members))
+		for i := range in.Members {
+			out.Members[i] = memberStatusFromV1beta1(&in.Members[i])
+		}
+	}
+	return out
+}
+
+// memberStatusToV1beta1 converts a v1 member status to v1beta1.
+func memberStatusToV1beta1(
+	in *MemberStatus,
+) kdv1beta1.MemberStatus {
+
+	out := kdv1beta1.MemberStatus{
+		Pod:              in.Pod,
+		Service:          in.Service,
+		InternalService:  in.InternalService,
+		AuthToken:        in.AuthToken,
+		PVC:              in.PVC,
+		PVCs:             in.PVCs,
+		State:            in.State,
+		NodeID:           in.NodeID,
+		BlockDevicePaths: in.BlockDevicePaths,
+	}
+	detail := &in.StateDetail
+	out.StateDetail = kdv1beta1.MemberStateDetail{
+		ConfigErrorDetail:        detail.ConfigErrorDetail,
+		LastConfigDataGeneration: detail.LastConfigDataGeneration,
+		LastSetupGeneration:      detail.LastSetupGeneration,
+		ConfiguringContainer:     detail.ConfiguringContainer,
+		LastConfiguredContainer:  detail.LastConfiguredContainer,
+		LastKnownContainerState:  detail.LastKnownContainerState,
+		LastConnectionVersion:    detail.LastConnectionVersion,
+		StartScriptOutMsg:        detail.StartScriptOutMsg,
+		StartScriptErrMsg:        detail.StartScriptErrMsg,
+		SchedulingErrorMessage:   detail.SchedulingErrorMessage,
+		StorageInitProgress:      detail.StorageInitProgress,
+		ConfiguredApp:            detail.ConfiguredApp,
+		RestartCount:             detail.RestartCount,
+		LastTerminatedTime:       detail.LastTerminatedTime,
+		LastRestartTime:          detail.LastRestartTime,
+		WaitingOnDependency:      detail.WaitingOnDependency,
+		CloneSource:              detail.CloneSource,
+	}
+	if in.Dashboards != nil {
+		out.Dashboards = make([]kdv1beta1.MemberDashboard, len(in.Dashboards))
+		for i, dashboard := range in.Dashboards {
+			out.Dashboards[i] = kdv1beta1.MemberDashboard(dashboard)
+		}
+	}
+	if detail.StorageResizes != nil {
+		out.StateDetail.StorageResizes = make([]kdv1beta1.PVCResize, len(detail.StorageResizes))
+		for i, resize := range detail.StorageResizes {
+			out.StateDetail.StorageResizes[i] = kdv1beta1.PVCResize(resize)
+		}
+	}
+	if detail.PendingNotifyCmds != nil {
+		out.StateDetail.PendingNotifyCmds = make([]*kdv1beta1.NotificationDesc, len(detail.PendingNotifyCmds))
+		for i, notify := range detail.PendingNotifyCmds {
+			if notify != nil {
+				notifyDesc := kdv1beta1.NotificationDesc(*notify)
+				out.StateDetail.PendingNotifyCmds[i] = &notifyDesc
+			}
+		}
+	}
+	return out
+}
+
+// memberStatusFromV1beta1 converts a v1beta1 member status to v1.
+func memberStatusFromV1beta1(
+	in *kdv1beta1.MemberStatus,
+) MemberStatus {
+
+	out := MemberStatus{
+		Pod:              in.Pod,
+		Service:          in.Service,
+		InternalService:  in.InternalService,
+		AuthToken:        in.AuthToken,
+		PVC:              in.PVC,
+		PVCs:             in.PVCs,
+		State:            in.State,
+		NodeID:           in.NodeID,
+		BlockDevicePaths: in.BlockDevicePaths,
+	}
+	detail := &in.StateDetail
+	out.StateDetail = MemberStateDetail{
+		ConfigErrorDetail:        detail.ConfigErrorDetail,
+		LastConfigDataGeneration: detail.LastConfigDataGeneration,
+		LastSetupGeneration:      detail.LastSetupGeneration,
+		ConfiguringContainer:     detail.ConfiguringContainer,
+		LastConfiguredContainer:  detail.LastConfiguredContainer,
+		LastKnownContainerState:  detail.LastKnownContainerState,
+		LastConnectionVersion:    detail.LastConnectionVersion,
+		StartScriptOutMsg:        detail.StartScriptOutMsg,
+		StartScriptErrMsg:        detail.StartScriptErrMsg,
+		SchedulingErrorMessage:   detail.SchedulingErrorMessage,
+		StorageInitProgress:      detail.StorageInitProgress,
+		ConfiguredApp:            detail.ConfiguredApp,
+		RestartCount:             detail.RestartCount,
+		LastTerminatedTime:       detail.LastTerminatedTime,
+		LastRestartTime:          detail.LastRestartTime,
+		WaitingOnDependency:      detail.WaitingOnDependency,
+		CloneSource:              detail.CloneSource,
+	}
+	if in.Dashboards != nil {
+		out.Dashboards = make([]MemberDashboard, len(in.Dashboards))
+		for i, dashboard := range in.Dashboards {
+			out.Dashboards[i] = MemberDashboard(dashboard)
+		}
+	}
+	if detail.StorageResizes != nil {
+		out.StateDetail.StorageResizes = make([]PVCResize, len(detail.StorageResizes))
+		for i, resize := range detail.StorageResizes {
+			out.StateDetail.StorageResizes[i] = PVCResize(resize)
+		}
+	}
+	if detail.PendingNotifyCmds != nil {
+		out.StateDetail.PendingNotifyCmds = make([]*NotificationDesc, len(detail.PendingNotifyCmds))
+		for i, notify := range detail.PendingNotifyCmds {
+			if notify != nil {
+				notifyDesc := NotificationDesc(*notify)
+				out.StateDetail.PendingNotifyCmds[i] = &notifyDesc
+			}
+		}
+	}
+	return out
+}
+
+// conditionsToV1beta1 converts a list of v1 conditions to v1beta1.
+func conditionsToV1beta1(
+	in []Condition,
+) []kdv1beta1.Condition {
+
+	if in == nil {
+		return nil
+	}
+	out := make([]kdv1beta1.Condition, len(in))
+	for i, condition := range in {
+		out[i] = kdv1beta1.Condition(condition)
+	}
+	return out
+}
+
+// conditionsFromV1beta1 converts a list of v1beta1 conditions to v1.
+func conditionsFromV1beta1(
+	in []kdv1beta1.Condition,
+) []Condition {
+
+	if in == nil {
+		return nil
+	}
+	out := make([]Condition, len(in))
+	for i, condition := range in {
+		out[i] = Condition(condition)
+	}
+	return out
+}
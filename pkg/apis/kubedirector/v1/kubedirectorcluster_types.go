@@ -0,0 +1,471 @@
+// Copyright 2022 Hewlett Packard Enterprise Development LP
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// UID represents the old naming scheme where object names were generated
+	// with unique UID extensions.
+	UID string = "UID"
+
+	// CrNameRole represents the new naming scheme based on cluster name and
+	// respective role name.
+	CrNameRole string = "CrNameRole"
+
+	// TerminationPolicyWait leaves a member with a terminated app container
+	// alone, waiting for K8s to restart the container.
+	TerminationPolicyWait string = "wait"
+
+	// TerminationPolicyRestartPod deletes the pod of a member with a
+	// terminated app container. The member keeps its persistent storage.
+	TerminationPolicyRestartPod string = "restartPod"
+
+	// TerminationPolicyRecreateMember deletes the pod and the PVC of a member
+	// with a terminated app container, so that the member is set up again
+	// from scratch using the same pod name.
+	TerminationPolicyRecreateMember string = "recreateMember"
+
+	// TerminationPolicyMarkConfigError puts a member with a terminated app
+	// container into config error state.
+	TerminationPolicyMarkConfigError string = "markConfigError"
+
+	// PVCResizeRequested means that a larger size has been requested for a
+	// member PVC, but the resize has not started yet.
+	PVCResizeRequested string = "requested"
+
+	// PVCResizeResizing means that the volume of a member PVC is being
+	// resized.
+	PVCResizeResizing string = "resizing"
+
+	// PVCResizeFileSystemPending means that the volume of a member PVC has
+	// been resized, but the filesystem on it has not been resized yet.
+	PVCResizeFileSystemPending string = "fileSystemResizePending"
+
+	// PVCRetentionPolicyRetain keeps the PVCs of a member when it is removed
+	// (or of all members when the cluster is deleted).
+	PVCRetentionPolicyRetain string = "Retain"
+
+	// PVCRetentionPolicyDelete deletes the PVCs of a member when it is
+	// removed (or of all members when the cluster is deleted).
+	PVCRetentionPolicyDelete string = "Delete"
+
+	// ClusterConditionReady is true when the cluster is configured and all
+	// of its members are up and running.
+	ClusterConditionReady string = "Ready"
+
+	// ClusterConditionConfigured is true when the cluster has been fully
+	// reconciled to its current spec.
+	ClusterConditionConfigured string = "Configured"
+
+	// ClusterConditionMembersDegraded is true when any members are down,
+	// unschedulable, failing their readiness probe, or in config error state.
+	ClusterConditionMembersDegraded string = "MembersDegraded"
+
+	// ClusterConditionConnectionsSynced is true when all ready members have
+	// been informed of the current state of the connected resources.
+	ClusterConditionConnectionsSynced string = "ConnectionsSynced"
+
+	// ClusterConditionRestoring is true while the cluster is being restored
+	// from backup.
+	ClusterConditionRestoring string = "Restoring"
+
+	// ClusterConditionSpecChangePending is true when a spec change has been
+	// accepted but not yet fully processed.
+	ClusterConditionSpecChangePending string = "SpecChangePending"
+)
+
+// KubeDirectorClusterSpec defines the desired state of KubeDirectorCluster.
+// AppID references a KubeDirectorApp CR. ServiceType indicates whether to
+// use NodePort or LoadBalancer services. The Roles field describes the
+// requested cluster roles, each of which will be implemented (by KubeDirector)
+// using a StatefulSet. ConfigChoices maps config choice IDs from the
+// KubeDirectorApp to the IDs of the chosen selections. Suspend, if true,
+// scales every role down to zero members while keeping their persistent
+// storage and member status, until it is set back to false. CloneFrom, if
+// set, seeds the persistent storage of each member from the corresponding
+// member of an existing cluster. PVCRetentionPolicy says whether member PVCs
+// are kept when members are removed or the cluster is deleted.
+type KubeDirectorClusterSpec struct {
+	AppID              string              `json:"app"`
+	AppCatalog         *string             `json:"appCatalog,omitempty"`
+	ServiceType        *corev1.ServiceType `json:"serviceType,omitempty"`
+	Roles              []Role              `json:"roles"`
+	DefaultSecret      *KDSecret           `json:"defaultSecret,omitempty"`
+	Connections        Connections         `json:"connections"`
+	NamingScheme       *string             `json:"namingScheme,omitempty"`
+	ConfigChoices      map[string]string   `json:"configChoices,omitempty"`
+	Suspend            *bool               `json:"suspend,omitempty"`
+	CloneFrom          *CloneSource        `json:"cloneFrom,omitempty"`
+	PVCRetentionPolicy *PVCRetentionPolicy `json:"persistentVolumeClaimRetentionPolicy,omitempty"`
+}
+
+// CloneSource identifies the existing cluster that a new cluster is cloned
+// from. Cluster is the name of a cluster in the same namespace. By default
+// the PVC of each new member is cloned directly from the PVC of the source
+// member with the same role and ordinal. If VolumeSnapshotPrefix is set, the
+// PVC is instead restored from the CSI VolumeSnapshot named by that prefix
+// followed by "-" and the name of the source member's PVC.
+type CloneSource struct {
+	Cluster              string  `json:"cluster"`
+	VolumeSnapshotPrefix *string `json:"volumeSnapshotPrefix,omitempty"`
+}
+
+// PVCRetentionPolicy controls what happens to the PVCs of members that are
+// removed when a role shrinks (WhenScaled) and to the PVCs of all members
+// when the cluster is deleted (WhenDeleted). Each is one of the
+// PVCRetentionPolicy* constants; an unset property comes from the
+// KubeDirectorConfig default policy, or is otherwise Delete.
+type PVCRetentionPolicy struct {
+	WhenScaled  *string `json:"whenScaled,omitempty"`
+	WhenDeleted *string `json:"whenDeleted,omitempty"`
+}
+
+// Connections specifies list of cluster objects and configmaps objects that has
+// be connected to the cluster.
+type Connections struct {
+	Clusters   []string `json:"clusters,omitempty"`
+	ConfigMaps []string `json:"configmaps,omitempty"`
+	Secrets    []string `json:"secrets,omitempty"`
+}
+
+// KubeDirectorClusterStatus defines the observed state of KubeDirectorCluster.
+// It identifies which native k8s objects make up the cluster, and broadly
+// indicates ongoing operations of cluster creation or reconfiguration.
+// SuspendedTime is when the cluster was suspended; it is cleared once the
+// cluster has been resumed and its members reconfigured. RetainedPVCs lists
+// the PVCs kept from removed members by the PVC retention policy.
+// IstioRouting names the Istio VirtualService and DestinationRule for the
+// cluster service, if KubeDirector is configured for Istio. (The other
+// services of the cluster also get routing objects named after them.)
+type KubeDirectorClusterStatus struct {
+	State                   string           `json:"state"`
+	RestoreProgress         *RestoreProgress `json:"restoreProgress,omitempty"`
+	MemberStateRollup       StateRollup      `json:"memberStateRollup"`
+	GenerationUID           string           `json:"generationUID"`
+	SpecGenerationToProcess *int64           `json:"specGenerationToProcess,omitempty"`
+	ClusterService          string           `json:"clusterService"`
+	IstioRouting            string           `json:"istioRouting,omitempty"`
+	LastNodeID              int64            `json:"lastNodeID"`
+	Roles                   []RoleStatus     `json:"roles"`
+	LastConnectionHash      string           `json:"lastConnectionHash"`
+	UpgradeProgress         *UpgradeProgress `json:"upgradeProgress,omitempty"`
+	Conditions              []Condition      `json:"conditions,omitempty"`
+	SuspendedTime           *metav1.Time     `json:"suspendedTime,omitempty"`
+	RetainedPVCs            []RetainedPVCs   `json:"retainedPVCs,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// KubeDirectorCluster is the Schema for the kubedirectorclusters API.
+// This object represents a single virtual cluster. This cluster will be
+// implemented by KubeDirector using native k8s objects.
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=kubedirectorclusters,scope=Namespaced
+type KubeDirectorCluster struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              KubeDirectorClusterSpec    `json:"spec,omitempty"`
+	Status            *KubeDirectorClusterStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// KubeDirectorClusterList contains a list of KubeDirectorCluster.
+type KubeDirectorClusterList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []KubeDirectorCluster `json:"items"`
+}
+
+// KDSecret describes a secret object intended to be mounted inside a container.
+type KDSecret struct {
+	Name        string `json:"name"`
+	DefaultMode *int32 `json:"defaultMode,omitempty"`
+	MountPath   string `json:"mountPath"`
+	ReadOnly    bool   `json:"readOnly,omitempty"`
+}
+
+// EnvVar specifies environment variables for the start script in a container
+type EnvVar struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// FilePermissions specifies file mode along with user/group
+// information for the file
+type FilePermissions struct {
+	FileMode  *int32  `json:"fileMode,omitempty"`
+	FileOwner *string `json:"fileOwner,omitempty"`
+	FileGroup *string `json:"fileGroup,omitempty"`
+}
+
+// FileInjections specifies file injection spec, including
+// file permissions on the destination file
+type FileInjections struct {
+	SrcURL      string           `json:"srcURL"`
+	DestDir     string           `json:"destDir"`
+	Permissions *FilePermissions `json:"permissions,omitempty"`
+}
+
+// VolumeProjections describes an individual volume projection
+// spec for mounting user created volumes to
+type VolumeProjections struct {
+	PvcName   string `json:"pvcName"`
+	MountPath string `json:"mountPath"`
+	ReadOnly  bool   `json:"readOnly,omitempty"`
+}
+
+// Role describes a subset of the virtual cluster members that shares a common
+// image, resource requirements, persistent storage definition, and (as
+// defined by the cluster's KubeDirectorApp) set of service endpoints. The
+// image of the app role can be overridden with imageRepoTag, if the app role
+// lists a matching pattern in its allowedImages. The scheduling properties
+// (tolerations, nodeSelector, etc.) are merged with any matching scheduling
+// defaults from the KubeDirectorConfig. Any securityContext properties set
+// here take precedence over those of the app role. ServiceType overrides the
+// cluster's serviceType for the member services of this role.
+type Role struct {
+	Name                      string                            `json:"id"`
+	PodLabels                 map[string]string                 `json:"podLabels,omitempty"`
+	PodAnnotations            map[string]string                 `json:"podAnnotations,omitempty"`
+	ServiceLabels             map[string]string                 `json:"serviceLabels,omitempty"`
+	ServiceAnnotations        map[string]string                 `json:"serviceAnnotations,omitempty"`
+	Members                   *int32                            `json:"members,omitempty"`
+	MaxUnavailable            *int32                            `json:"maxUnavailable,omitempty"`
+	Resources                 corev1.ResourceRequirements       `json:"resources"`
+	Affinity                  *corev1.Affinity                  `json:"affinity,omitempty"`
+	Storage                   *ClusterStorage                   `json:"storage,omitempty"`
+	EnvVars                   []corev1.EnvVar                   `json:"env,omitempty"`
+	SharedMemory              *string                           `json:"sharedMemory,omitempty"`
+	FileInjections            []FileInjections                  `json:"fileInjections,omitempty"`
+	Secret                    *KDSecret                         `json:"secret,omitempty"`
+	BlockStorage              *BlockStorage                     `json:"blockStorage,omitempty"`
+	ServiceAccountName        string                            `json:"serviceAccountName,omitempty"`
+	ServiceType               *corev1.ServiceType               `json:"serviceType,omitempty"`
+	SecretKeys                []SecretKey                       `json:"secretKeys,omitempty"`
+	VolumeProjections         []VolumeProjections               `json:"volumeProjections,omitempty"`
+	TerminationPolicy         *TerminationPolicy                `json:"terminationPolicy,omitempty"`
+	ImageRepoTag              *string                           `json:"imageRepoTag,omitempty"`
+	Tolerations               []corev1.Toleration               `json:"tolerations,omitempty"`
+	NodeSelector              map[string]string                 `json:"nodeSelector,omitempty"`
+	PriorityClassName         string                            `json:"priorityClassName,omitempty"`
+	SchedulerName             string                            `json:"schedulerName,omitempty"`
+	RuntimeClassName          *string                           `json:"runtimeClassName,omitempty"`
+	TopologySpreadConstraints []corev1.TopologySpreadConstraint `json:"topologySpreadConstraints,omitempty"`
+	SecurityContext           *SecurityContext                  `json:"securityContext,omitempty"`
+}
+
+// TerminationPolicy specifies what to do when the app container of a ready
+// (or config error) member is found to be terminated. Mode is one of the
+// TerminationPolicy* constants. MaxRestarts limits how many times in a row a
+// member will be restarted or recreated; once that budget is used up the
+// member is put into config error state instead.
+type TerminationPolicy struct {
+	Mode        *string `json:"mode,omitempty"`
+	MaxRestarts *int32  `json:"maxRestarts,omitempty"`
+}
+
+// SecretKey holds data which is supposed to be only available on configuration phase
+type SecretKey struct {
+	// Name is required and cannot be empty
+	Name string `json:"name"`
+	// Non-empty Value overrides EncryptedValue after encryption.
+	// Empty Value is allowed but requires EncryptedValue to be empty when submitting.
+	Value string `json:"value,omitempty"`
+	// EncryptedValue is supposed to be generated by KD webhook
+	// by encrypting Value with AES using master encryption key from KubeDirectorConfig
+	EncryptedValue string `json:"encryptedValue,omitempty"`
+}
+
+// RestoreProgress identifies any necessary kdcluster components that have
+// not yet been re-created by a backup restore.
+type RestoreProgress struct {
+	AwaitingApp       bool   `json:"awaitingApp"`
+	AwaitingStatus    bool   `json:"awaitingStatus"`
+	AwaitingResources bool   `json:"awaitingResources"`
+	Error             string `json:"error"`
+}
+
+// UpgradeProgress tracks an in-flight change of the kdapp used by a running
+// kdcluster. PrevApp and TargetApp are kdapp resource names; if the spec is
+// reverted to PrevApp before the upgrade completes, the two are swapped and
+// RollingBack is set. FailedMembers lists any members whose upgrade event
+// returned an error, which pauses the rollout.
+type UpgradeProgress struct {
+	PrevApp         string   `json:"prevApp"`
+	TargetApp       string   `json:"targetApp"`
+	RollingBack     bool     `json:"rollingBack"`
+	UpgradedMembers int32    `json:"upgradedMembers"`
+	TotalMembers    int32    `json:"totalMembers"`
+	FailedMembers   []string `json:"failedMembers,omitempty"`
+}
+
+// Condition describes one aspect of the current state of a KubeDirector
+// resource, in the same form as the standard K8s status conditions. Type
+// is one of the per-resource condition constants, and Status is "True",
+// "False", or "Unknown". LastTransitionTime only changes when Status does.
+type Condition struct {
+	Type               string                 `json:"type"`
+	Status             corev1.ConditionStatus `json:"status"`
+	ObservedGeneration int64                  `json:"observedGeneration,omitempty"`
+	LastTransitionTime metav1.Time            `json:"lastTransitionTime"`
+	Reason             string                 `json:"reason"`
+	Message            string                 `json:"message"`
+}
+
+// StateRollup surfaces whether any per-member statuses have problems that
+// should be investigated.
+type StateRollup struct {
+	MembershipChanging  bool `json:"membershipChanging"`
+	MembersDown         bool `json:"membersDown"`
+	MembersInitializing bool `json:"membersInitializing"`
+	MembersWaiting      bool `json:"membersWaiting"`
+	MembersRestarting   bool `json:"membersRestarting"`
+	ConfigErrors        bool `json:"configErrors"`
+	MembersNotScheduled bool `json:"membersNotScheduled"`
+	MembersDegraded     bool `json:"membersDegraded"`
+}
+
+// ClusterStorage defines the persistent storage size/type, if any, to be used
+// for certain specified directories of each container filesystem in a role.
+// Volumes provides additional named volumes for the persistVolumes declared
+// by the app role; directories of any app volume not provided here are kept
+// on the main volume.
+type ClusterStorage struct {
+	Size         string          `json:"size"`
+	StorageClass *string         `json:"storageClassName,omitempty"`
+	Volumes      []StorageVolume `json:"volumes,omitempty"`
+}
+
+// StorageVolume defines the size/type of one named persistent volume of a
+// role. If StorageClass is unspecified, the class of the role's main volume
+// is used.
+type StorageVolume struct {
+	Name         string  `json:"name"`
+	Size         string  `json:"size"`
+	StorageClass *string `json:"storageClassName,omitempty"`
+}
+
+// BlockStorage defines the block storage type, path, and optionally size, if any, to be used
+// for mounting a block volume in a role.
+type BlockStorage struct {
+	StorageClass *string `json:"storageClassName,omitempty"`
+	Path         *string `json:"pathPrefix,omitempty"`
+	Size         *string `json:"size,omitempty"`
+	NumDevices   *int32  `json:"numDevices,omitempty"`
+}
+
+// RoleStatus describes the component objects of a virtual cluster role
+// (including any PodDisruptionBudget, role-wide service, and Prometheus
+// PodMonitor), and the image used by the role's statefulset.
+// RecreatingStatefulSet is set while the statefulset is being replaced
+// (keeping its pods) to pick up new volume claim templates.
+type RoleStatus struct {
+	Name                  string            `json:"id"`
+	StatefulSet           string            `json:"statefulSet"`
+	Members               []MemberStatus    `json:"members"`
+	EncryptedSecretKeys   map[string]string `json:"encryptedSecretKeys,omitempty"`
+	ImageRepoTag          string            `json:"imageRepoTag,omitempty"`
+	PodDisruptionBudget   string            `json:"podDisruptionBudget,omitempty"`
+	RoleService           string            `json:"roleService,omitempty"`
+	PodMonitor            string            `json:"podMonitor,omitempty"`
+	RecreatingStatefulSet bool              `json:"recreatingStatefulSet,omitempty"`
+}
+
+// RetainedPVCs records the PVCs that were kept when a member was removed.
+// If the role grows back to include a member with the same pod name, the
+// new member reuses them.
+type RetainedPVCs struct {
+	Role string   `json:"role"`
+	Pod  string   `json:"pod"`
+	PVCs []string `json:"pvcs"`
+}
+
+// MemberStatus describes the component objects of a virtual cluster member.
+// Service is the member's service for its externally exposed endpoints, and
+// InternalService (if any) is a ClusterIP service for the rest. PVC is the
+// claim for the member's main persistent volume, and PVCs lists the claims
+// for all of its persistent volumes (the main volume first). Dashboards
+// lists the Ingress or HTTPRoute objects that expose the member's dashboard
+// endpoints, if the KubeDirectorConfig asks for them.
+type MemberStatus struct {
+	Pod              string            `json:"pod"`
+	Service          string            `json:"service"`
+	InternalService  string            `json:"internalService,omitempty"`
+	Dashboards       []MemberDashboard `json:"dashboards,omitempty"`
+	AuthToken        string            `json:"authToken,omitempty"`
+	PVC              string            `json:"pvc,omitempty"`
+	PVCs             []string          `json:"pvcs,omitempty"`
+	State            string            `json:"state"`
+	StateDetail      MemberStateDetail `json:"stateDetail,omitempty"`
+	NodeID           int64             `json:"nodeID"`
+	BlockDevicePaths []string          `json:"blockDevicePaths,omitempty"`
+}
+
+// MemberDashboard records the Ingress or HTTPRoute (as given by Kind) that
+// exposes a member's dashboard endpoint for the given service, and the URL
+// at which the dashboard can be reached.
+type MemberDashboard struct {
+	Service string `json:"service"`
+	Kind    string `json:"kind"`
+	Route   string `json:"route"`
+	URL     string `json:"url"`
+}
+
+// MemberStateDetail digs into detail about the management of configmeta and
+// app scripts in the member.
+type MemberStateDetail struct {
+	ConfigErrorDetail        *string             `json:"configErrorDetail,omitempty"`
+	LastConfigDataGeneration *int64              `json:"lastConfigDataGeneration,omitempty"`
+	LastSetupGeneration      *int64              `json:"lastSetupGeneration,omitempty"`
+	ConfiguringContainer     string              `json:"configuringContainer,omitempty"`
+	LastConfiguredContainer  string              `json:"lastConfiguredContainer,omitempty"`
+	LastKnownContainerState  string              `json:"lastKnownContainerState,omitempty"`
+	PendingNotifyCmds        []*NotificationDesc `json:"pendingNotifyCmds,omitempty"`
+	LastConnectionVersion    *int64              `json:"lastConnectionVersion,omitempty"`
+	StartScriptOutMsg        string              `json:"startScriptStdoutMessage,omitempty"`
+	StartScriptErrMsg        string              `json:"startScriptStderrMessage,omitempty"`
+	SchedulingErrorMessage   *string             `json:"schedulingErrorMessage,omitempty"`
+	StorageInitProgress      *string             `json:"storageInitProgress,omitempty"`
+	ConfiguredApp            string              `json:"configuredApp,omitempty"`
+	RestartCount             int32               `json:"restartCount,omitempty"`
+	LastTerminatedTime       *metav1.Time        `json:"lastTerminatedTime,omitempty"`
+	LastRestartTime          *metav1.Time        `json:"lastRestartTime,omitempty"`
+	WaitingOnDependency      []string            `json:"waitingOnDependency,omitempty"`
+	CloneSource              string              `json:"cloneSource,omitempty"`
+	StorageResizes           []PVCResize         `json:"storageResizes,omitempty"`
+}
+
+// PVCResize tracks the expansion of one of a member's PVCs, from its current
+// capacity to the requested size. State is one of the PVCResize* constants.
+type PVCResize struct {
+	PVC       string `json:"pvc"`
+	Requested string `json:"requested"`
+	Capacity  string `json:"capacity,omitempty"`
+	State     string `json:"state"`
+}
+
+// NotificationDesc contains the info necessary to perform a notify command.
+type NotificationDesc struct {
+	Arguments []string `json:"arguments,omitempty"`
+}
+
+func init() {
+	SchemeBuilder.Register(&KubeDirectorCluster{}, &KubeDirectorClusterList{})
+}
@@ -0,0 +1,177 @@
+// Copyright 2022 Hewlett Packard Enterprise Development LP
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"fmt"
+
+	kdv1beta1 "github.com/bluek8s/kubedirector/pkg/apis/kubedirector/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+)
+
+// ConvertTo converts this KubeDirectorConfig to the hub (v1beta1) version.
+func (in *KubeDirectorConfig) ConvertTo(
+	dstRaw conversion.Hub,
+) error {
+
+	dst, ok := dstRaw.(*kdv1beta1.KubeDirectorConfig)
+	if !ok {
+		return fmt.Errorf("unexpected conversion hub type %T", dstRaw)
+	}
+	src := in.DeepCopy()
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Spec = nil
+	if src.Spec != nil {
+		spec := configSpecToV1beta1(src.Spec)
+		dst.Spec = &spec
+	}
+	dst.Status = nil
+	if src.Status != nil {
+		dst.Status = &kdv1beta1.KubeDirectorConfigStatus{
+			GenerationUID: src.Status.GenerationUID,
+			State:         src.Status.State,
+			Conditions:    conditionsToV1beta1(src.Status.Conditions),
+		}
+	}
+	return nil
+}
+
+// ConvertFrom converts from the hub (v1beta1) version to this version.
+func (in *KubeDirectorConfig) ConvertFrom(
+	srcRaw conversion.Hub,
+) error {
+
+	src, ok := srcRaw.(*kdv1beta1.KubeDirectorConfig)
+	if !ok {
+		return fmt.Errorf("unexpected conversion hub type %T", srcRaw)
+	}
+	src = src.DeepCopy()
+	in.ObjectMeta = src.ObjectMeta
+	in.Spec = nil
+	if src.Spec != nil {
+		spec := configSpecFromV1beta1(src.Spec)
+		in.Spec = &spec
+	}
+	in.Status = nil
+	if src.Status != nil {
+		in.Status = &KubeDirectorConfigStatus{
+			GenerationUID: src.Status.GenerationUID,
+			State:         src.Status.State,
+			Conditions:    conditionsFromV1beta1(src.Status.Conditions),
+		}
+	}
+	return nil
+}
+
+// configSpecToV1beta1 converts a v1 config spec to v1beta1.
+func configSpecToV1beta1(
+	in *KubeDirectorConfigSpec,
+) kdv1beta1.KubeDirectorConfigSpec {
+
+	out := kdv1beta1.KubeDirectorConfigSpec{
+		StorageClass:                   in.StorageClass,
+		NativeSystemdSupport:           in.NativeSystemdSupport,
+		RequiredSecretPrefix:           in.RequiredSecretPrefix,
+		ClusterSvcDomainBase:           in.ClusterSvcDomainBase,
+		DefaultNamingScheme:            in.DefaultNamingScheme,
+		MasterEncryptionKey:            in.MasterEncryptionKey,
+		PodLabels:                      in.PodLabels,
+		PodAnnotations:                 in.PodAnnotations,
+		ServiceLabels:                  in.ServiceLabels,
+		ServiceAnnotations:             in.ServiceAnnotations,
+		BackupClusterStatus:            in.BackupClusterStatus,
+		AllowRestoreWithoutConnections: in.AllowRestoreWithoutConnections,
+		ForceSharedMemorySizeSupport:   in.ForceSharedMemorySizeSupport,
+		RestrictedMode:                 in.RestrictedMode,
+	}
+	if in.ServiceType != nil {
+		serviceType := string(*in.ServiceType)
+		out.ServiceType = &serviceType
+	}
+	if in.DefaultTerminationPolicy != nil {
+		terminationPolicy := kdv1beta1.TerminationPolicy(*in.DefaultTerminationPolicy)
+		out.DefaultTerminationPolicy = &terminationPolicy
+	}
+	if in.DefaultPVCRetentionPolicy != nil {
+		pvcRetentionPolicy := kdv1beta1.PVCRetentionPolicy(*in.DefaultPVCRetentionPolicy)
+		out.DefaultPVCRetentionPolicy = &pvcRetentionPolicy
+	}
+	if in.DashboardIngress != nil {
+		dashboardIngress := kdv1beta1.DashboardIngress(*in.DashboardIngress)
+		out.DashboardIngress = &dashboardIngress
+	}
+	if in.Istio != nil {
+		istio := kdv1beta1.IstioSettings(*in.Istio)
+		out.Istio = &istio
+	}
+	if in.SchedulingDefaults != nil {
+		out.SchedulingDefaults = make([]kdv1beta1.SchedulingDefaults, len(in.SchedulingDefaults))
+		for i, schedulingDefaults := range in.SchedulingDefaults {
+			out.SchedulingDefaults[i] = kdv1beta1.SchedulingDefaults(schedulingDefaults)
+		}
+	}
+	return out
+}
+
+// configSpecFromV1beta1 converts a v1beta1 config spec to v1.
+func configSpecFromV1beta1(
+	in *kdv1beta1.KubeDirectorConfigSpec,
+) KubeDirectorConfigSpec {
+
+	out := KubeDirectorConfigSpec{
+		StorageClass:                   in.StorageClass,
+		NativeSystemdSupport:           in.NativeSystemdSupport,
+		RequiredSecretPrefix:           in.RequiredSecretPrefix,
+		ClusterSvcDomainBase:           in.ClusterSvcDomainBase,
+		DefaultNamingScheme:            in.DefaultNamingScheme,
+		MasterEncryptionKey:            in.MasterEncryptionKey,
+		PodLabels:                      in.PodLabels,
+		PodAnnotations:                 in.PodAnnotations,
+		ServiceLabels:                  in.ServiceLabels,
+		ServiceAnnotations:             in.ServiceAnnotations,
+		BackupClusterStatus:            in.BackupClusterStatus,
+		AllowRestoreWithoutConnections: in.AllowRestoreWithoutConnections,
+		ForceSharedMemorySizeSupport:   in.ForceSharedMemorySizeSupport,
+		RestrictedMode:                 in.RestrictedMode,
+	}
+	if in.ServiceType != nil {
+		serviceType := corev1.ServiceType(*in.ServiceType)
+		out.ServiceType = &serviceType
+	}
+	if in.DefaultTerminationPolicy != nil {
+		terminationPolicy := TerminationPolicy(*in.DefaultTerminationPolicy)
+		out.DefaultTerminationPolicy = &terminationPolicy
+	}
+	if in.DefaultPVCRetentionPolicy != nil {
+		pvcRetentionPolicy := PVCRetentionPolicy(*in.DefaultPVCRetentionPolicy)
+		out.DefaultPVCRetentionPolicy = &pvcRetentionPolicy
+	}
+	if in.DashboardIngress != nil {
+		dashboardIngress := DashboardIngress(*in.DashboardIngress)
+		out.DashboardIngress = &dashboardIngress
+	}
+	if in.Istio != nil {
+		istio := IstioSettings(*in.Istio)
+		out.Istio = &istio
+	}
+	if in.SchedulingDefaults != nil {
+		out.SchedulingDefaults = make([]SchedulingDefaults, len(in.SchedulingDefaults))
+		for i, schedulingDefaults := range in.SchedulingDefaults {
+			out.SchedulingDefaults[i] = SchedulingDefaults(schedulingDefaults)
+		}
+	}
+	return out
+}
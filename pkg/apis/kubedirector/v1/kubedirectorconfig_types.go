@@ -0,0 +1,147 @@
+// Copyright 2022 Hewlett Packard Enterprise Development LP
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ConfigConditionReady is true when the config has been processed and
+	// is in use by KubeDirector.
+	ConfigConditionReady string = "Ready"
+
+	// DashboardIngressModeIngress exposes dashboard endpoints through K8s
+	// Ingress objects.
+	DashboardIngressModeIngress string = "ingress"
+	// DashboardIngressModeGateway exposes dashboard endpoints through
+	// Gateway API HTTPRoute objects attached to an existing Gateway.
+	DashboardIngressModeGateway string = "gateway"
+
+	// IstioTLSModeMutual has Istio sidecars use mutual TLS for traffic
+	// between the members of a kdcluster.
+	IstioTLSModeMutual string = "ISTIO_MUTUAL"
+	// IstioTLSModeDisable has Istio sidecars send plain traffic between the
+	// members of a kdcluster.
+	IstioTLSModeDisable string = "DISABLE"
+)
+
+// KubeDirectorConfigSpec defines the desired state of KubeDirectorConfig.
+type KubeDirectorConfigSpec struct {
+	StorageClass                   *string              `json:"defaultStorageClassName,omitempty"`
+	ServiceType                    *corev1.ServiceType  `json:"defaultServiceType,omitempty"`
+	NativeSystemdSupport           *bool                `json:"nativeSystemdSupport,omitempty"`
+	RequiredSecretPrefix           *string              `json:"requiredSecretPrefix,omitempty"`
+	ClusterSvcDomainBase           *string              `json:"clusterSvcDomainBase,omitempty"`
+	DefaultNamingScheme            *string              `json:"defaultNamingScheme,omitempty"`
+	MasterEncryptionKey            *string              `json:"masterEncryptionKey,omitempty"`
+	PodLabels                      map[string]string    `json:"podLabels,omitempty"`
+	PodAnnotations                 map[string]string    `json:"podAnnotations,omitempty"`
+	ServiceLabels                  map[string]string    `json:"serviceLabels,omitempty"`
+	ServiceAnnotations             map[string]string    `json:"serviceAnnotations,omitempty"`
+	BackupClusterStatus            *bool                `json:"backupClusterStatus,omitempty"`
+	AllowRestoreWithoutConnections *bool                `json:"allowRestoreWithoutConnections,omitempty"`
+	ForceSharedMemorySizeSupport   *bool                `json:"forceSharedMemorySizeSupport,omitempty"`
+	DefaultTerminationPolicy       *TerminationPolicy   `json:"defaultTerminationPolicy,omitempty"`
+	DefaultPVCRetentionPolicy      *PVCRetentionPolicy  `json:"defaultPersistentVolumeClaimRetentionPolicy,omitempty"`
+	SchedulingDefaults             []SchedulingDefaults `json:"schedulingDefaults,omitempty"`
+	RestrictedMode                 *bool                `json:"restrictedMode,omitempty"`
+	DashboardIngress               *DashboardIngress    `json:"dashboardIngress,omitempty"`
+	Istio                          *IstioSettings       `json:"istio,omitempty"`
+}
+
+// IstioSettings turns on support for running kdcluster members in an Istio
+// service mesh, if Enabled is true. TLSMode (one of the IstioTLSMode*
+// constants) is used in the DestinationRule generated for each kdcluster;
+// ISTIO_MUTUAL if unset. ExcludePorts lists ports whose inbound and outbound
+// traffic should bypass the sidecar proxy in member pods.
+type IstioSettings struct {
+	Enabled      bool    `json:"enabled"`
+	TLSMode      *string `json:"tlsMode,omitempty"`
+	ExcludePorts []int32 `json:"excludePorts,omitempty"`
+}
+
+// DashboardIngress describes how the dashboard endpoints of kdcluster
+// members are exposed through a host name for each member and endpoint, as
+// an alternative to reaching them through NodePort or LoadBalancer services.
+// Mode is one of the DashboardIngressMode* constants. IngressClassName picks
+// the Ingress controller in ingress mode; GatewayName and GatewayNamespace
+// name the Gateway that HTTPRoutes attach to in gateway mode. HostPattern
+// forms each host name, and may use the placeholders {member}, {role},
+// {cluster}, {namespace}, and {service}. TLSSecretName names the secret with
+// the certificate for those hosts in ingress mode; GatewayTLS says whether
+// the Gateway listener serves https in gateway mode. Annotations are added
+// to every created Ingress or HTTPRoute.
+type DashboardIngress struct {
+	Mode             string            `json:"mode"`
+	IngressClassName *string           `json:"ingressClassName,omitempty"`
+	GatewayName      string            `json:"gatewayName,omitempty"`
+	GatewayNamespace string            `json:"gatewayNamespace,omitempty"`
+	HostPattern      string            `json:"hostPattern"`
+	TLSSecretName    *string           `json:"tlsSecretName,omitempty"`
+	GatewayTLS       bool              `json:"gatewayTLS,omitempty"`
+	Annotations      map[string]string `json:"annotations,omitempty"`
+}
+
+// SchedulingDefaults declares scheduling properties for the roles of
+// kdclusters that use the given kdapp (AppID) and/or are in the given
+// namespace; an empty AppID or Namespace matches any. Each property is used
+// for a role that does not set it. NodeSelector entries are merged with the
+// role's nodeSelector, with the role's values taking precedence. If several
+// entries match a role, later entries take precedence over earlier ones.
+type SchedulingDefaults struct {
+	AppID                     string                            `json:"app,omitempty"`
+	Namespace                 string                            `json:"namespace,omitempty"`
+	Tolerations               []corev1.Toleration               `json:"tolerations,omitempty"`
+	NodeSelector              map[string]string                 `json:"nodeSelector,omitempty"`
+	PriorityClassName         string                            `json:"priorityClassName,omitempty"`
+	SchedulerName             string                            `json:"schedulerName,omitempty"`
+	RuntimeClassName          *string                           `json:"runtimeClassName,omitempty"`
+	TopologySpreadConstraints []corev1.TopologySpreadConstraint `json:"topologySpreadConstraints,omitempty"`
+}
+
+// KubeDirectorConfigStatus defines the observed state of KubeDirectorConfig.
+type KubeDirectorConfigStatus struct {
+	GenerationUID string      `json:"generationUID"`
+	State         string      `json:"state"`
+	Conditions    []Condition `json:"conditions,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// KubeDirectorConfig is the Schema for the kubedirectorconfigs API.
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=kubedirectorconfigs,scope=Namespaced
+type KubeDirectorConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   *KubeDirectorConfigSpec   `json:"spec,omitempty"`
+	Status *KubeDirectorConfigStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// KubeDirectorConfigList contains a list of KubeDirectorConfig.
+type KubeDirectorConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []KubeDirectorConfig `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&KubeDirectorConfig{}, &KubeDirectorConfigList{})
+}
@@ -0,0 +1,71 @@
+// Copyright 2022 Hewlett Packard Enterprise Development LP
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"fmt"
+
+	kdv1beta1 "github.com/bluek8s/kubedirector/pkg/apis/kubedirector/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+)
+
+// ConvertTo converts this KubeDirectorRoleScaler to the hub (v1beta1)
+// version.
+func (in *KubeDirectorRoleScaler) ConvertTo(
+	dstRaw conversion.Hub,
+) error {
+
+	dst, ok := dstRaw.(*kdv1beta1.KubeDirectorRoleScaler)
+	if !ok {
+		return fmt.Errorf("unexpected conversion hub type %T", dstRaw)
+	}
+	src := in.DeepCopy()
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Spec = kdv1beta1.KubeDirectorRoleScalerSpec(src.Spec)
+	dst.Status = nil
+	if src.Status != nil {
+		dst.Status = &kdv1beta1.KubeDirectorRoleScalerStatus{
+			Replicas:      src.Status.Replicas,
+			Selector:      src.Status.Selector,
+			LastScaleTime: src.Status.LastScaleTime,
+			Conditions:    conditionsToV1beta1(src.Status.Conditions),
+		}
+	}
+	return nil
+}
+
+// ConvertFrom converts from the hub (v1beta1) version to this version.
+func (in *KubeDirectorRoleScaler) ConvertFrom(
+	srcRaw conversion.Hub,
+) error {
+
+	src, ok := srcRaw.(*kdv1beta1.KubeDirectorRoleScaler)
+	if !ok {
+		return fmt.Errorf("unexpected conversion hub type %T", srcRaw)
+	}
+	src = src.DeepCopy()
+	in.ObjectMeta = src.ObjectMeta
+	in.Spec = KubeDirectorRoleScalerSpec(src.Spec)
+	in.Status = nil
+	if src.Status != nil {
+		in.Status = &KubeDirectorRoleScalerStatus{
+			Replicas:      src.Status.Replicas,
+			Selector:      src.Status.Selector,
+			LastScaleTime: src.Status.LastScaleTime,
+			Conditions:    conditionsFromV1beta1(src.Status.Conditions),
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+// Copyright 2022 Hewlett Packard Enterprise Development LP
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// RoleScalerConditionReady is true when the scaler's target role exists
+	// and has been resized to the scaler's requested member count (within
+	// the limits allowed for the role).
+	RoleScalerConditionReady string = "Ready"
+
+	// RoleScalerConditionLimited is true when the scaler's requested member
+	// count is outside the limits allowed for the role, and the role is
+	// being sized to the nearest allowed count instead.
+	RoleScalerConditionLimited string = "Limited"
+)
+
+// KubeDirectorRoleScalerSpec defines the desired state of
+// KubeDirectorRoleScaler. Cluster and Role identify a scale-out role of a
+// kdcluster in the same namespace. Replicas is the desired member count for
+// that role; if it is unset the scaler only reports the role's current size.
+// CooldownSeconds is the minimum time between two resizes made through the
+// scaler.
+type KubeDirectorRoleScalerSpec struct {
+	Cluster         string `json:"cluster"`
+	Role            string `json:"role"`
+	Replicas        *int32 `json:"replicas,omitempty"`
+	CooldownSeconds *int32 `json:"cooldownSeconds,omitempty"`
+}
+
+// KubeDirectorRoleScalerStatus defines the observed state of
+// KubeDirectorRoleScaler. Replicas and Selector are the current member count
+// of the role and the label selector for its pods, as expected by the scale
+// subresource. LastScaleTime is when the scaler last resized the role.
+type KubeDirectorRoleScalerStatus struct {
+	Replicas      int32        `json:"replicas"`
+	Selector      string       `json:"selector"`
+	LastScaleTime *metav1.Time `json:"lastScaleTime,omitempty"`
+	Conditions    []Condition  `json:"conditions,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// KubeDirectorRoleScaler is the Schema for the kubedirectorrolescalers API.
+// This object controls the member count of a single role of a virtual
+// cluster, and implements the scale subresource so that autoscalers can
+// drive it.
+// +kubebuilder:subresource:status
+// +kubebuilder:subresource:scale:specpath=.spec.replicas,statuspath=.status.replicas,selectorpath=.status.selector
+// +kubebuilder:resource:path=kubedirectorrolescalers,scope=Namespaced
+type KubeDirectorRoleScaler struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              KubeDirectorRoleScalerSpec    `json:"spec,omitempty"`
+	Status            *KubeDirectorRoleScalerStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// KubeDirectorRoleScalerList contains a list of KubeDirectorRoleScaler.
+type KubeDirectorRoleScalerList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []KubeDirectorRoleScaler `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&KubeDirectorRoleScaler{}, &KubeDirectorRoleScalerList{})
+}
@@ -0,0 +1,77 @@
+// Copyright 2022 Hewlett Packard Enterprise Development LP
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"fmt"
+
+	kdv1beta1 "github.com/bluek8s/kubedirector/pkg/apis/kubedirector/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+)
+
+// ConvertTo converts this KubeDirectorStatusBackup to the hub (v1beta1)
+// version.
+func (in *KubeDirectorStatusBackup) ConvertTo(
+	dstRaw conversion.Hub,
+) error {
+
+	dst, ok := dstRaw.(*kdv1beta1.KubeDirectorStatusBackup)
+	if !ok {
+		return fmt.Errorf("unexpected conversion hub type %T", dstRaw)
+	}
+	src := in.DeepCopy()
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Spec.StatusBackup = nil
+	if src.Spec.StatusBackup != nil {
+		status := clusterStatusToV1beta1(src.Spec.StatusBackup)
+		dst.Spec.StatusBackup = &status
+	}
+	dst.Spec.DataBackup = nil
+	if src.Spec.DataBackup != nil {
+		dst.Spec.DataBackup = &kdv1beta1.DataBackup{
+			Backup:         src.Spec.DataBackup.Backup,
+			CompletionTime: src.Spec.DataBackup.CompletionTime,
+			Snapshots:      backupSnapshotsToV1beta1(src.Spec.DataBackup.Snapshots),
+		}
+	}
+	return nil
+}
+
+// ConvertFrom converts from the hub (v1beta1) version to this version.
+func (in *KubeDirectorStatusBackup) ConvertFrom(
+	srcRaw conversion.Hub,
+) error {
+
+	src, ok := srcRaw.(*kdv1beta1.KubeDirectorStatusBackup)
+	if !ok {
+		return fmt.Errorf("unexpected conversion hub type %T", srcRaw)
+	}
+	src = src.DeepCopy()
+	in.ObjectMeta = src.ObjectMeta
+	in.Spec.StatusBackup = nil
+	if src.Spec.StatusBackup != nil {
+		status := clusterStatusFromV1beta1(src.Spec.StatusBackup)
+		in.Spec.StatusBackup = &status
+	}
+	in.Spec.DataBackup = nil
+	if src.Spec.DataBackup != nil {
+		in.Spec.DataBackup = &DataBackup{
+			Backup:         src.Spec.DataBackup.Backup,
+			CompletionTime: src.Spec.DataBackup.CompletionTime,
+			Snapshots:      backupSnapshotsFromV1beta1(src.Spec.DataBackup.Snapshots),
+		}
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+// Copyright 2022 Hewlett Packard Enterprise Development LP
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta1
+
+// v1beta1 is the storage version of the KubeDirector resources, and the
+// version used internally by KubeDirector. It is therefore the hub that other
+// API versions convert to and from.
+
+// Hub marks KubeDirectorApp as a conversion hub.
+func (*KubeDirectorApp) Hub() {}
+
+// Hub marks KubeDirectorCluster as a conversion hub.
+func (*KubeDirectorCluster) Hub() {}
+
+// Hub marks KubeDirectorConfig as a conversion hub.
+func (*KubeDirectorConfig) Hub() {}
+
+// Hub marks KubeDirectorStatusBackup as a conversion hub.
+func (*KubeDirectorStatusBackup) Hub() {}
+
+// Hub marks KubeDirectorRoleScaler as a conversion hub.
+func (*KubeDirectorRoleScaler) Hub() {}
+
+// Hub marks KubeDirectorBackup as a conversion hub.
+func (*KubeDirectorBackup) Hub() {}
@@ -57,3 +57,34 @@ func (setupPackage SetupPackage) MarshalJSON() ([]byte, error) {
 
 	return json.Marshal(setupPackage.Info)
 }
+
+// MarshalJSON for KubeDirectorAppSpec omits the defaultConfigPackage
+// property if it is unset. An unset SetupPackage has no JSON representation
+// of its own, so this must be handled by the containing type.
+func (appSpec KubeDirectorAppSpec) MarshalJSON() ([]byte, error) {
+	type plainAppSpec KubeDirectorAppSpec
+	if appSpec.DefaultSetupPackage.IsSet {
+		return json.Marshal(plainAppSpec(appSpec))
+	}
+	return json.Marshal(
+		struct {
+			plainAppSpec
+			DefaultSetupPackage *SetupPackage `json:"defaultConfigPackage,omitempty"`
+		}{plainAppSpec: plainAppSpec(appSpec)},
+	)
+}
+
+// MarshalJSON for NodeRole omits the configPackage property if it is unset,
+// as with KubeDirectorAppSpec above.
+func (nodeRole NodeRole) MarshalJSON() ([]byte, error) {
+	type plainNodeRole NodeRole
+	if nodeRole.SetupPackage.IsSet {
+		return json.Marshal(plainNodeRole(nodeRole))
+	}
+	return json.Marshal(
+		struct {
+			plainNodeRole
+			SetupPackage *SetupPackage `json:"configPackage,omitempty"`
+		}{plainNodeRole: plainNodeRole(nodeRole)},
+	)
+}
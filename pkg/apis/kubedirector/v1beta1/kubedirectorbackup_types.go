@@ -0,0 +1,97 @@
+// Copyright 2022 Hewlett Packard Enterprise Development LP
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// BackupStatePending means the backup is waiting for its kdcluster to be
+	// stable enough to back up.
+	BackupStatePending string = "pending"
+
+	// BackupStateSnapshotting means the members have been quiesced and
+	// their volume snapshots are being taken.
+	BackupStateSnapshotting string = "snapshotting"
+
+	// BackupStateFinishing means the members have been resumed and the
+	// volume snapshots are being made ready for use.
+	BackupStateFinishing string = "finishing"
+
+	// BackupStateCompleted means all volume snapshots are ready for use.
+	BackupStateCompleted string = "completed"
+
+	// BackupStateFailed means the backup could not be completed; see the
+	// status message for the reason.
+	BackupStateFailed string = "failed"
+)
+
+// KubeDirectorBackupSpec defines the desired state of KubeDirectorBackup.
+// Cluster identifies a kdcluster in the same namespace. The persistent
+// storage of each of its members is captured in a CSI VolumeSnapshot, using
+// the given VolumeSnapshotClassName if any.
+type KubeDirectorBackupSpec struct {
+	Cluster                 string  `json:"cluster"`
+	VolumeSnapshotClassName *string `json:"volumeSnapshotClassName,omitempty"`
+}
+
+// KubeDirectorBackupStatus defines the observed state of KubeDirectorBackup.
+// StartTime is when the members were quiesced and CompletionTime is when all
+// of the snapshots became ready for use. Snapshots lists the volume snapshot
+// taken for each member with persistent storage.
+type KubeDirectorBackupStatus struct {
+	State          string           `json:"state"`
+	Message        string           `json:"message,omitempty"`
+	StartTime      *metav1.Time     `json:"startTime,omitempty"`
+	CompletionTime *metav1.Time     `json:"completionTime,omitempty"`
+	Snapshots      []BackupSnapshot `json:"snapshots,omitempty"`
+}
+
+// BackupSnapshot identifies the VolumeSnapshot taken of a member's PVC.
+type BackupSnapshot struct {
+	Role           string `json:"role"`
+	Member         string `json:"member"`
+	PVC            string `json:"pvc"`
+	VolumeSnapshot string `json:"volumeSnapshot"`
+	ReadyToUse     bool   `json:"readyToUse,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// KubeDirectorBackup is the Schema for the kubedirectorbackups API. This
+// object represents an app-consistent backup of the persistent data of a
+// single virtual cluster.
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=kubedirectorbackups,scope=Namespaced
+type KubeDirectorBackup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              KubeDirectorBackupSpec    `json:"spec,omitempty"`
+	Status            *KubeDirectorBackupStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// KubeDirectorBackupList contains a list of KubeDirectorBackup.
+type KubeDirectorBackupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []KubeDirectorBackup `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&KubeDirectorBackup{}, &KubeDirectorBackupList{})
+}
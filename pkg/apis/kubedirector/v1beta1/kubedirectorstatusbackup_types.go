@@ -19,10 +19,20 @@ import (
 )
 
 // KubeDirectorStatusBackupSpec defines the desired state of KubeDirectorStatusBackup.
-// This contains a single property that mirrors the status stanza of the
-// associated KubeDirectorCluster.
+// StatusBackup mirrors the status stanza of the associated
+// KubeDirectorCluster. DataBackup records the volume snapshots of the most
+// recently completed KubeDirectorBackup of that cluster, if any.
 type KubeDirectorStatusBackupSpec struct {
 	StatusBackup *KubeDirectorClusterStatus `json:"statusBackup,omitempty"`
+	DataBackup   *DataBackup                `json:"dataBackup,omitempty"`
+}
+
+// DataBackup identifies a completed KubeDirectorBackup and the volume
+// snapshots that it took.
+type DataBackup struct {
+	Backup         string           `json:"backup"`
+	CompletionTime *metav1.Time     `json:"completionTime,omitempty"`
+	Snapshots      []BackupSnapshot `json:"snapshots,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -41,6 +41,9 @@ const (
 	// SecretType is a label placed on desired secret that
 	// we want to watch and propogate inside containers
 	secretType = shared.KdDomainBase + "/secretType"
+	// baseNodegroupID is the ID of the nodegroup formed by the top-level
+	// config of an app.
+	baseNodegroupID = "1"
 )
 
 // allServiceRefkeys is a subroutine of getServices, used to generate a
@@ -49,6 +52,7 @@ const (
 func allServiceRefkeys(
 	roleNames []string,
 	serviceName string,
+	nodegroupID string,
 	connectedClusterName string,
 ) refkeysMap {
 
@@ -58,7 +62,7 @@ func allServiceRefkeys(
 		if connectedClusterName != "" {
 			refKeyList = []string{"connections", "clusters", connectedClusterName}
 		}
-		refKeyList = append(refKeyList, "nodegroups", "1", "roles", r, "services", serviceName)
+		refKeyList = append(refKeyList, "nodegroups", nodegroupID, "roles", r, "services", serviceName)
 		result[r] = refkeys{
 			BdvlibRefKey: refKeyList,
 		}
@@ -67,28 +71,41 @@ func allServiceRefkeys(
 }
 
 // getServices is a subroutine of clusterBaseConfig, used to generate a
-// description of all active services and their associated roles in the
-// format expected by the app setup Python packages.
+// description of all active services and their associated roles (grouped by
+// nodegroup) in the format expected by the app setup Python packages.
 func getServices(
+	cr *kdv1.KubeDirectorCluster,
 	appCR *kdv1.KubeDirectorApp,
 	membersForRole map[string][]*kdv1.MemberStatus,
 	connectedClusterName string,
 ) map[string]ngRefkeysMap {
 
 	result := make(map[string]ngRefkeysMap)
+	nodegroupConfigs := GetNodegroupConfigs(cr, appCR)
 
 	for _, service := range appCR.Spec.Services {
-		var activeRoleNames []string
-		for _, roleService := range appCR.Spec.Config.RoleServices {
-			if shared.StringInList(service.ID, roleService.ServiceIDs) {
-				if _, ok := membersForRole[roleService.RoleID]; ok {
-					activeRoleNames = append(activeRoleNames, roleService.RoleID)
+		activeRoleNames := make(map[string][]string)
+		for _, nodegroupConfig := range nodegroupConfigs {
+			for _, roleService := range nodegroupConfig.RoleServices {
+				if shared.StringInList(service.ID, roleService.ServiceIDs) {
+					if _, ok := membersForRole[roleService.RoleID]; ok {
+						activeRoleNames[nodegroupConfig.ID] = append(
+							activeRoleNames[nodegroupConfig.ID],
+							roleService.RoleID,
+						)
+					}
 				}
 			}
 		}
 		if len(activeRoleNames) > 0 {
-			result[service.ID] = ngRefkeysMap{
-				"1": allServiceRefkeys(activeRoleNames, service.ID, connectedClusterName),
+			result[service.ID] = make(ngRefkeysMap)
+			for nodegroupID, roleNames := range activeRoleNames {
+				result[service.ID][nodegroupID] = allServiceRefkeys(
+					roleNames,
+					service.ID,
+					nodegroupID,
+					connectedClusterName,
+				)
 			}
 		}
 	}
@@ -96,9 +113,33 @@ func getServices(
 	return result
 }
 
+// nodegroupRefkeys generates a map of nodegroup ID to refkeys, for each
+// nodegroup in the given list. Each refkeys value is the given prefix,
+// followed by the nodegroups key and nodegroup ID, followed by the given
+// suffix.
+func nodegroupRefkeys(
+	nodegroupConfigs []NodegroupConfig,
+	prefix []string,
+	suffix ...string,
+) refkeysMap {
+
+	result := make(refkeysMap)
+	for _, nodegroupConfig := range nodegroupConfigs {
+		var refKeyList []string
+		refKeyList = append(refKeyList, prefix...)
+		refKeyList = append(refKeyList, "nodegroups", nodegroupConfig.ID)
+		refKeyList = append(refKeyList, suffix...)
+		result[nodegroupConfig.ID] = refkeys{
+			BdvlibRefKey: refKeyList,
+		}
+	}
+	return result
+}
+
 // servicesForRole generates a map of service ID to internal service
 // representation, for all services active in the given role.
 func servicesForRole(
+	cr *kdv1.KubeDirectorCluster,
 	appCR *kdv1.KubeDirectorApp,
 	roleName string,
 	members []*kdv1.MemberStatus,
@@ -107,72 +148,85 @@ func servicesForRole(
 ) map[string]service {
 
 	result := make(map[string]service)
-	for _, roleService := range appCR.Spec.Config.RoleServices {
-		if roleService.RoleID == roleName {
-			for _, serviceID := range roleService.ServiceIDs {
-				var serviceToken string
-				serviceDef := GetServiceFromID(appCR, serviceID)
-				var endpoints []string
-				if serviceDef.Endpoint.Port != nil {
-					for _, m := range members {
-						nodeName := m.Pod
-						endpoint := serviceDef.Endpoint.URLScheme
-						endpoint += "://" + nodeName + "." + domain
-						endpoint += ":" + strconv.Itoa(int(*(serviceDef.Endpoint.Port)))
-						endpoints = append(endpoints, endpoint)
-						if serviceDef.Endpoint.HasAuthToken {
-							if len(m.AuthToken) == 0 {
-								checksum := md5.Sum([]byte(uuid.New().String()))
-								m.AuthToken = hex.EncodeToString(checksum[:])
-							}
-							serviceToken = m.AuthToken
-							wait := time.Second
-							maxWait := 4096 * time.Second
-							for {
-								if wait > maxWait {
-									break
-								}
-								k8sService, err := observer.GetService(appCR.Namespace, m.Service)
-								if err == nil {
-									k8sService.Annotations[serviceAuthToken] = serviceToken
-									if shared.Update(context.TODO(), k8sService) == nil {
-										break
-									}
-								}
-								time.Sleep(wait)
-								wait = wait * 2
+	nodegroupID := GetNodegroupForRole(cr, appCR, roleName)
+	var roleService string
+	for _, roleStatus := range cr.Status.Roles {
+		if roleStatus.Name == roleName {
+			roleService = roleStatus.RoleService
+			break
+		}
+	}
+	for _, serviceID := range roleServiceIDs(cr, appCR, roleName) {
+		var serviceToken string
+		serviceDef := GetServiceFromID(appCR, serviceID)
+		var endpoints []string
+		if serviceDef.Endpoint.Port != nil {
+			for _, m := range members {
+				nodeName := m.Pod
+				endpoint := serviceDef.Endpoint.URLScheme
+				endpoint += "://" + nodeName + "." + domain
+				endpoint += ":" + strconv.Itoa(int(*(serviceDef.Endpoint.Port)))
+				endpoints = append(endpoints, endpoint)
+				if serviceDef.Endpoint.HasAuthToken {
+					if len(m.AuthToken) == 0 {
+						checksum := md5.Sum([]byte(uuid.New().String()))
+						m.AuthToken = hex.EncodeToString(checksum[:])
+					}
+					serviceToken = m.AuthToken
+					wait := time.Second
+					maxWait := 4096 * time.Second
+					for {
+						if wait > maxWait {
+							break
+						}
+						k8sService, err := observer.GetService(appCR.Namespace, m.Service)
+						if err == nil {
+							k8sService.Annotations[serviceAuthToken] = serviceToken
+							if shared.Update(context.TODO(), k8sService) == nil {
+								break
 							}
 						}
+						time.Sleep(wait)
+						wait = wait * 2
 					}
 				}
-				s := service{
-					Qualifiers: []string{}, // currently, always empty
-					Name:       serviceDef.Label.Name,
-					ID:         serviceDef.ID,
-					Hostnames: refkeys{
-						BdvlibRefKey: []string{"nodegroups", "1", "roles", roleName, "hostnames"},
-					},
-					GlobalID: "1_" + roleName + "_" + serviceDef.ID,
-					FQDNs: refkeys{
-						BdvlibRefKey: []string{"nodegroups", "1", "roles", roleName, "fqdns"},
-					},
-					ExportedService: serviceDef.ExportedService,
-					Endpoints:       endpoints,
-					AuthToken:       serviceToken,
-				}
-				if connectedClusterName != "" {
-					s.Hostnames.BdvlibRefKey = append(
-						[]string{"connections", "clusters", connectedClusterName},
-						s.Hostnames.BdvlibRefKey...,
-					)
-					s.FQDNs.BdvlibRefKey = append(
-						[]string{"connections", "clusters", connectedClusterName},
-						s.FQDNs.BdvlibRefKey...,
-					)
-				}
-				result[serviceDef.ID] = s
 			}
+			// The role-wide service, if any, is listed as one more endpoint
+			// after those of the individual members.
+			if serviceDef.Endpoint.HasRoleService && (roleService != "") {
+				endpoint := serviceDef.Endpoint.URLScheme
+				endpoint += "://" + roleService + "." + cr.Namespace
+				endpoint += shared.GetSvcClusterDomainBase()
+				endpoint += ":" + strconv.Itoa(int(*(serviceDef.Endpoint.Port)))
+				endpoints = append(endpoints, endpoint)
+			}
+		}
+		s := service{
+			Qualifiers: []string{}, // currently, always empty
+			Name:       serviceDef.Label.Name,
+			ID:         serviceDef.ID,
+			Hostnames: refkeys{
+				BdvlibRefKey: []string{"nodegroups", nodegroupID, "roles", roleName, "hostnames"},
+			},
+			GlobalID: nodegroupID + "_" + roleName + "_" + serviceDef.ID,
+			FQDNs: refkeys{
+				BdvlibRefKey: []string{"nodegroups", nodegroupID, "roles", roleName, "fqdns"},
+			},
+			ExportedService: serviceDef.ExportedService,
+			Endpoints:       endpoints,
+			AuthToken:       serviceToken,
 		}
+		if connectedClusterName != "" {
+			s.Hostnames.BdvlibRefKey = append(
+				[]string{"connections", "clusters", connectedClusterName},
+				s.Hostnames.BdvlibRefKey...,
+			)
+			s.FQDNs.BdvlibRefKey = append(
+				[]string{"connections", "clusters", connectedClusterName},
+				s.FQDNs.BdvlibRefKey...,
+			)
+		}
+		result[serviceDef.ID] = s
 	}
 
 	return result
@@ -301,26 +355,22 @@ func genClusterConnections(
 		if err != nil {
 			return nil, err
 		}
+		nodegroupConfigs := GetNodegroupConfigs(clusterToConnect, appForclusterToConnect)
 		toConnectMeta[clusterName] = configmeta{
 			Version:    strconv.Itoa(appForclusterToConnect.Spec.SchemaVersion),
-			Services:   getServices(appForclusterToConnect, membersForRole, clusterName),
+			Services:   getServices(clusterToConnect, appForclusterToConnect, membersForRole, clusterName),
 			Nodegroups: nodegroups,
 			Distros: map[string]refkeysMap{
-				appForclusterToConnect.Spec.DistroID: refkeysMap{
-					"1": refkeys{
-						BdvlibRefKey: []string{"connections", "clusters", clusterName, "nodegroups", "1"},
-					},
-				},
+				appForclusterToConnect.Spec.DistroID: nodegroupRefkeys(
+					nodegroupConfigs,
+					[]string{"connections", "clusters", clusterName},
+				),
 			},
 			Cluster: cluster{
-				Name:     clusterName,
-				Isolated: false, // currently, always false
-				ID:       string(clusterToConnect.UID),
-				ConfigMeta: map[string]refkeys{
-					"1": refkeys{
-						BdvlibRefKey: []string{"nodegroups", "1", "config_metadata"},
-					},
-				},
+				Name:       clusterName,
+				Isolated:   false, // currently, always false
+				ID:         string(clusterToConnect.UID),
+				ConfigMeta: nodegroupRefkeys(nodegroupConfigs, nil, "config_metadata"),
 			},
 		}
 	}
@@ -329,9 +379,9 @@ func genClusterConnections(
 }
 
 // nodegroups generates a map of nodegroup ID to internal nodegroup
-// representation. Note that KubeDirector currently only allows/manages one
-// nodegroup per virtual cluster, so this will always be a map that has a
-// single key of "1".
+// representation. There is always a nodegroup "1" for the top-level config
+// of the app, plus one for each config choice whose selection (as chosen in
+// the virtual cluster spec) selects any roles.
 func nodegroups(
 	cr *kdv1.KubeDirectorCluster,
 	appCR *kdv1.KubeDirectorApp,
@@ -339,7 +389,11 @@ func nodegroups(
 	domain string,
 ) (map[string]nodegroup, error) {
 
-	roles := make(map[string]role)
+	nodegroupConfigs := GetNodegroupConfigs(cr, appCR)
+	rolesForNodegroup := make(map[string]map[string]role)
+	for _, nodegroupConfig := range nodegroupConfigs {
+		rolesForNodegroup[nodegroupConfig.ID] = make(map[string]role)
+	}
 	for _, roleSpec := range cr.Spec.Roles {
 		roleName := roleSpec.Name
 		members := membersForRole[roleName]
@@ -376,8 +430,9 @@ func nodegroups(
 		if err != nil {
 			return nil, err
 		}
-		roles[roleName] = role{
-			Services:     servicesForRole(appCR, roleName, members, "", domain),
+		nodegroupID := GetNodegroupForRole(cr, appCR, roleName)
+		rolesForNodegroup[nodegroupID][roleName] = role{
+			Services:     servicesForRole(cr, appCR, roleName, members, "", domain),
 			NodeIDs:      nodeIds,
 			Hostnames:    fqdns,
 			FQDNs:        fqdns,
@@ -386,14 +441,16 @@ func nodegroups(
 			SecretKeys:   secretKeys,
 		}
 	}
-	return map[string]nodegroup{
-		"1": nodegroup{
-			Roles:               roles,
+	result := make(map[string]nodegroup)
+	for _, nodegroupConfig := range nodegroupConfigs {
+		result[nodegroupConfig.ID] = nodegroup{
+			Roles:               rolesForNodegroup[nodegroupConfig.ID],
 			DistroID:            appCR.Spec.DistroID,
 			CatalogEntryVersion: appCR.Spec.Version,
-			ConfigMeta:          appCR.Spec.Config.ConfigMetadata,
-		},
-	}, nil
+			ConfigMeta:          nodegroupConfig.ConfigMetadata,
+		}
+	}
+	return result, nil
 }
 
 // secretKeys decrypts role secret keys into name-to-value map
@@ -439,26 +496,19 @@ func clusterBaseConfig(
 	if err != nil {
 		return nil, err
 	}
+	nodegroupConfigs := GetNodegroupConfigs(cr, appCR)
 	return &configmeta{
 		Version:    strconv.Itoa(appCR.Spec.SchemaVersion),
-		Services:   getServices(appCR, membersForRole, ""),
+		Services:   getServices(cr, appCR, membersForRole, ""),
 		Nodegroups: nodegroups,
 		Distros: map[string]refkeysMap{
-			appCR.Spec.DistroID: refkeysMap{
-				"1": refkeys{
-					BdvlibRefKey: []string{"nodegroups", "1"},
-				},
-			},
+			appCR.Spec.DistroID: nodegroupRefkeys(nodegroupConfigs, nil),
 		},
 		Cluster: cluster{
-			Name:     cr.Name,
-			Isolated: false, // currently, always false
-			ID:       string(cr.UID),
-			ConfigMeta: map[string]refkeys{
-				"1": refkeys{
-					BdvlibRefKey: []string{"nodegroups", "1", "config_metadata"},
-				},
-			},
+			Name:       cr.Name,
+			Isolated:   false, // currently, always false
+			ID:         string(cr.UID),
+			ConfigMeta: nodegroupRefkeys(nodegroupConfigs, nil, "config_metadata"),
 		},
 		Connections: connections{
 			Clusters:   clustersMeta,
@@ -495,18 +545,34 @@ func ConfigmetaGenerator(
 		return nil, err
 	}
 	for roleName, members := range membersForRole {
+		dependsOn := make(refkeysMap)
+		if nodeRole := GetRoleFromID(appCR, roleName); nodeRole != nil {
+			for _, dependency := range nodeRole.DependsOn {
+				if _, ok := membersForRole[dependency]; !ok {
+					continue
+				}
+				dependsOn[dependency] = refkeys{
+					BdvlibRefKey: []string{
+						"nodegroups",
+						GetNodegroupForRole(cr, appCR, dependency),
+						"roles",
+						dependency,
+					},
+				}
+			}
+		}
 		for _, member := range members {
 			memberName := member.Pod
 
 			perNodeConfig[memberName] = &node{
 				RoleID:           roleName,
-				NodegroupID:      "1",
+				NodegroupID:      GetNodegroupForRole(cr, appCR, roleName),
 				ID:               strconv.FormatInt(member.NodeID, 10),
 				Hostname:         memberName + "." + domain,
 				FQDN:             memberName + "." + domain,
 				Domain:           domain,
 				DistroID:         appCR.Spec.DistroID,
-				DependsOn:        make(refkeysMap), // currently, always empty
+				DependsOn:        dependsOn,
 				BlockDevicePaths: member.BlockDevicePaths,
 			}
 		}
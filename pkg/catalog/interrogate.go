@@ -16,6 +16,7 @@ package catalog
 
 import (
 	"fmt"
+	"path"
 	"strconv"
 	"strings"
 
@@ -68,6 +69,21 @@ func GetRoleFromID(
 	return nil
 }
 
+// GetConfigChoiceFromID is a utility function that returns the config choice
+// definition for the given choice ID, or nil if no such choice is defined.
+func GetConfigChoiceFromID(
+	appCR *kdv1.KubeDirectorApp,
+	choiceID string,
+) *kdv1.ConfigChoice {
+
+	for i := range appCR.Spec.Config.ConfigChoices {
+		if appCR.Spec.Config.ConfigChoices[i].ID == choiceID {
+			return &(appCR.Spec.Config.ConfigChoices[i])
+		}
+	}
+	return nil
+}
+
 // GetAllRoleIDs is a utility function that returns the list of all node roles
 // ID.
 func GetAllRoleIDs(
@@ -81,15 +97,115 @@ func GetAllRoleIDs(
 	return nodeRoles
 }
 
-// GetSelectedRoleIDs returns the list of selected roles in the config.
+// GetChoiceSelection returns the selection used by the virtual cluster for
+// the given config choice. If the cluster spec does not name a valid
+// selection for the choice, the choice's default selection is used. The
+// result is nil only if the default is not valid either.
+func GetChoiceSelection(
+	cr *kdv1.KubeDirectorCluster,
+	choice *kdv1.ConfigChoice,
+) *kdv1.ConfigSelection {
+
+	var defaultSelection *kdv1.ConfigSelection
+	chosenID, chosen := cr.Spec.ConfigChoices[choice.ID]
+	for i := range choice.Selections {
+		selection := &(choice.Selections[i])
+		if chosen && (selection.ID == chosenID) {
+			return selection
+		}
+		if selection.ID == choice.Default {
+			defaultSelection = selection
+		}
+	}
+	return defaultSelection
+}
+
+// GetNodegroupConfigs returns the nodegroups of the virtual cluster. The
+// first is always nodegroup "1", made from the top-level config of the app.
+// Each config choice whose chosen selection selects any roles adds another
+// nodegroup. Its ID is based on the position of the choice in the app (the
+// first choice is nodegroup "2") so that it does not depend on what was
+// chosen for other choices.
+func GetNodegroupConfigs(
+	cr *kdv1.KubeDirectorCluster,
+	appCR *kdv1.KubeDirectorApp,
+) []NodegroupConfig {
+
+	result := []NodegroupConfig{
+		{
+			ID:             baseNodegroupID,
+			SelectedRoles:  appCR.Spec.Config.SelectedRoles,
+			RoleServices:   appCR.Spec.Config.RoleServices,
+			ConfigMetadata: appCR.Spec.Config.ConfigMetadata,
+		},
+	}
+	for i := range appCR.Spec.Config.ConfigChoices {
+		selection := GetChoiceSelection(cr, &(appCR.Spec.Config.ConfigChoices[i]))
+		if (selection == nil) || (len(selection.SelectedRoles) == 0) {
+			continue
+		}
+		result = append(
+			result,
+			NodegroupConfig{
+				ID:             strconv.Itoa(i + 2),
+				SelectedRoles:  selection.SelectedRoles,
+				RoleServices:   selection.RoleServices,
+				ConfigMetadata: selection.ConfigMetadata,
+			},
+		)
+	}
+	return result
+}
+
+// GetSelectedRoleIDs returns the list of roles selected by the virtual
+// cluster's nodegroups, i.e. by the app's top-level config and by the
+// selections chosen for its config choices.
 func GetSelectedRoleIDs(
+	cr *kdv1.KubeDirectorCluster,
 	appCR *kdv1.KubeDirectorApp,
 ) []string {
 
-	// Will be modified to accept config choices input when that is
-	// implemented.
+	var result []string
+	for _, nodegroupConfig := range GetNodegroupConfigs(cr, appCR) {
+		result = append(result, nodegroupConfig.SelectedRoles...)
+	}
+	return result
+}
+
+// GetNodegroupForRole returns the ID of the nodegroup that the given role
+// belongs to. A role not selected by any nodegroup is treated as part of
+// nodegroup "1".
+func GetNodegroupForRole(
+	cr *kdv1.KubeDirectorCluster,
+	appCR *kdv1.KubeDirectorApp,
+	roleID string,
+) string {
+
+	for _, nodegroupConfig := range GetNodegroupConfigs(cr, appCR) {
+		if shared.StringInList(roleID, nodegroupConfig.SelectedRoles) {
+			return nodegroupConfig.ID
+		}
+	}
+	return baseNodegroupID
+}
 
-	return appCR.Spec.Config.SelectedRoles
+// roleServiceIDs returns the IDs of the services on the given role, as
+// listed in the roleServices of the virtual cluster's nodegroups.
+func roleServiceIDs(
+	cr *kdv1.KubeDirectorCluster,
+	appCR *kdv1.KubeDirectorApp,
+	roleID string,
+) []string {
+
+	var result []string
+	for _, nodegroupConfig := range GetNodegroupConfigs(cr, appCR) {
+		for _, roleService := range nodegroupConfig.RoleServices {
+			if roleService.RoleID == roleID {
+				result = append(result, roleService.ServiceIDs...)
+			}
+		}
+	}
+	return result
 }
 
 // GetRoleCardinality is a utility function that fetches the cardinality value
@@ -147,35 +263,96 @@ func PortsForRole(
 
 	var result []ServicePortInfo
 
-	// Match the role in the roleService and based on that fetch the service
-	// endpoint ports matching the service IDs.
-	for _, roleService := range appCR.Spec.Config.RoleServices {
-		if roleService.RoleID == role {
-			for _, service := range appCR.Spec.Services {
-				if shared.StringInList(service.ID, roleService.ServiceIDs) {
-					if service.Endpoint.Port != nil {
-						servicePortInfo := ServicePortInfo{
-							ID:        service.ID,
-							Port:      *(service.Endpoint.Port),
-							URLScheme: service.Endpoint.URLScheme,
-						}
-						result = append(result, servicePortInfo)
-					}
+	// Find the services on the role and fetch the service endpoint ports
+	// matching those service IDs.
+	serviceIDs := roleServiceIDs(cr, appCR, role)
+	for _, service := range appCR.Spec.Services {
+		if shared.StringInList(service.ID, serviceIDs) {
+			if service.Endpoint.Port != nil {
+				servicePortInfo := ServicePortInfo{
+					ID:          service.ID,
+					Port:        *(service.Endpoint.Port),
+					URLScheme:   service.Endpoint.URLScheme,
+					Exposure:    service.Endpoint.Exposure,
+					RoleService: service.Endpoint.HasRoleService,
+					IsDashboard: service.Endpoint.IsDashboard,
+					Path:        service.Endpoint.Path,
+					Metrics:     service.Endpoint.Metrics,
+				}
+				if servicePortInfo.Exposure == "" {
+					servicePortInfo.Exposure = kdv1.ServiceExposureExternal
 				}
+				result = append(result, servicePortInfo)
 			}
-			break
 		}
 	}
 
 	return result, nil
 }
 
-// ImageForRole returns the image to be used for pods in a given role.
+// ProbesForRole returns the endpoints of the services on a given role that
+// declare a readiness probe and a liveness probe, respectively. Either may be
+// nil. (The validator has already checked that at most one service on any
+// role declares each kind of probe.)
+func ProbesForRole(
+	cr *kdv1.KubeDirectorCluster,
+	role string,
+) (*kdv1.ServiceEndpoint, *kdv1.ServiceEndpoint, error) {
+
+	appCR, err := GetApp(cr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var readiness *kdv1.ServiceEndpoint
+	var liveness *kdv1.ServiceEndpoint
+	serviceIDs := roleServiceIDs(cr, appCR, role)
+	for i := range appCR.Spec.Services {
+		service := &(appCR.Spec.Services[i])
+		if !shared.StringInList(service.ID, serviceIDs) {
+			continue
+		}
+		if (readiness == nil) && (service.Endpoint.ReadinessProbe != nil) {
+			readiness = &(service.Endpoint)
+		}
+		if (liveness == nil) && (service.Endpoint.LivenessProbe != nil) {
+			liveness = &(service.Endpoint)
+		}
+	}
+
+	return readiness, liveness, nil
+}
+
+// ImageAllowedForRole checks whether the given image matches one of the
+// allowedImages patterns of the given app role.
+func ImageAllowedForRole(
+	nodeRole *kdv1.NodeRole,
+	image string,
+) bool {
+
+	for _, pattern := range nodeRole.AllowedImages {
+		if matched, _ := path.Match(pattern, image); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// ImageForRole returns the image to be used for pods in a given role. This
+// is the image override from the kdcluster role if any, otherwise the image
+// declared by the app role. (The validator has already checked that any
+// override is allowed by the app.)
 func ImageForRole(
 	cr *kdv1.KubeDirectorCluster,
 	role string,
 ) (string, error) {
 
+	for _, clusterRole := range cr.Spec.Roles {
+		if (clusterRole.Name == role) && (clusterRole.ImageRepoTag != nil) {
+			return *(clusterRole.ImageRepoTag), nil
+		}
+	}
+
 	// Fetch the app type definition if we haven't yet cached it in this
 	// handler pass.
 	appCR, err := GetApp(cr)
@@ -337,6 +514,147 @@ func RoleContainerSpecs(
 	return nil, nil
 }
 
+// AppPersistVolumes fetches the named volumes that the app declares for
+// holding some of a given role's persisted directories.
+func AppPersistVolumes(
+	cr *kdv1.KubeDirectorCluster,
+	role string,
+) ([]kdv1.PersistVolume, error) {
+
+	appCR, err := GetApp(cr)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, nodeRole := range appCR.Spec.NodeRoles {
+		if nodeRole.ID == role {
+			return nodeRole.PersistVolumes, nil
+		}
+	}
+
+	// Should never reach here.
+	return nil, fmt.Errorf(
+		"Role {%s} not found for app {%s} when searching for persist volumes",
+		role,
+		cr.Spec.AppID,
+	)
+}
+
+// RoleSidecars fetches the sidecar containers declared by the KDApp for the
+// given role.
+func RoleSidecars(
+	cr *kdv1.KubeDirectorCluster,
+	role string,
+) ([]kdv1.Sidecar, error) {
+
+	appCR, err := GetApp(cr)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, nodeRole := range appCR.Spec.NodeRoles {
+		if role == nodeRole.ID {
+			return nodeRole.Sidecars, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// RoleDisruptionBudget returns the disruption budget declared by the app
+// for the given role, or nil if there is none.
+func RoleDisruptionBudget(
+	cr *kdv1.KubeDirectorCluster,
+	role string,
+) (*kdv1.DisruptionBudget, error) {
+
+	appCR, err := GetApp(cr)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, nodeRole := range appCR.Spec.NodeRoles {
+		if role == nodeRole.ID {
+			return nodeRole.DisruptionBudget, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// RoleSecurityContext returns the security context for the given role,
+// combining the app role's security context with that of the cluster role.
+// Properties set for the cluster role take precedence, and the lists of
+// dropped capabilities are merged. The result is nil if neither sets a
+// security context.
+func RoleSecurityContext(
+	cr *kdv1.KubeDirectorCluster,
+	role string,
+) (*kdv1.SecurityContext, error) {
+
+	appCR, err := GetApp(cr)
+	if err != nil {
+		return nil, err
+	}
+
+	var result *kdv1.SecurityContext
+	for _, nodeRole := range appCR.Spec.NodeRoles {
+		if (role == nodeRole.ID) && (nodeRole.SecurityContext != nil) {
+			result = nodeRole.SecurityContext.DeepCopy()
+		}
+	}
+	for _, clusterRole := range cr.Spec.Roles {
+		if (role != clusterRole.Name) || (clusterRole.SecurityContext == nil) {
+			continue
+		}
+		override := clusterRole.SecurityContext.DeepCopy()
+		if result == nil {
+			result = override
+			continue
+		}
+		if override.RunAsUser != nil {
+			result.RunAsUser = override.RunAsUser
+		}
+		if override.RunAsGroup != nil {
+			result.RunAsGroup = override.RunAsGroup
+		}
+		if override.RunAsNonRoot != nil {
+			result.RunAsNonRoot = override.RunAsNonRoot
+		}
+		if override.FSGroup != nil {
+			result.FSGroup = override.FSGroup
+		}
+		if override.SeccompProfile != nil {
+			result.SeccompProfile = override.SeccompProfile
+		}
+		if override.ReadOnlyRootFilesystem != nil {
+			result.ReadOnlyRootFilesystem = override.ReadOnlyRootFilesystem
+		}
+		for _, capability := range override.DropCapabilities {
+			if !capabilityInList(capability, result.DropCapabilities) {
+				result.DropCapabilities = append(result.DropCapabilities, capability)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// capabilityInList is a utility function that checks if a capability
+// exists in a given list.
+func capabilityInList(
+	capability v1.Capability,
+	list []v1.Capability,
+) bool {
+
+	for _, c := range list {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
 // FindApp returns the app type definition for the given virtual cluster. If
 // the appCatalog property is set to "local", it looks in the same namespace
 // as the cluster. If set to "system", it looks in the same namespace as
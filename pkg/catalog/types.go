@@ -14,6 +14,10 @@
 
 package catalog
 
+import (
+	kdv1 "github.com/bluek8s/kubedirector/pkg/apis/kubedirector/v1beta1"
+)
+
 // configmeta is a representation of a virtual cluster config, based on both
 // the app type definition and the deploy-time spec provided in the cluster
 // CR. It is arranged in a format to be consumed by the app setup Python
@@ -98,9 +102,28 @@ type flavor struct {
 	Cores       string `json:"cores"`
 }
 
-// ServicePortInfo - A mapping between a Service Port ID and the port number
+// NodegroupConfig describes one nodegroup of a virtual cluster: the roles
+// it selects, the services on those roles, and its config metadata. These
+// come from either the top-level config of the app or the selection chosen
+// for one of its config choices.
+type NodegroupConfig struct {
+	ID             string
+	SelectedRoles  []string
+	RoleServices   []kdv1.RoleService
+	ConfigMetadata map[string]string
+}
+
+// ServicePortInfo - A mapping between a Service Port ID and the port number.
+// Exposure is one of the ServiceExposure* constants (never empty), and
+// RoleService is set if the port should also be on the role-wide service.
+// IsDashboard, Path, and Metrics come from the service endpoint.
 type ServicePortInfo struct {
-	ID        string
-	Port      int32
-	URLScheme string
+	ID          string
+	Port        int32
+	URLScheme   string
+	Exposure    string
+	RoleService bool
+	IsDashboard bool
+	Path        string
+	Metrics     *kdv1.ServiceMetrics
 }
@@ -0,0 +1,512 @@
+// Copyright 2022 Hewlett Packard Enterprise Development LP
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubedirectorbackup
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	kdv1 "github.com/bluek8s/kubedirector/pkg/apis/kubedirector/v1beta1"
+	"github.com/bluek8s/kubedirector/pkg/catalog"
+	"github.com/bluek8s/kubedirector/pkg/controller/kubedirectorcluster"
+	"github.com/bluek8s/kubedirector/pkg/executor"
+	"github.com/bluek8s/kubedirector/pkg/observer"
+	"github.com/bluek8s/kubedirector/pkg/shared"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	// quiesceTimeout is the longest that members are kept quiesced while
+	// waiting for their volume snapshots to be taken.
+	quiesceTimeout = 10 * time.Minute
+
+	preBackupEvent  = "prebackup"
+	postBackupEvent = "postbackup"
+)
+
+// syncBackup runs the reconciliation logic. It is invoked because of a
+// change in or addition of a KubeDirectorBackup instance, or a periodic
+// polling to check on such a resource. The returned boolean is true if the
+// backup has reached a final state and does not need to be polled anymore.
+//
+// A backup waits until its kdcluster is stable, then sends the prebackup
+// event to all members, snapshots every member PVC, and sends the postbackup
+// event once all of the snapshots have been taken. When the snapshots are
+// ready for use, the backup is recorded in the kdcluster's status backup so
+// that a restore of the kdcluster can restore the member PVCs from them. The
+// backup holds a finalizer while members are quiesced, so that deleting it
+// at that point still resumes the members.
+func (r *ReconcileKubeDirectorBackup) syncBackup(
+	reqLogger logr.Logger,
+	cr *kdv1.KubeDirectorBackup,
+) (done bool, err error) {
+
+	// Memoize state of the incoming object.
+	oldStatus := cr.Status.DeepCopy()
+
+	// Make sure we have a Status object to work with.
+	if cr.Status == nil {
+		cr.Status = &kdv1.KubeDirectorBackupStatus{
+			State: kdv1.BackupStatePending,
+		}
+	}
+
+	// Set a defer func to write new status if it changes, and to drop the
+	// finalizer once members are no longer quiesced. If either write fails
+	// the reconciler will requeue the backup and we'll try again.
+	defer func() {
+		if !equality.Semantic.DeepEqual(cr.Status, oldStatus) {
+			updateErr := shared.StatusUpdate(context.TODO(), cr)
+			if updateErr != nil {
+				shared.LogErrorf(
+					reqLogger,
+					updateErr,
+					cr,
+					shared.EventReasonBackup,
+					"failed to update status",
+				)
+				if err == nil {
+					err = updateErr
+				}
+				return
+			}
+		}
+		if (cr.Status.State != kdv1.BackupStateSnapshotting) && shared.HasFinalizer(cr) {
+			finalizerErr := setFinalizer(cr, false)
+			if (finalizerErr != nil) && (err == nil) {
+				err = finalizerErr
+			}
+		}
+	}()
+
+	if cr.DeletionTimestamp != nil {
+		if cr.Status.State == kdv1.BackupStateSnapshotting {
+			cluster, clusterErr := observer.GetCluster(cr.Namespace, cr.Spec.Cluster)
+			if clusterErr == nil {
+				sendBackupEvent(reqLogger, cr, cluster, postBackupEvent)
+			}
+			failBackup(reqLogger, cr, "backup deleted before snapshots were taken")
+		}
+		return true, nil
+	}
+
+	if (cr.Status.State == kdv1.BackupStateCompleted) ||
+		(cr.Status.State == kdv1.BackupStateFailed) {
+		return true, nil
+	}
+
+	cluster, clusterErr := observer.GetCluster(cr.Namespace, cr.Spec.Cluster)
+	if clusterErr != nil {
+		if errors.IsNotFound(clusterErr) {
+			failBackup(reqLogger, cr, fmt.Sprintf("kdcluster{%s} not found", cr.Spec.Cluster))
+			return true, nil
+		}
+		return false, clusterErr
+	}
+
+	switch cr.Status.State {
+	case kdv1.BackupStatePending:
+		return startBackup(reqLogger, cr, cluster)
+	case kdv1.BackupStateSnapshotting:
+		return takeSnapshots(reqLogger, cr, cluster)
+	case kdv1.BackupStateFinishing:
+		return finishBackup(reqLogger, cr, cluster)
+	}
+	return true, nil
+}
+
+// startBackup waits for the kdcluster to be stable, then quiesces its
+// members and starts snapshotting their PVCs.
+func startBackup(
+	reqLogger logr.Logger,
+	cr *kdv1.KubeDirectorBackup,
+	cluster *kdv1.KubeDirectorCluster,
+) (bool, error) {
+
+	if busy := kubedirectorcluster.ClusterBusyReason(cluster, true); busy != "" {
+		cr.Status.Message = busy
+		return false, nil
+	}
+
+	var snapshots []kdv1.BackupSnapshot
+	for _, roleStatus := range cluster.Status.Roles {
+		for i := range roleStatus.Members {
+			member := &roleStatus.Members[i]
+			for _, pvc := range executor.MemberPVCs(member) {
+				snapshots = append(
+					snapshots,
+					kdv1.BackupSnapshot{
+						Role:           roleStatus.Name,
+						Member:         member.Pod,
+						PVC:            pvc,
+						VolumeSnapshot: cr.Name + "-" + pvc,
+					},
+				)
+			}
+		}
+	}
+	if len(snapshots) == 0 {
+		failBackup(
+			reqLogger,
+			cr,
+			fmt.Sprintf("kdcluster{%s} has no persistent storage", cr.Spec.Cluster),
+		)
+		return true, nil
+	}
+
+	// Hold the finalizer before quiescing anything.
+	if !shared.HasFinalizer(cr) {
+		finalizerErr := setFinalizer(cr, true)
+		if finalizerErr != nil {
+			return false, finalizerErr
+		}
+	}
+
+	shared.LogInfof(
+		reqLogger,
+		cr,
+		shared.EventReasonBackup,
+		"quiescing members of kdcluster{%s}",
+		cr.Spec.Cluster,
+	)
+	now := metav1.Now()
+	cr.Status.StartTime = &now
+	cr.Status.Snapshots = snapshots
+	cr.Status.State = kdv1.BackupStateSnapshotting
+	if failed := sendBackupEvent(reqLogger, cr, cluster, preBackupEvent); len(failed) != 0 {
+		sendBackupEvent(reqLogger, cr, cluster, postBackupEvent)
+		failBackup(
+			reqLogger,
+			cr,
+			fmt.Sprintf("%s event failed for members: %s", preBackupEvent, strings.Join(failed, ",")),
+		)
+		return true, nil
+	}
+	return takeSnapshots(reqLogger, cr, cluster)
+}
+
+// takeSnapshots creates any volume snapshots not yet created, and once all
+// of them have been taken, resumes the kdcluster members.
+func takeSnapshots(
+	reqLogger logr.Logger,
+	cr *kdv1.KubeDirectorBackup,
+	cluster *kdv1.KubeDirectorCluster,
+) (bool, error) {
+
+	allTaken := true
+	for i := range cr.Status.Snapshots {
+		snapshot := &(cr.Status.Snapshots[i])
+		volumeSnapshot, getErr := observer.GetVolumeSnapshot(cr.Namespace, snapshot.VolumeSnapshot)
+		if getErr != nil {
+			if !errors.IsNotFound(getErr) {
+				return false, getErr
+			}
+			createErr := executor.CreateVolumeSnapshot(cr, snapshot.VolumeSnapshot, snapshot.PVC)
+			if createErr != nil {
+				shared.LogErrorf(
+					reqLogger,
+					createErr,
+					cr,
+					shared.EventReasonBackup,
+					"failed to create VolumeSnapshot{%s} of PVC{%s}",
+					snapshot.VolumeSnapshot,
+					snapshot.PVC,
+				)
+				return false, createErr
+			}
+			shared.LogInfof(
+				reqLogger,
+				cr,
+				shared.EventReasonBackup,
+				"created VolumeSnapshot{%s} of PVC{%s}",
+				snapshot.VolumeSnapshot,
+				snapshot.PVC,
+			)
+			allTaken = false
+			continue
+		}
+		if snapshotErr := snapshotError(volumeSnapshot); snapshotErr != "" {
+			sendBackupEvent(reqLogger, cr, cluster, postBackupEvent)
+			failBackup(
+				reqLogger,
+				cr,
+				fmt.Sprintf("VolumeSnapshot{%s} failed: %s", snapshot.VolumeSnapshot, snapshotErr),
+			)
+			return true, nil
+		}
+		snapshot.ReadyToUse = snapshotReady(volumeSnapshot)
+		if !snapshot.ReadyToUse && !snapshotTaken(volumeSnapshot) {
+			allTaken = false
+		}
+	}
+
+	if !allTaken {
+		if time.Since(cr.Status.StartTime.Time) > quiesceTimeout {
+			sendBackupEvent(reqLogger, cr, cluster, postBackupEvent)
+			failBackup(
+				reqLogger,
+				cr,
+				fmt.Sprintf("volume snapshots were not taken within %v", quiesceTimeout),
+			)
+			return true, nil
+		}
+		cr.Status.Message = "waiting for volume snapshots to be taken"
+		return false, nil
+	}
+
+	shared.LogInfof(
+		reqLogger,
+		cr,
+		shared.EventReasonBackup,
+		"resuming members of kdcluster{%s}",
+		cr.Spec.Cluster,
+	)
+	cr.Status.State = kdv1.BackupStateFinishing
+	cr.Status.Message = ""
+	if failed := sendBackupEvent(reqLogger, cr, cluster, postBackupEvent); len(failed) != 0 {
+		cr.Status.Message = fmt.Sprintf(
+			"%s event failed for members: %s",
+			postBackupEvent,
+			strings.Join(failed, ","),
+		)
+	}
+	return finishBackup(reqLogger, cr, cluster)
+}
+
+// finishBackup waits for all of the volume snapshots to be ready for use,
+// then records the backup in the kdcluster's status backup.
+func finishBackup(
+	reqLogger logr.Logger,
+	cr *kdv1.KubeDirectorBackup,
+	cluster *kdv1.KubeDirectorCluster,
+) (bool, error) {
+
+	allReady := true
+	for i := range cr.Status.Snapshots {
+		snapshot := &(cr.Status.Snapshots[i])
+		if snapshot.ReadyToUse {
+			continue
+		}
+		volumeSnapshot, getErr := observer.GetVolumeSnapshot(cr.Namespace, snapshot.VolumeSnapshot)
+		if getErr != nil {
+			if errors.IsNotFound(getErr) {
+				failBackup(
+					reqLogger,
+					cr,
+					fmt.Sprintf("VolumeSnapshot{%s} not found", snapshot.VolumeSnapshot),
+				)
+				return true, nil
+			}
+			return false, getErr
+		}
+		if snapshotErr := snapshotError(volumeSnapshot); snapshotErr != "" {
+			failBackup(
+				reqLogger,
+				cr,
+				fmt.Sprintf("VolumeSnapshot{%s} failed: %s", snapshot.VolumeSnapshot, snapshotErr),
+			)
+			return true, nil
+		}
+		snapshot.ReadyToUse = snapshotReady(volumeSnapshot)
+		if !snapshot.ReadyToUse {
+			allReady = false
+		}
+	}
+	if !allReady {
+		return false, nil
+	}
+
+	now := metav1.Now()
+	cr.Status.CompletionTime = &now
+	statusBackup, statusBackupErr := observer.GetStatusBackup(cr.Namespace, cluster.Name)
+	if statusBackupErr == nil {
+		recordErr := executor.UpdateStatusBackupDataBackup(statusBackup, cr)
+		if recordErr != nil {
+			cr.Status.CompletionTime = nil
+			return false, recordErr
+		}
+	} else if errors.IsNotFound(statusBackupErr) {
+		cr.Status.Message = "kdstatusbackup not found; a restore of the kdcluster will not use these snapshots"
+	} else {
+		cr.Status.CompletionTime = nil
+		return false, statusBackupErr
+	}
+	shared.LogInfof(
+		reqLogger,
+		cr,
+		shared.EventReasonBackup,
+		"backup of kdcluster{%s} completed",
+		cr.Spec.Cluster,
+	)
+	cr.Status.State = kdv1.BackupStateCompleted
+	return true, nil
+}
+
+// failBackup moves the backup to the failed state with the given reason.
+func failBackup(
+	reqLogger logr.Logger,
+	cr *kdv1.KubeDirectorBackup,
+	reason string,
+) {
+
+	shared.LogInfof(
+		reqLogger,
+		cr,
+		shared.EventReasonBackup,
+		"backup failed: %s",
+		reason,
+	)
+	cr.Status.State = kdv1.BackupStateFailed
+	cr.Status.Message = reason
+}
+
+// setFinalizer adds or removes the KubeDirector finalizer on the backup.
+// The write is done on a copy so that the in-memory status is not replaced.
+func setFinalizer(
+	cr *kdv1.KubeDirectorBackup,
+	present bool,
+) error {
+
+	patched := cr.DeepCopy()
+	if present {
+		shared.EnsureFinalizer(patched)
+	} else {
+		shared.RemoveFinalizer(patched)
+	}
+	updateErr := shared.Update(context.TODO(), patched)
+	if updateErr == nil {
+		cr.Finalizers = patched.Finalizers
+		cr.ResourceVersion = patched.ResourceVersion
+	}
+	return updateErr
+}
+
+// sendBackupEvent runs the startscript with the given event in every
+// configured member of the kdcluster whose role has registered for that event. Apps
+// that predate the backup events would not recognize them, so they are only
+// sent if explicitly registered. The names of any members where the event
+// failed are returned.
+func sendBackupEvent(
+	reqLogger logr.Logger,
+	cr *kdv1.KubeDirectorBackup,
+	cluster *kdv1.KubeDirectorCluster,
+	event string,
+) []string {
+
+	appCR, appErr := catalog.GetApp(cluster)
+	if appErr != nil {
+		shared.LogErrorf(
+			reqLogger,
+			appErr,
+			cr,
+			shared.EventReasonBackup,
+			"failed to find kdapp{%s} for %s event",
+			cluster.Spec.AppID,
+			event,
+		)
+		return []string{cluster.Name}
+	}
+
+	var targets []*kdv1.MemberStatus
+	for i := range cluster.Status.Roles {
+		roleStatus := &(cluster.Status.Roles[i])
+		appRole := catalog.GetRoleFromID(appCR, roleStatus.Name)
+		if (appRole == nil) || (appRole.EventList == nil) ||
+			!shared.StringInList(event, *appRole.EventList) {
+			continue
+		}
+		for j := range roleStatus.Members {
+			member := &(roleStatus.Members[j])
+			if member.StateDetail.LastConfiguredContainer != "" {
+				targets = append(targets, member)
+			}
+		}
+	}
+
+	var failed []string
+	var failedLock sync.Mutex
+	var wg sync.WaitGroup
+	wg.Add(len(targets))
+	for _, member := range targets {
+		go func(m *kdv1.MemberStatus) {
+			defer wg.Done()
+			cmd := shared.AppStartscript + " --" + event
+			cmdErr := executor.RunScript(
+				reqLogger,
+				cr,
+				cluster.Namespace,
+				m.Pod,
+				m.StateDetail.LastConfiguredContainer,
+				executor.AppContainerName,
+				"app "+event,
+				strings.NewReader(cmd),
+			)
+			if cmdErr != nil {
+				shared.LogErrorf(
+					reqLogger,
+					cmdErr,
+					cr,
+					shared.EventReasonBackup,
+					"%s event failed for member{%s}",
+					event,
+					m.Pod,
+				)
+				failedLock.Lock()
+				failed = append(failed, m.Pod)
+				failedLock.Unlock()
+			}
+		}(member)
+	}
+	wg.Wait()
+	return failed
+}
+
+// snapshotTaken returns true if the point-in-time snapshot has been cut,
+// even if it is not yet ready for use.
+func snapshotTaken(
+	volumeSnapshot *unstructured.Unstructured,
+) bool {
+
+	creationTime, _, _ := unstructured.NestedString(volumeSnapshot.Object, "status", "creationTime")
+	return creationTime != ""
+}
+
+// snapshotReady returns true if the snapshot is ready to be restored from.
+func snapshotReady(
+	volumeSnapshot *unstructured.Unstructured,
+) bool {
+
+	readyToUse, _, _ := unstructured.NestedBool(volumeSnapshot.Object, "status", "readyToUse")
+	return readyToUse
+}
+
+// snapshotError returns the error message reported for the snapshot, if any.
+func snapshotError(
+	volumeSnapshot *unstructured.Unstructured,
+) string {
+
+	message, found, _ := unstructured.NestedString(volumeSnapshot.Object, "status", "error", "message")
+	if found && (message == "") {
+		message = "unknown error"
+	}
+	return message
+}
@@ -0,0 +1,74 @@
+// Copyright 2022 Hewlett Packard Enterprise Development LP
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubedirectorcluster
+
+import (
+	kdv1 "github.com/bluek8s/kubedirector/pkg/apis/kubedirector/v1beta1"
+)
+
+// ClusterBusyReason returns a description of why the kdcluster is not ready
+// for another controller (e.g. the role scaler or the backup controller) to
+// act on it, or an empty string if it is. A kdcluster is busy while a spec
+// change is pending or its configmeta is still being delivered to members,
+// while it is suspended, being restored, or upgrading, and while members
+// are being added, removed, restarted, or notified. If requireRunning is
+// true, it is also busy while any member is down, initializing, or in a
+// config error state.
+func ClusterBusyReason(
+	cluster *kdv1.KubeDirectorCluster,
+	requireRunning bool,
+) string {
+
+	if cluster.Status == nil {
+		return "kdcluster has not been processed yet"
+	}
+	if cluster.Status.State == ClusterSpecModified {
+		return "a kdcluster spec change is still being processed"
+	}
+	if (cluster.Spec.Suspend != nil) && *(cluster.Spec.Suspend) {
+		return "kdcluster is suspended"
+	}
+	if cluster.Status.RestoreProgress != nil {
+		return "kdcluster is being restored"
+	}
+	if cluster.Status.UpgradeProgress != nil {
+		return "kdcluster app upgrade is in progress"
+	}
+	rollup := cluster.Status.MemberStateRollup
+	if rollup.MembershipChanging || rollup.MembersRestarting {
+		return "kdcluster members are still being created or deleted"
+	}
+	if requireRunning &&
+		(rollup.MembersDown || rollup.MembersInitializing || rollup.ConfigErrors) {
+		return "kdcluster members are not all running and configured"
+	}
+	for _, roleStatus := range cluster.Status.Roles {
+		for _, memberStatus := range roleStatus.Members {
+			if len(memberStatus.StateDetail.PendingNotifyCmds) != 0 {
+				return "kdcluster members have pending notifications"
+			}
+			// Ready members that have configmeta must have the configmeta
+			// for the latest processed spec.
+			lastGeneration := memberStatus.StateDetail.LastConfigDataGeneration
+			if (memberStatus.State == string(memberReady)) &&
+				(lastGeneration != nil) &&
+				(cluster.Status.SpecGenerationToProcess != nil) &&
+				(*lastGeneration != *cluster.Status.SpecGenerationToProcess) {
+				return "a kdcluster spec change is still being processed"
+			}
+		}
+	}
+	return ""
+}
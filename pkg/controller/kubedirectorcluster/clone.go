@@ -0,0 +1,228 @@
+// Copyright 2022 Hewlett Packard Enterprise Development LP
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubedirectorcluster
+
+import (
+	"strings"
+
+	"github.com/go-logr/logr"
+
+	kdv1 "github.com/bluek8s/kubedirector/pkg/apis/kubedirector/v1beta1"
+	"github.com/bluek8s/kubedirector/pkg/executor"
+	"github.com/bluek8s/kubedirector/pkg/observer"
+	"github.com/bluek8s/kubedirector/pkg/shared"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// memberOrdinal returns the statefulset ordinal suffix of a member's pod
+// name.
+func memberOrdinal(
+	podName string,
+) string {
+
+	return podName[strings.LastIndex(podName, "-")+1:]
+}
+
+// cloneDataSource returns the data source to populate a new member's PVC
+// from, given the PVC of the corresponding member in the source cluster.
+func cloneDataSource(
+	cloneFrom *kdv1.CloneSource,
+	sourcePVC string,
+) *corev1.TypedLocalObjectReference {
+
+	if cloneFrom.VolumeSnapshotPrefix == nil {
+		return &corev1.TypedLocalObjectReference{
+			Kind: "PersistentVolumeClaim",
+			Name: sourcePVC,
+		}
+	}
+	return volumeSnapshotDataSource(*cloneFrom.VolumeSnapshotPrefix + "-" + sourcePVC)
+}
+
+// volumeSnapshotDataSource returns a PVC data source referencing the CSI
+// VolumeSnapshot with the given name.
+func volumeSnapshotDataSource(
+	snapshotName string,
+) *corev1.TypedLocalObjectReference {
+
+	apiGroup := shared.VolumeSnapshotGroup
+	return &corev1.TypedLocalObjectReference{
+		APIGroup: &apiGroup,
+		Kind:     shared.VolumeSnapshotKind,
+		Name:     snapshotName,
+	}
+}
+
+// seedClonedPVCs creates the PVCs for the create pending members of a role
+// in a cluster being cloned, before the statefulset gets around to creating
+// them. Each PVC is populated from the PVC (or a snapshot of the PVC) of the
+// member with the same role and ordinal in the source cluster, and the
+// source member is recorded in the member's state detail. Members with no
+// counterpart in the source cluster get fresh storage as usual. Since the
+// cloned storage was already initialized in the source member, the init
+// container will skip copying the persisted directories into it.
+//
+// Only the members created along with the cluster are cloned; members added
+// to the cluster later get fresh storage. The return value is false if
+// member creation should wait for a later handler pass.
+func seedClonedPVCs(
+	reqLogger logr.Logger,
+	cr *kdv1.KubeDirectorCluster,
+	role *roleInfo,
+) bool {
+
+	if (cr.Spec.CloneFrom == nil) || (cr.Status.State != string(clusterCreating)) {
+		return true
+	}
+	var toSeed []*kdv1.MemberStatus
+	for _, m := range role.membersByState[memberCreatePending] {
+		if (m.PVC != "") && (m.StateDetail.CloneSource == "") &&
+			(m.StateDetail.LastConfiguredContainer == "") {
+			toSeed = append(toSeed, m)
+		}
+	}
+	if len(toSeed) == 0 {
+		return true
+	}
+
+	sourceName := cr.Spec.CloneFrom.Cluster
+	source, sourceErr := observer.GetCluster(cr.Namespace, sourceName)
+	if sourceErr != nil {
+		if apierrors.IsNotFound(sourceErr) {
+			// Nothing to clone from anymore, so let the members be created
+			// with fresh storage.
+			shared.LogInfof(
+				reqLogger,
+				cr,
+				shared.EventReasonCluster,
+				"source kdcluster{%s} for clone not found; members of role{%s} will not be cloned",
+				sourceName,
+				role.roleStatus.Name,
+			)
+			return true
+		}
+		shared.LogErrorf(
+			reqLogger,
+			sourceErr,
+			cr,
+			shared.EventReasonCluster,
+			"failed to find source kdcluster{%s} for clone",
+			sourceName,
+		)
+		return false
+	}
+
+	// Index the source members of this role by ordinal.
+	sourceMembers := make(map[string]*kdv1.MemberStatus)
+	if source.Status != nil {
+		for i := range source.Status.Roles {
+			sourceRole := &source.Status.Roles[i]
+			if sourceRole.Name != role.roleStatus.Name {
+				continue
+			}
+			for j := range sourceRole.Members {
+				sourceMember := &sourceRole.Members[j]
+				if sourceMember.PVC != "" {
+					sourceMembers[memberOrdinal(sourceMember.Pod)] = sourceMember
+				}
+			}
+		}
+	}
+
+	allSeeded := true
+	for _, m := range toSeed {
+		sourceMember, ok := sourceMembers[memberOrdinal(m.Pod)]
+		if !ok {
+			continue
+		}
+		_, pvcGetErr := observer.GetPVC(cr.Namespace, m.PVC)
+		if pvcGetErr == nil {
+			// Too late to clone into this one.
+			shared.LogInfof(
+				reqLogger,
+				cr,
+				shared.EventReasonMember,
+				"PVC{%s} for member{%s} already exists; member will not be cloned",
+				m.PVC,
+				m.Pod,
+			)
+			continue
+		}
+		if !apierrors.IsNotFound(pvcGetErr) {
+			shared.LogErrorf(
+				reqLogger,
+				pvcGetErr,
+				cr,
+				shared.EventReasonMember,
+				"failed to find PVC{%s}",
+				m.PVC,
+			)
+			allSeeded = false
+			continue
+		}
+		// Clone each of the member's volumes that the source member also
+		// has. The main volume goes last, since its existence is checked
+		// above; a named volume PVC may already exist if an earlier pass
+		// was interrupted partway through the member's volumes.
+		sourcePVCs := executor.MemberPVCs(sourceMember)
+		claimNames := executor.StorageClaimNames(role.roleSpec)
+		memberSeeded := true
+		for i := len(claimNames) - 1; i >= 0; i-- {
+			claimName := claimNames[i]
+			sourcePVC := executor.MemberPVCName(claimName, sourceMember.Pod)
+			if !shared.StringInList(sourcePVC, sourcePVCs) {
+				continue
+			}
+			pvcName := executor.MemberPVCName(claimName, m.Pod)
+			createErr := executor.CreatePVCFromDataSource(
+				cr,
+				role.roleSpec,
+				claimName,
+				m.Pod,
+				cloneDataSource(cr.Spec.CloneFrom, sourcePVC),
+			)
+			if (createErr != nil) && !apierrors.IsAlreadyExists(createErr) {
+				shared.LogErrorf(
+					reqLogger,
+					createErr,
+					cr,
+					shared.EventReasonMember,
+					"failed to create PVC{%s} cloned from member{%s}",
+					pvcName,
+					sourceMember.Pod,
+				)
+				memberSeeded = false
+				continue
+			}
+			shared.LogInfof(
+				reqLogger,
+				cr,
+				shared.EventReasonMember,
+				"created PVC{%s} for member{%s} cloned from member{%s} of kdcluster{%s}",
+				pvcName,
+				m.Pod,
+				sourceMember.Pod,
+				sourceName,
+			)
+		}
+		if !memberSeeded {
+			allSeeded = false
+			continue
+		}
+		m.StateDetail.CloneSource = sourceMember.Pod
+	}
+	return allSeeded
+}
@@ -82,6 +82,10 @@ func (r *ReconcileKubeDirectorCluster) syncCluster(
 		statusBackupShouldExist = shared.GetBackupClusterStatus()
 	}
 
+	// The hash of connected resources is calculated below, but the defer
+	// func needs it too (for the ConnectionsSynced condition).
+	var currentHash string
+
 	// Set a defer func to write new status and/or finalizers if they change.
 	defer func() {
 		fetchBackup()
@@ -91,6 +95,7 @@ func (r *ReconcileKubeDirectorCluster) syncCluster(
 		executor.UpdateClusterStatusBackupOwner(reqLogger, cr, statusBackup)
 		syncMemberNotifies(reqLogger, cr)
 		updateStateRollup(cr)
+		updateConditions(cr, currentHash)
 		nowHasFinalizer := shared.HasFinalizer(cr)
 		// Now see if anything has changed that we need to fix or update.
 		statusChanged := false
@@ -226,7 +231,7 @@ func (r *ReconcileKubeDirectorCluster) syncCluster(
 	cr.Status.RestoreProgress = nil
 
 	// Calculate md5check sum to generate unique hash for connection object
-	currentHash := calcConnectionsHash(&cr.Spec.Connections, cr.Namespace)
+	currentHash = calcConnectionsHash(&cr.Spec.Connections, cr.Namespace)
 
 	// We use a finalizer to maintain KubeDirector state consistency;
 	// e.g. app references and ClusterStatusGens.
@@ -260,6 +265,19 @@ func (r *ReconcileKubeDirectorCluster) syncCluster(
 		)
 	}
 
+	// While suspended (or being suspended) the cluster's members are gone,
+	// so the usual reconciliation is skipped.
+	if suspendRequested(cr) {
+		suspended, suspendErr := handleSuspend(reqLogger, cr)
+		if suspendErr != nil {
+			errLog("suspend", suspendErr)
+			return suspendErr
+		}
+		if suspended {
+			return nil
+		}
+	}
+
 	checkContainerStates(reqLogger, cr)
 
 	clusterServiceErr := syncClusterService(reqLogger, cr)
@@ -313,11 +331,19 @@ func (r *ReconcileKubeDirectorCluster) syncCluster(
 		cr.Status.LastConnectionHash = currentHash
 	}
 
+	// Once all members of a resumed cluster are back, send them fresh
+	// configmeta before declaring the cluster ready again.
+	if (state == clusterMembersStableReady) && (cr.Status.SuspendedTime != nil) {
+		handleResumed(reqLogger, cr)
+		state = clusterMembersStableUnready
+	}
+
 	memberServicesErr := syncMemberServices(reqLogger, cr, roles)
 	if memberServicesErr != nil {
 		errLog("member services", memberServicesErr)
 		return memberServicesErr
 	}
+	syncIstioRouting(reqLogger, cr, roles)
 
 	if state == clusterMembersStableReady {
 		if cr.Status.State != string(clusterReady) {
@@ -328,75 +354,9 @@ func (r *ReconcileKubeDirectorCluster) syncCluster(
 				"stable",
 			)
 
-			amIBeingConnectedToThis := func(otherCluster kdv1.KubeDirectorCluster) bool {
-				for _, connectedName := range otherCluster.Spec.Connections.Clusters {
-					if cr.Name == connectedName {
-						return true
-					}
-				}
-				return false
-			}
-
-			// Once the cluster is deemed ready, if this cluster is connected to any cluster then
-			// we need to notify that cluster that configmeta here has
-			// changed, so bump up connectionsGenerationToProcess for that cluster
-			allClusters := &kdv1.KubeDirectorClusterList{}
-			shared.List(context.TODO(), allClusters)
-			// notify clusters to which this cluster is
-			// connected
-			for _, kubecluster := range allClusters.Items {
-				if amIBeingConnectedToThis(kubecluster) {
-					shared.LogInfof(
-						reqLogger,
-						cr,
-						shared.EventReasonCluster,
-						"connected to cluster {%s}; updating it",
-						kubecluster.Name,
-					)
-					shared.LogInfof(
-						reqLogger,
-						&kubecluster,
-						shared.EventReasonCluster,
-						"connected cluster {%s} has changed",
-						cr.Name,
-					)
-					// Annotate cluster to trigger connected cluster's reconciler
-					wait := time.Second
-					maxWait := 4096 * time.Second
-					for {
-						updateMetaGenerator := &kubecluster
-						annotations := updateMetaGenerator.Annotations
-						if annotations == nil {
-							annotations = make(map[string]string)
-							updateMetaGenerator.Annotations = annotations
-						}
-						if v, ok := annotations[shared.ConnectionsIncrementor]; ok {
-							newV, _ := strconv.Atoi(v)
-							annotations[shared.ConnectionsIncrementor] = strconv.Itoa(newV + 1)
-						} else {
-							annotations[shared.ConnectionsIncrementor] = "1"
-						}
-						updateMetaGenerator.Annotations = annotations
-						if shared.Update(context.TODO(), updateMetaGenerator) == nil {
-							break
-						}
-						// Since update failed, get a fresh copy of this cluster to work with and
-						// try update
-						updateMetaGenerator, fetchErr := observer.GetCluster(kubecluster.Namespace, kubecluster.Name)
-						if fetchErr != nil {
-							if errors.IsNotFound(fetchErr) {
-								break
-							}
-						}
-						if wait > maxWait {
-							return fmt.Errorf(
-								"Unable to notify cluster {%s} of configmeta change",
-								updateMetaGenerator.Name)
-						}
-						time.Sleep(wait)
-						wait = wait * 2
-					}
-				}
+			notifyErr := notifyConnectedClusters(reqLogger, cr)
+			if notifyErr != nil {
+				return notifyErr
 			}
 			cr.Status.State = string(clusterReady)
 		}
@@ -434,6 +394,88 @@ func (r *ReconcileKubeDirectorCluster) syncCluster(
 	return nil
 }
 
+// notifyConnectedClusters lets any clusters that are connected to this one
+// know that its configmeta has changed, by bumping their connections
+// incrementor annotation. This is done when the cluster is deemed ready and
+// when it is suspended.
+func notifyConnectedClusters(
+	reqLogger logr.Logger,
+	cr *kdv1.KubeDirectorCluster,
+) error {
+
+	amIBeingConnectedToThis := func(otherCluster kdv1.KubeDirectorCluster) bool {
+		for _, connectedName := range otherCluster.Spec.Connections.Clusters {
+			if cr.Name == connectedName {
+				return true
+			}
+		}
+		return false
+	}
+
+	// If this cluster is connected to any cluster then we need to notify
+	// that cluster that configmeta here has changed, so bump up
+	// connectionsGenerationToProcess for that cluster
+	allClusters := &kdv1.KubeDirectorClusterList{}
+	shared.List(context.TODO(), allClusters)
+	// notify clusters to which this cluster is
+	// connected
+	for _, kubecluster := range allClusters.Items {
+		if amIBeingConnectedToThis(kubecluster) {
+			shared.LogInfof(
+				reqLogger,
+				cr,
+				shared.EventReasonCluster,
+				"connected to cluster {%s}; updating it",
+				kubecluster.Name,
+			)
+			shared.LogInfof(
+				reqLogger,
+				&kubecluster,
+				shared.EventReasonCluster,
+				"connected cluster {%s} has changed",
+				cr.Name,
+			)
+			// Annotate cluster to trigger connected cluster's reconciler
+			wait := time.Second
+			maxWait := 4096 * time.Second
+			for {
+				updateMetaGenerator := &kubecluster
+				annotations := updateMetaGenerator.Annotations
+				if annotations == nil {
+					annotations = make(map[string]string)
+					updateMetaGenerator.Annotations = annotations
+				}
+				if v, ok := annotations[shared.ConnectionsIncrementor]; ok {
+					newV, _ := strconv.Atoi(v)
+					annotations[shared.ConnectionsIncrementor] = strconv.Itoa(newV + 1)
+				} else {
+					annotations[shared.ConnectionsIncrementor] = "1"
+				}
+				updateMetaGenerator.Annotations = annotations
+				if shared.Update(context.TODO(), updateMetaGenerator) == nil {
+					break
+				}
+				// Since update failed, get a fresh copy of this cluster to work with and
+				// try update
+				updateMetaGenerator, fetchErr := observer.GetCluster(kubecluster.Namespace, kubecluster.Name)
+				if fetchErr != nil {
+					if errors.IsNotFound(fetchErr) {
+						break
+					}
+				}
+				if wait > maxWait {
+					return fmt.Errorf(
+						"Unable to notify cluster {%s} of configmeta change",
+						updateMetaGenerator.Name)
+				}
+				time.Sleep(wait)
+				wait = wait * 2
+			}
+		}
+	}
+	return nil
+}
+
 // Calculates md5sum of resource-versions of all resources
 // connected to this cluster
 func calcConnectionsHash(
@@ -485,12 +527,17 @@ func calcConnectionsHash(
 
 // checkContainerStates updates the lastKnownContainerState in each member
 // status. It will also move ready or config-error nodes back to create pending
-// status if their container ID has changed.
+// status if their container ID has changed, and apply the role's termination
+// policy to members whose container has terminated.
 func checkContainerStates(
 	reqLogger logr.Logger,
 	cr *kdv1.KubeDirectorCluster,
 ) {
 
+	roleSpecs := make(map[string]*kdv1.Role)
+	for i := range cr.Spec.Roles {
+		roleSpecs[cr.Spec.Roles[i].Name] = &(cr.Spec.Roles[i])
+	}
 	numRoleStatuses := len(cr.Status.Roles)
 	for i := 0; i < numRoleStatuses; i++ {
 		roleStatus := &(cr.Status.Roles[i])
@@ -498,6 +545,7 @@ func checkContainerStates(
 		for j := 0; j < numMemberStatuses; j++ {
 			memberStatus := &(roleStatus.Members[j])
 			containerID := ""
+			var terminated *corev1.ContainerStateTerminated
 			// clear SchedulingErrorMessage in MemberStateDetail
 			memberStatus.StateDetail.SchedulingErrorMessage = nil
 			// clear StorageInitProgress in MemberStateDetail
@@ -524,7 +572,16 @@ func checkContainerStates(
 											case corev1.ConditionTrue:
 												memberStatus.StateDetail.LastKnownContainerState = containerRunning
 											case corev1.ConditionFalse:
-												memberStatus.StateDetail.LastKnownContainerState = containerUnresponsive
+												// A configured member whose app
+												// container is failing its
+												// readiness probe is degraded
+												// rather than unresponsive.
+												if (memberStatus.State == string(memberReady)) &&
+													!containerStatus.Ready {
+													memberStatus.StateDetail.LastKnownContainerState = containerDegraded
+												} else {
+													memberStatus.StateDetail.LastKnownContainerState = containerUnresponsive
+												}
 											}
 											break
 										}
@@ -543,6 +600,7 @@ func checkContainerStates(
 								}
 							} else if containerStatus.State.Terminated != nil {
 								memberStatus.StateDetail.LastKnownContainerState = containerTerminated
+								terminated = containerStatus.State.Terminated
 							} else {
 								memberStatus.StateDetail.LastKnownContainerState = containerUnknown
 							}
@@ -570,6 +628,9 @@ func checkContainerStates(
 							// stuff has been lost.
 							memberStatus.StateDetail.LastConfigDataGeneration = nil
 							memberStatus.StateDetail.LastSetupGeneration = nil
+							// Nothing to upgrade from either; setup will
+							// start fresh with the current app.
+							memberStatus.StateDetail.ConfiguredApp = ""
 							// We will completely rerun the config, so drop any
 							// pending notifies.
 							memberStatus.StateDetail.PendingNotifyCmds = []*kdv1.NotificationDesc{}
@@ -584,6 +645,16 @@ func checkContainerStates(
 						}
 					}
 				}
+				// Deal with a terminated container (or a healthy restarted
+				// one) according to the role's termination policy.
+				handleTerminatedContainer(
+					reqLogger,
+					cr,
+					roleSpecs[roleStatus.Name],
+					memberStatus,
+					pod,
+					terminated,
+				)
 				// Set pod blocking message in MemberStateDetail if LastKnownContainerState is containerMissing
 				updateSchedulingErrorMessage(pod, memberStatus)
 			}
@@ -604,6 +675,7 @@ func updateStateRollup(
 	cr.Status.MemberStateRollup.MembersRestarting = false
 	cr.Status.MemberStateRollup.ConfigErrors = false
 	cr.Status.MemberStateRollup.MembersNotScheduled = false
+	cr.Status.MemberStateRollup.MembersDegraded = false
 
 	checkMemberDown := func(memberStatus kdv1.MemberStatus) {
 		if (memberStatus.StateDetail.LastKnownContainerState == containerTerminated) ||
@@ -658,6 +730,9 @@ func updateStateRollup(
 			if memberStatus.StateDetail.LastKnownContainerState == containerWaiting {
 				cr.Status.MemberStateRollup.MembersWaiting = true
 			}
+			if memberStatus.StateDetail.LastKnownContainerState == containerDegraded {
+				cr.Status.MemberStateRollup.MembersDegraded = true
+			}
 		}
 	}
 }
@@ -672,7 +747,8 @@ func updateStateRollup(
 // up further processing in the next handler. In the latter case, sync up our
 // internal state with the visible state of the CR and return true to continue
 // processing. In either-new cluster case invoke shared.EnsureClusterAppReference
-// to mark that the app is being used.
+// to mark that the app (and the previous app, if an upgrade is in progress) is
+// being used.
 func (r *ReconcileKubeDirectorCluster) handleNewCluster(
 	reqLogger logr.Logger,
 	cr *kdv1.KubeDirectorCluster,
@@ -710,6 +786,15 @@ func (r *ReconcileKubeDirectorCluster) handleNewCluster(
 		*(cr.Spec.AppCatalog),
 		cr.Spec.AppID,
 	)
+	// If an app upgrade is in progress, the previous app is in use too.
+	if cr.Status.UpgradeProgress != nil {
+		shared.EnsureClusterAppReference(
+			cr.Namespace,
+			cr.Name,
+			*(cr.Spec.AppCatalog),
+			cr.Status.UpgradeProgress.PrevApp,
+		)
+	}
 	// There are creation-race or KD-recovery cases where the app might not
 	// exist, so check that now.
 	_, appErr := catalog.GetApp(cr)
@@ -759,6 +844,14 @@ func (r *ReconcileKubeDirectorCluster) handleFinalizers(
 ) (bool, error) {
 
 	if cr.DeletionTimestamp != nil {
+		// If the PVC retention policy says so, detach the member PVCs from
+		// the CR before it goes away so they are not garbage-collected.
+		if _, whenDeleted := pvcRetentionPolicy(cr); whenDeleted == kdv1.PVCRetentionPolicyRetain {
+			releaseErr := releaseClusterPVCs(reqLogger, cr)
+			if releaseErr != nil {
+				return false, releaseErr
+			}
+		}
 		// If a deletion has been requested, while ours (or other) finalizers
 		// existed on the CR, go ahead and remove our finalizer.
 		shared.RemoveFinalizer(cr)
@@ -776,6 +869,14 @@ func (r *ReconcileKubeDirectorCluster) handleFinalizers(
 			*(cr.Spec.AppCatalog),
 			cr.Spec.AppID,
 		)
+		if cr.Status.UpgradeProgress != nil {
+			shared.RemoveClusterAppReference(
+				cr.Namespace,
+				cr.Name,
+				*(cr.Spec.AppCatalog),
+				cr.Status.UpgradeProgress.PrevApp,
+			)
+		}
 		return true, nil
 	}
 
@@ -49,6 +49,7 @@ func (r *ReconcileKubeDirectorCluster) handleRestore(
 	// version of the func from "normal" reconciliation, this one dealing
 	// only with status updates.
 	defer func() {
+		updateConditions(cr, "")
 		// Bail out if nothing has changed. Note that if we are deleting we
 		// don't care if status has changed.
 		statusChanged := false
@@ -141,6 +142,7 @@ func (r *ReconcileKubeDirectorCluster) handleRestore(
 	checkStatusRestored(reqLogger, cr)
 
 	if cr.Status.RestoreProgress.AwaitingStatus == false {
+		restoreMemberVolumes(reqLogger, cr)
 		checkResourcesRestored(reqLogger, cr)
 	}
 
@@ -250,6 +252,84 @@ func checkStatusRestored(
 	}
 }
 
+// restoreMemberVolumes creates any missing member PVCs from the volume
+// snapshots of the KubeDirectorBackup recorded in the kdstatusbackup, if
+// any. The statefulsets will then adopt these PVCs rather than creating
+// empty ones, as long as they are created first. PVCs that already exist
+// (for example because the backup solution restored them) are left alone.
+func restoreMemberVolumes(
+	reqLogger logr.Logger,
+	cr *kdv1.KubeDirectorCluster,
+) {
+
+	statusBackup, statusBackupErr := observer.GetStatusBackup(
+		cr.Namespace,
+		cr.Name,
+	)
+	if (statusBackupErr != nil) || (statusBackup.Spec.DataBackup == nil) {
+		return
+	}
+	dataBackup := statusBackup.Spec.DataBackup
+	snapshotsByPVC := make(map[string]string)
+	for _, snapshot := range dataBackup.Snapshots {
+		snapshotsByPVC[snapshot.PVC] = snapshot.VolumeSnapshot
+	}
+
+	for _, roleStatus := range cr.Status.Roles {
+		var roleSpec *kdv1.Role
+		for i := range cr.Spec.Roles {
+			if cr.Spec.Roles[i].Name == roleStatus.Name {
+				roleSpec = &(cr.Spec.Roles[i])
+				break
+			}
+		}
+		if roleSpec == nil {
+			continue
+		}
+		for _, memberStatus := range roleStatus.Members {
+			for _, claimName := range executor.StorageClaimNames(roleSpec) {
+				pvcName := executor.MemberPVCName(claimName, memberStatus.Pod)
+				snapshotName, ok := snapshotsByPVC[pvcName]
+				if !ok || !shared.StringInList(pvcName, executor.MemberPVCs(&memberStatus)) {
+					continue
+				}
+				_, pvcErr := observer.GetPVC(cr.Namespace, pvcName)
+				if (pvcErr == nil) || !errors.IsNotFound(pvcErr) {
+					continue
+				}
+				createErr := executor.CreatePVCFromDataSource(
+					cr,
+					roleSpec,
+					claimName,
+					memberStatus.Pod,
+					volumeSnapshotDataSource(snapshotName),
+				)
+				if createErr != nil {
+					shared.LogErrorf(
+						reqLogger,
+						createErr,
+						cr,
+						shared.EventReasonCluster,
+						"being restored: failed to create PVC %s from VolumeSnapshot %s",
+						pvcName,
+						snapshotName,
+					)
+					continue
+				}
+				shared.LogInfof(
+					reqLogger,
+					cr,
+					shared.EventReasonCluster,
+					"being restored: created PVC %s from VolumeSnapshot %s of kdbackup %s",
+					pvcName,
+					snapshotName,
+					dataBackup.Backup,
+				)
+			}
+		}
+	}
+}
+
 // checkResourcesRestored looks to see if resources named in the status,
 // which KD is responsible for directly creating, all exist. Set the
 // restoreProgress.awaitingResources flag accordingly.
@@ -317,7 +397,8 @@ func clusterServiceExists(
 }
 
 // roleResourcesExist looks to see if a statefulsets named in the
-// status exist, along with the necessary per-member services.
+// status exist, along with any PodDisruptionBudgets, role-wide services,
+// and the necessary per-member services.
 func roleResourcesExist(
 	reqLogger logr.Logger,
 	cr *kdv1.KubeDirectorCluster,
@@ -340,6 +421,38 @@ func roleResourcesExist(
 				return false
 			}
 		}
+		if roleStatus.PodDisruptionBudget != "" {
+			_, pdbErr := observer.GetPodDisruptionBudget(
+				cr.Namespace,
+				roleStatus.PodDisruptionBudget,
+			)
+			if pdbErr != nil {
+				shared.LogInfof(
+					reqLogger,
+					cr,
+					shared.EventReasonCluster,
+					"being restored: PodDisruptionBudget %s does not exist",
+					roleStatus.PodDisruptionBudget,
+				)
+				return false
+			}
+		}
+		if roleStatus.RoleService != "" {
+			_, serviceErr := observer.GetService(
+				cr.Namespace,
+				roleStatus.RoleService,
+			)
+			if serviceErr != nil {
+				shared.LogInfof(
+					reqLogger,
+					cr,
+					shared.EventReasonCluster,
+					"being restored: service %s does not exist",
+					roleStatus.RoleService,
+				)
+				return false
+			}
+		}
 		for _, memberStatus := range roleStatus.Members {
 			memberService := memberStatus.Service
 			if memberService != "" && memberService != zeroPortsService {
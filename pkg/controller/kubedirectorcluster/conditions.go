@@ -0,0 +1,208 @@
+// Copyright 2022 Hewlett Packard Enterprise Development LP
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubedirectorcluster
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	kdv1 "github.com/bluek8s/kubedirector/pkg/apis/kubedirector/v1beta1"
+	"github.com/bluek8s/kubedirector/pkg/shared"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// updateConditions sets the standard status conditions for the cluster,
+// based on its current state and member state rollup (so updateStateRollup
+// should be called first). currentHash is the hash of the connected
+// resources calculated in this handler pass, or emptystring if it was not
+// calculated; in that case the ConnectionsSynced condition is left alone.
+func updateConditions(
+	cr *kdv1.KubeDirectorCluster,
+	currentHash string,
+) {
+
+	setCondition := func(
+		conditionType string,
+		isTrue bool,
+		reason string,
+		message string,
+	) {
+		status := corev1.ConditionFalse
+		if isTrue {
+			status = corev1.ConditionTrue
+		}
+		shared.SetCondition(
+			&cr.Status.Conditions,
+			conditionType,
+			status,
+			reason,
+			message,
+			cr.Generation,
+		)
+	}
+
+	// While being restored, the other conditions can't be evaluated. Just
+	// make sure they are not claiming the cluster is ready.
+	restoreProgress := cr.Status.RestoreProgress
+	if restoreProgress != nil {
+		var awaiting []string
+		if restoreProgress.AwaitingApp {
+			awaiting = append(awaiting, "app")
+		}
+		if restoreProgress.AwaitingStatus {
+			awaiting = append(awaiting, "status")
+		}
+		if restoreProgress.AwaitingResources {
+			awaiting = append(awaiting, "resources")
+		}
+		message := fmt.Sprintf("awaiting %s", strings.Join(awaiting, ", "))
+		if restoreProgress.Error != "" {
+			message = restoreProgress.Error
+		}
+		setCondition(kdv1.ClusterConditionRestoring, true, "RestoreInProgress", message)
+		setCondition(kdv1.ClusterConditionConfigured, false, "Restoring", "cluster is being restored")
+		setCondition(kdv1.ClusterConditionReady, false, "Restoring", "cluster is being restored")
+		return
+	}
+	setCondition(kdv1.ClusterConditionRestoring, false, "NotRestoring", "")
+
+	// Configured follows the overall cluster state.
+	configured := (cr.Status.State == string(clusterReady))
+	var configuredReason string
+	switch cr.Status.State {
+	case string(clusterReady):
+		configuredReason = "Configured"
+	case string(clusterCreating):
+		configuredReason = "Creating"
+	case string(clusterUpdating):
+		configuredReason = "Updating"
+	case string(clusterSuspended):
+		configuredReason = "Suspended"
+	case ClusterSpecModified:
+		configuredReason = "SpecModified"
+	default:
+		configuredReason = "Unknown"
+	}
+	configuredMessage := fmt.Sprintf("cluster state is %s", cr.Status.State)
+	setCondition(kdv1.ClusterConditionConfigured, configured, configuredReason, configuredMessage)
+
+	// SpecChangePending covers a newly accepted spec change, members that
+	// have not yet been updated for the last processed spec change, and any
+	// in-progress app upgrade.
+	specGen := cr.Status.SpecGenerationToProcess
+	membersUpdating := false
+	for _, roleStatus := range cr.Status.Roles {
+		for _, memberStatus := range roleStatus.Members {
+			if len(memberStatus.StateDetail.PendingNotifyCmds) != 0 {
+				membersUpdating = true
+			}
+			configGen := memberStatus.StateDetail.LastConfigDataGeneration
+			if (specGen != nil) && (configGen != nil) && (*configGen != *specGen) {
+				membersUpdating = true
+			}
+		}
+	}
+	switch {
+	case cr.Status.State == ClusterSpecModified:
+		setCondition(kdv1.ClusterConditionSpecChangePending, true, "SpecModified", "spec change not yet processed")
+	case cr.Status.UpgradeProgress != nil:
+		upgradeMessage := fmt.Sprintf(
+			"moving from app %s to app %s",
+			cr.Status.UpgradeProgress.PrevApp,
+			cr.Status.UpgradeProgress.TargetApp,
+		)
+		setCondition(kdv1.ClusterConditionSpecChangePending, true, "AppUpgrading", upgradeMessage)
+	case membersUpdating:
+		setCondition(kdv1.ClusterConditionSpecChangePending, true, "MembersUpdating", "members have not yet processed the latest spec change")
+	default:
+		setCondition(kdv1.ClusterConditionSpecChangePending, false, "NoPendingChanges", "")
+	}
+
+	// MembersDegraded comes from the member state rollup.
+	rollup := &cr.Status.MemberStateRollup
+	var degradedReasons []string
+	if rollup.MembersDown {
+		degradedReasons = append(degradedReasons, "MembersDown")
+	}
+	if rollup.ConfigErrors {
+		degradedReasons = append(degradedReasons, "ConfigErrors")
+	}
+	if rollup.MembersNotScheduled {
+		degradedReasons = append(degradedReasons, "MembersNotScheduled")
+	}
+	if rollup.MembersDegraded {
+		degradedReasons = append(degradedReasons, "ReadinessFailing")
+	}
+	// Members of a suspended cluster are expected to be down.
+	suspended := (cr.Status.State == string(clusterSuspended))
+	degraded := (len(degradedReasons) != 0) && !suspended
+	if suspended {
+		setCondition(kdv1.ClusterConditionMembersDegraded, false, "Suspended", "cluster is suspended")
+	} else if degraded {
+		setCondition(kdv1.ClusterConditionMembersDegraded, true, degradedReasons[0], strings.Join(degradedReasons, ", "))
+	} else {
+		setCondition(kdv1.ClusterConditionMembersDegraded, false, "MembersHealthy", "")
+	}
+
+	// ConnectionsSynced checks that the connected resources have not changed
+	// since they were last processed, and that all ready members have
+	// caught up with the latest change.
+	if currentHash != "" {
+		connections := &cr.Spec.Connections
+		hasConnections := (len(connections.Clusters) != 0) ||
+			(len(connections.ConfigMaps) != 0) ||
+			(len(connections.Secrets) != 0)
+		membersReconnecting := false
+		connectionsVersionStr, hasVersion := cr.Annotations[shared.HashChangeIncrementor]
+		connectionsVersion, versionErr := strconv.ParseInt(connectionsVersionStr, 10, 64)
+		if hasVersion && (versionErr == nil) {
+			for _, roleStatus := range cr.Status.Roles {
+				for _, memberStatus := range roleStatus.Members {
+					if memberStatus.State != string(memberReady) {
+						continue
+					}
+					memberVersion := memberStatus.StateDetail.LastConnectionVersion
+					if (memberVersion == nil) || (*memberVersion < connectionsVersion) {
+						membersReconnecting = true
+					}
+				}
+			}
+		}
+		switch {
+		case currentHash != cr.Status.LastConnectionHash:
+			setCondition(kdv1.ClusterConditionConnectionsSynced, false, "ConnectionsChanged", "connected resources have changed")
+		case membersReconnecting:
+			setCondition(kdv1.ClusterConditionConnectionsSynced, false, "MembersReconnecting", "members have not yet processed the latest connection change")
+		case !hasConnections:
+			setCondition(kdv1.ClusterConditionConnectionsSynced, true, "NoConnections", "")
+		default:
+			setCondition(kdv1.ClusterConditionConnectionsSynced, true, "Synced", "")
+		}
+	}
+
+	// Ready is the overall "everything is fine" summary.
+	switch {
+	case !configured:
+		setCondition(kdv1.ClusterConditionReady, false, configuredReason, configuredMessage)
+	case degraded:
+		setCondition(kdv1.ClusterConditionReady, false, "MembersDegraded", strings.Join(degradedReasons, ", "))
+	case rollup.MembershipChanging || rollup.MembersRestarting ||
+		rollup.MembersInitializing || rollup.MembersWaiting:
+		setCondition(kdv1.ClusterConditionReady, false, "MembersNotReady", "some members are not yet running")
+	default:
+		setCondition(kdv1.ClusterConditionReady, true, "Ready", "")
+	}
+}
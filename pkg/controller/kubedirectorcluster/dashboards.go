@@ -0,0 +1,142 @@
+// Copyright 2022 Hewlett Packard Enterprise Development LP
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubedirectorcluster
+
+import (
+	kdv1 "github.com/bluek8s/kubedirector/pkg/apis/kubedirector/v1beta1"
+	"github.com/bluek8s/kubedirector/pkg/executor"
+	"github.com/bluek8s/kubedirector/pkg/shared"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+)
+
+// handleMemberDashboards makes sure that the Ingress or HTTPRoute objects
+// for the member's dashboard endpoints exist and are up to date, and removes
+// any recorded objects that are no longer wanted (for example because the
+// dashboardIngress settings of the KubeDirectorConfig have changed). The
+// objects and their URLs are recorded in the member status. Failure will not
+// be treated as a reconciler-stopping error; we'll just try again next time.
+func handleMemberDashboards(
+	reqLogger logr.Logger,
+	cr *kdv1.KubeDirectorCluster,
+	role *roleInfo,
+	member *kdv1.MemberStatus,
+) {
+
+	routes, routesErr := executor.DashboardRoutesForMember(
+		cr,
+		role.roleSpec,
+		member,
+	)
+	if routesErr != nil {
+		shared.LogErrorf(
+			reqLogger,
+			routesErr,
+			cr,
+			shared.EventReasonMember,
+			"failed to determine dashboard routes for member{%s}",
+			member.Pod,
+		)
+		return
+	}
+
+	var unwanted []kdv1.MemberDashboard
+	for _, dashboard := range member.Dashboards {
+		if findDashboardRoute(routes, dashboard) == nil {
+			unwanted = append(unwanted, dashboard)
+		}
+	}
+	dashboards := deleteDashboardRoutes(reqLogger, cr, unwanted)
+
+	for _, route := range routes {
+		applyErr := executor.ApplyDashboardRoute(
+			reqLogger,
+			cr,
+			role.roleSpec,
+			route,
+		)
+		if applyErr == nil {
+			dashboards = append(dashboards, route.Dashboard)
+			continue
+		}
+		shared.LogErrorf(
+			reqLogger,
+			applyErr,
+			cr,
+			shared.EventReasonMember,
+			"failed to apply %s{%s} for member{%s}",
+			route.Dashboard.Kind,
+			route.Dashboard.Route,
+			member.Pod,
+		)
+		// Keep any existing record of the object, so that it will still be
+		// cleaned up if it becomes unwanted.
+		for _, dashboard := range member.Dashboards {
+			if (dashboard.Kind == route.Dashboard.Kind) &&
+				(dashboard.Route == route.Dashboard.Route) {
+				dashboards = append(dashboards, dashboard)
+				break
+			}
+		}
+	}
+	member.Dashboards = dashboards
+}
+
+// deleteDashboardRoutes deletes the given Ingress or HTTPRoute objects for
+// a member's dashboard endpoints, and returns those that could not be
+// deleted. An object whose kind is not known to K8s (for example if the
+// Gateway API has been removed) is treated as already gone.
+func deleteDashboardRoutes(
+	reqLogger logr.Logger,
+	cr *kdv1.KubeDirectorCluster,
+	dashboards []kdv1.MemberDashboard,
+) []kdv1.MemberDashboard {
+
+	var remaining []kdv1.MemberDashboard
+	for _, dashboard := range dashboards {
+		deleteErr := executor.DeleteDashboardRoute(cr.Namespace, dashboard)
+		if (deleteErr == nil) || errors.IsNotFound(deleteErr) || meta.IsNoMatchError(deleteErr) {
+			continue
+		}
+		shared.LogErrorf(
+			reqLogger,
+			deleteErr,
+			cr,
+			shared.EventReasonMember,
+			"failed to delete %s{%s}",
+			dashboard.Kind,
+			dashboard.Route,
+		)
+		remaining = append(remaining, dashboard)
+	}
+	return remaining
+}
+
+// findDashboardRoute returns the route that matches the given member status
+// entry (by kind and object name), or nil if there is none.
+func findDashboardRoute(
+	routes []executor.DashboardRoute,
+	dashboard kdv1.MemberDashboard,
+) *executor.DashboardRoute {
+
+	for i := range routes {
+		if (routes[i].Dashboard.Kind == dashboard.Kind) &&
+			(routes[i].Dashboard.Route == dashboard.Route) {
+			return &(routes[i])
+		}
+	}
+	return nil
+}
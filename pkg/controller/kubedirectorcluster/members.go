@@ -442,6 +442,12 @@ func handleCreatePendingMembers(
 	role *roleInfo,
 ) {
 
+	// If this cluster is a clone, its new members' PVCs must be in place
+	// before the statefulset creates them.
+	if !seedClonedPVCs(reqLogger, cr, role) {
+		return
+	}
+
 	// Fix statefulset if necessary, and bail out if it is not good yet.
 	if !checkMemberCount(reqLogger, cr, role) {
 		return
@@ -484,7 +490,47 @@ func handleCreatePendingMembers(
 				}
 				return
 			}
+			if (pod.Status.Phase == corev1.PodPending) && (pod.DeletionTimestamp == nil) {
+				// If any of the member's PVCs were deleted (e.g. to recreate
+				// the member) the statefulset controller may have re-created
+				// the pod before the PVC was gone, in which case the pod can
+				// never be scheduled. Delete the pod again so that the
+				// statefulset controller will re-create both.
+				for _, pvcName := range executor.MemberPVCs(m) {
+					pvc, pvcGetErr := observer.GetPVC(cr.Namespace, pvcName)
+					if !apierrors.IsNotFound(pvcGetErr) &&
+						((pvcGetErr != nil) || (pvc.DeletionTimestamp == nil)) {
+						continue
+					}
+					shared.LogInfof(
+						reqLogger,
+						cr,
+						shared.EventReasonMember,
+						"PVC{%s} for member{%s} is gone; restarting pod",
+						pvcName,
+						m.Pod,
+					)
+					podDelErr := executor.DeletePod(cr.Namespace, m.Pod)
+					if (podDelErr != nil) && !apierrors.IsNotFound(podDelErr) {
+						shared.LogErrorf(
+							reqLogger,
+							podDelErr,
+							cr,
+							shared.EventReasonMember,
+							"failed to restart member{%s}",
+							m.Pod,
+						)
+					}
+					return
+				}
+			}
 			if pod.Status.Phase == corev1.PodRunning {
+				// In Istio mode, hold off on configuration until the
+				// sidecar proxy is ready; otherwise traffic to and from
+				// the app container may fail during setup.
+				if !executor.IstioProxyReady(pod) {
+					return
+				}
 				for _, containerStatus := range pod.Status.ContainerStatuses {
 					if (containerStatus.Name == executor.AppContainerName) &&
 						(containerStatus.ContainerID != "") {
@@ -532,13 +578,40 @@ func handleCreatingMembers(
 		return
 	}
 
-	// Perform setup on all of these members.
+	// Members that have never been configured must wait until the roles
+	// that this role depends on are fully configured.
+	toConfigure := creating
+	waitingOn := unconfiguredDependencies(cr, role, allRoles)
+	if len(waitingOn) != 0 {
+		toConfigure = []*kdv1.MemberStatus{}
+		for _, member := range creating {
+			if member.StateDetail.LastConfiguredContainer != "" {
+				toConfigure = append(toConfigure, member)
+				continue
+			}
+			if len(member.StateDetail.WaitingOnDependency) == 0 {
+				shared.LogInfof(
+					reqLogger,
+					cr,
+					shared.EventReasonMember,
+					"initial config for member{%s} in role{%s} waiting on role(s){%s}",
+					member.Pod,
+					role.roleStatus.Name,
+					strings.Join(waitingOn, ","),
+				)
+			}
+			member.StateDetail.WaitingOnDependency = waitingOn
+		}
+	}
+
+	// Perform setup on all of the other members.
 	var wgSetup sync.WaitGroup
-	wgSetup.Add(len(creating))
-	for _, member := range creating {
+	wgSetup.Add(len(toConfigure))
+	for _, member := range toConfigure {
 		go func(m *kdv1.MemberStatus) {
 			defer wgSetup.Done()
 
+			m.StateDetail.WaitingOnDependency = nil
 			containerID := m.StateDetail.ConfiguringContainer
 			setFinalState := func(state memberState, errorDetail *string) {
 				m.State = string(state)
@@ -573,6 +646,8 @@ func handleCreatingMembers(
 
 			if setupInfo == nil {
 				setFinalState(memberReady, nil)
+				m.StateDetail.ConfiguredApp = cr.Spec.AppID
+				m.StateDetail.CloneSource = ""
 				shared.LogInfof(
 					reqLogger,
 					cr,
@@ -653,6 +728,8 @@ func handleCreatingMembers(
 				role.roleStatus.Name,
 			)
 			setFinalState(memberReady, nil)
+			m.StateDetail.ConfiguredApp = cr.Spec.AppID
+			m.StateDetail.CloneSource = ""
 		}(member)
 	}
 	wgSetup.Wait()
@@ -676,13 +753,54 @@ func handleCreatingMembers(
 	}
 }
 
+// unconfiguredDependencies returns the IDs of any roles listed in the
+// dependsOn property of the given role that are not yet fully configured,
+// i.e. that do not have all of their desired members in ready state. Roles
+// that have no members in this cluster are not waited on.
+func unconfiguredDependencies(
+	cr *kdv1.KubeDirectorCluster,
+	role *roleInfo,
+	allRoles []*roleInfo,
+) []string {
+
+	var result []string
+	nodeRole := catalog.GetRoleFromID(cr.AppSpec, role.roleStatus.Name)
+	if nodeRole == nil {
+		return result
+	}
+	for _, dependency := range nodeRole.DependsOn {
+		for _, otherRole := range allRoles {
+			if (otherRole.roleStatus == nil) || (otherRole.roleStatus.Name != dependency) {
+				continue
+			}
+			numReady := 0
+			configured := true
+			for _, member := range otherRole.roleStatus.Members {
+				switch memberState(member.State) {
+				case memberReady:
+					numReady++
+				case memberDeletePending, memberDeleting:
+				default:
+					configured = false
+				}
+			}
+			if !configured || (numReady < otherRole.desiredPop) {
+				result = append(result, dependency)
+			}
+		}
+	}
+	return result
+}
+
 // handleDeletingMembers operates on all members in the role that are
 // currently in the deleting state. If the replicas count on the statefulset
 // has not been successfully updated yet, it attempts that change and returns.
 // Otherwise it checks each pod to see if it is gone, and if so deletes the
-// corresponding PVC and service. Once all member-related objects are gone,
-// the member status is marked for removal. It is quite possible for members
-// to be left in the deleting state across multiple reconciler passes.
+// corresponding service and (unless the PVC retention policy keeps them when
+// scaling) PVCs, along with any dashboard routes. Retained PVCs are recorded
+// in the cluster status. Once all member-related objects are gone, the
+// member status is marked for removal. It is quite possible for members to
+// be left in the deleting state across multiple reconciler passes.
 func handleDeletingMembers(
 	reqLogger logr.Logger,
 	cr *kdv1.KubeDirectorCluster,
@@ -702,14 +820,17 @@ func handleDeletingMembers(
 	// pods going away.
 
 	deleting := role.membersByState[memberDeleting]
+	whenScaled, _ := pvcRetentionPolicy(cr)
+	retainPVCs := (whenScaled == kdv1.PVCRetentionPolicyRetain)
+	retained := make([]*kdv1.RetainedPVCs, len(deleting))
 
 	// Now handle each of the deleting members in parallel. We want to clean
 	// up the corresponding service and volume claim, and ultimately the
 	// member status.
 	var wgCleanup sync.WaitGroup
 	wgCleanup.Add(len(deleting))
-	for _, member := range deleting {
-		go func(m *kdv1.MemberStatus) {
+	for index, member := range deleting {
+		go func(i int, m *kdv1.MemberStatus) {
 			defer wgCleanup.Done()
 			_, podGetErr := observer.GetPod(cr.Namespace, m.Pod)
 			if podGetErr == nil {
@@ -736,6 +857,7 @@ func handleDeletingMembers(
 					m.Service,
 				)
 				if serviceDelErr == nil || apierrors.IsNotFound(serviceDelErr) {
+					deleteIstioRouting(reqLogger, cr, m.Service)
 					m.Service = ""
 				} else {
 					shared.LogErrorf(
@@ -748,32 +870,79 @@ func handleDeletingMembers(
 					)
 				}
 			}
-			if m.PVC != "" {
-				pvcDelErr := executor.DeletePVC(
+			if retainPVCs && (m.PVC != "") {
+				retained[i] = &kdv1.RetainedPVCs{
+					Role: role.roleStatus.Name,
+					Pod:  m.Pod,
+					PVCs: executor.MemberPVCs(m),
+				}
+				m.PVC = ""
+				m.PVCs = nil
+			}
+			if m.InternalService != "" {
+				serviceDelErr := executor.DeletePodService(
+					reqLogger,
 					cr.Namespace,
-					m.PVC,
+					m.InternalService,
 				)
-				if pvcDelErr == nil || apierrors.IsNotFound(pvcDelErr) {
-					m.PVC = ""
+				if serviceDelErr == nil || apierrors.IsNotFound(serviceDelErr) {
+					deleteIstioRouting(reqLogger, cr, m.InternalService)
+					m.InternalService = ""
 				} else {
+					shared.LogErrorf(
+						reqLogger,
+						serviceDelErr,
+						cr,
+						shared.EventReasonMember,
+						"failed to delete service{%s}",
+						m.InternalService,
+					)
+				}
+			}
+			m.Dashboards = deleteDashboardRoutes(reqLogger, cr, m.Dashboards)
+			var remainingPVCs []string
+			for _, pvcName := range executor.MemberPVCs(m) {
+				pvcDelErr := executor.DeletePVC(
+					cr.Namespace,
+					pvcName,
+				)
+				if pvcDelErr != nil && !apierrors.IsNotFound(pvcDelErr) {
 					shared.LogErrorf(
 						reqLogger,
 						pvcDelErr,
 						cr,
 						shared.EventReasonMember,
 						"failed to delete PVC{%s}",
-						m.PVC,
+						pvcName,
 					)
+					remainingPVCs = append(remainingPVCs, pvcName)
 				}
 			}
+			if len(remainingPVCs) == 0 {
+				m.PVC = ""
+			}
+			m.PVCs = remainingPVCs
 			// If service and PVC have been cleaned up, mark member status for
 			// removal.
-			if m.Service == "" && m.PVC == "" {
+			if m.Service == "" && m.InternalService == "" && m.PVC == "" &&
+				len(m.Dashboards) == 0 {
 				m.Pod = ""
 			}
-		}(member)
+		}(index, member)
 	}
 	wgCleanup.Wait()
+	for _, r := range retained {
+		if r != nil {
+			shared.LogInfof(
+				reqLogger,
+				cr,
+				shared.EventReasonMember,
+				"retaining PVCs of removed member{%s}",
+				r.Pod,
+			)
+			recordRetainedPVCs(cr, *r)
+		}
+	}
 }
 
 // handleDeletePendingMembers operates on all members in the role that are
@@ -987,7 +1156,8 @@ func setupLegacyLinks(
 }
 
 // setupAppConfig injects the app setup package (if any) into the member's
-// container and installs it.
+// container and installs it. If reinstall is true, any previously installed
+// package (from an app being upgraded from) is replaced.
 func setupAppConfig(
 	reqLogger logr.Logger,
 	cr *kdv1.KubeDirectorCluster,
@@ -995,23 +1165,26 @@ func setupAppConfig(
 	podName string,
 	expectedContainerID string,
 	roleName string,
+	reinstall bool,
 ) error {
 
 	// Check to see if the destination file exists already, in which case just
 	// return. Also bail out if we cannot manage to check file existence.
-	fileExists, fileError := executor.IsFileExists(
-		reqLogger,
-		cr,
-		cr.Namespace,
-		podName,
-		expectedContainerID,
-		executor.AppContainerName,
-		appPrepStartscript,
-	)
-	if fileError != nil {
-		return fileError
-	} else if fileExists {
-		return nil
+	if !reinstall {
+		fileExists, fileError := executor.IsFileExists(
+			reqLogger,
+			cr,
+			cr.Namespace,
+			podName,
+			expectedContainerID,
+			executor.AppContainerName,
+			appPrepStartscript,
+		)
+		if fileError != nil {
+			return fileError
+		} else if fileExists {
+			return nil
+		}
 	}
 
 	// Fetch and install it.
@@ -1241,10 +1414,28 @@ func appConfig(
 		)
 	}
 
+	// If this member was last configured using some other app, this is an
+	// app upgrade (or rollback) and the startscript from the current app
+	// will be run with the upgrade event rather than the configure event.
+	upgradeFrom := ""
+	if (stateDetail.ConfiguredApp != "") && (stateDetail.ConfiguredApp != cr.Spec.AppID) {
+		upgradeFrom = stateDetail.ConfiguredApp
+	}
+
+	// If this member's storage was cloned from a member of another cluster,
+	// and that member had been configured, the startscript will be run with
+	// the cloned event rather than the configure event.
+	clonedFrom := ""
+	if (stateDetail.CloneSource != "") && (cr.Spec.CloneFrom != nil) {
+		clonedFrom = cr.Spec.CloneFrom.Cluster
+	}
+	runCloned := false
+
 	// If a config error detail already exists, this is a restart of a member
 	// that had been in config error state. In that case we won't try
 	// checking the existing state within the guest.
 	if stateDetail.ConfigErrorDetail != nil {
+		runCloned = (clonedFrom != "")
 		// Clean up for the retry.
 		stateDetail.ConfigErrorDetail = nil
 		stateDetail.LastSetupGeneration = nil
@@ -1276,7 +1467,20 @@ func appConfig(
 				return true, err
 			}
 			configContainerID, configStatus := statusStr[:splitPoint], statusStr[splitPoint+1:]
-			if configStatus == "" {
+			if (clonedFrom != "") && (configContainerID != expectedContainerID) {
+				// This status came along with the storage cloned from the
+				// source member. Fall through to run the cloned event.
+				runCloned = true
+				shared.LogInfof(
+					reqLogger,
+					cr,
+					shared.EventReasonMember,
+					"member{%s} was cloned from member{%s} of kdcluster{%s}",
+					podName,
+					stateDetail.CloneSource,
+					clonedFrom,
+				)
+			} else if configStatus == "" {
 				// Script isn't done. But was it interrupted by a container
 				// restart? If not we will return and check again later; if so
 				// we will fall through and try to start setup from scratch.
@@ -1301,7 +1505,20 @@ func appConfig(
 					stateDetail.PendingNotifyCmds = []*kdv1.NotificationDesc{}
 				}
 				status, convErr := strconv.Atoi(configStatus)
-				if convErr == nil && status == 0 {
+				if convErr == nil && status == 0 && upgradeFrom != "" &&
+					configContainerID != expectedContainerID {
+					// Configure previously succeeded, but this container is
+					// now running a different app. Fall through to run the
+					// upgrade.
+					shared.LogInfof(
+						reqLogger,
+						cr,
+						shared.EventReasonMember,
+						"member{%s} will be upgraded from app{%s}",
+						podName,
+						upgradeFrom,
+					)
+				} else if convErr == nil && status == 0 {
 					// Configure previously succeeded so basically we're done
 					// here. However, if this is a container restart, see if
 					// we need to re-establish configcli symlinks.
@@ -1318,12 +1535,13 @@ func appConfig(
 						}
 					}
 					return true, nil
+				} else {
+					statusErr := fmt.Errorf(
+						"configure failed with exit status {%s}",
+						configStatus,
+					)
+					return true, statusErr
 				}
-				statusErr := fmt.Errorf(
-					"configure failed with exit status {%s}",
-					configStatus,
-				)
-				return true, statusErr
 			}
 		}
 	}
@@ -1392,7 +1610,15 @@ func appConfig(
 		return true, linkErr
 	}
 	// Make sure the necessary app-specific materials are in place.
-	setupErr := setupAppConfig(reqLogger, cr, setupInfo.PackageURL, podName, expectedContainerID, roleName)
+	setupErr := setupAppConfig(
+		reqLogger,
+		cr,
+		setupInfo.PackageURL,
+		podName,
+		expectedContainerID,
+		roleName,
+		upgradeFrom != "",
+	)
 	if setupErr != nil {
 		return true, setupErr
 	}
@@ -1409,6 +1635,65 @@ func appConfig(
 		return true, appErr
 	}
 	role := catalog.GetRoleFromID(appCr, roleName)
+	// Apps that predate the cloned event would not recognize it, so it is
+	// only sent if explicitly registered. Otherwise the cloned member goes
+	// through the same setup as any other new member.
+	if runCloned && (role.EventList != nil) && shared.StringInList("cloned", *role.EventList) {
+		cmd := fmt.Sprintf(appPrepClonedRunCmd, expectedContainerID, clonedFrom)
+		cmdErr := executor.RunScript(
+			reqLogger,
+			cr,
+			cr.Namespace,
+			podName,
+			expectedContainerID,
+			executor.AppContainerName,
+			"app clone",
+			strings.NewReader(cmd),
+		)
+		if cmdErr != nil {
+			nodeRole := catalog.GetRoleFromID(cr.AppSpec, roleName)
+			if nodeRole != nil {
+				setStateDetailLogs(readFile, stateDetail, nodeRole.MaxLogSizeDump)
+			}
+			return true, cmdErr
+		}
+		return false, nil
+	}
+	if upgradeFrom != "" {
+		// An app being rolled back to may predate the upgrade event, so in
+		// that case only send the event if it is explicitly registered.
+		rollingBack := (cr.Status.UpgradeProgress != nil) && cr.Status.UpgradeProgress.RollingBack
+		if role.EventList == nil {
+			if rollingBack {
+				return true, nil
+			}
+		} else if !shared.StringInList("upgrade", *role.EventList) {
+			return true, nil
+		}
+		rollbackArg := ""
+		if rollingBack {
+			rollbackArg = " --rollback"
+		}
+		cmd := fmt.Sprintf(appPrepUpgradeRunCmd, expectedContainerID, upgradeFrom, rollbackArg)
+		cmdErr := executor.RunScript(
+			reqLogger,
+			cr,
+			cr.Namespace,
+			podName,
+			expectedContainerID,
+			executor.AppContainerName,
+			"app upgrade",
+			strings.NewReader(cmd),
+		)
+		if cmdErr != nil {
+			nodeRole := catalog.GetRoleFromID(cr.AppSpec, roleName)
+			if nodeRole != nil {
+				setStateDetailLogs(readFile, stateDetail, nodeRole.MaxLogSizeDump)
+			}
+			return true, cmdErr
+		}
+		return false, nil
+	}
 	if role.EventList != nil && !shared.StringInList("configure", *role.EventList) {
 		return true, nil
 	}
@@ -1500,7 +1785,8 @@ func queueNotify(
 	// Compose the notify command arguments.
 	arguments := []string{
 		"--" + op,
-		"--nodegroup 1", // currently only 1 nodegroup possible
+		"--nodegroup",
+		catalog.GetNodegroupForRole(cr, appCr, modifiedRole.roleStatus.Name),
 		"--role",
 		modifiedRole.roleStatus.Name,
 		"--fqdns",
@@ -0,0 +1,73 @@
+// Copyright 2022 Hewlett Packard Enterprise Development LP
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubedirectorcluster
+
+import (
+	kdv1 "github.com/bluek8s/kubedirector/pkg/apis/kubedirector/v1beta1"
+	"github.com/bluek8s/kubedirector/pkg/catalog"
+	"github.com/bluek8s/kubedirector/pkg/executor"
+	"github.com/go-logr/logr"
+)
+
+// handleRoleMonitor manages the Prometheus PodMonitor for a role, which is
+// needed if any of the role's service endpoints serve metrics and the
+// PodMonitor resource type is available (see handleRoleObject). If
+// PodMonitors are not available, the role's pod template is given
+// Prometheus scrape annotations instead when it is generated.
+func handleRoleMonitor(
+	reqLogger logr.Logger,
+	cr *kdv1.KubeDirectorCluster,
+	role *roleInfo,
+) {
+
+	if role.roleStatus == nil {
+		return
+	}
+
+	var metricsPorts []catalog.ServicePortInfo
+	handleRoleObject(
+		reqLogger,
+		cr,
+		role,
+		roleObject{
+			kind:       "PodMonitor",
+			statusName: &role.roleStatus.PodMonitor,
+			needed: func() (bool, error) {
+				var portsErr error
+				metricsPorts, portsErr = executor.MetricsPortsForRole(cr, role.roleSpec)
+				if (portsErr != nil) || (len(metricsPorts) == 0) {
+					return false, portsErr
+				}
+				return executor.PodMonitorsSupported()
+			},
+			apply: func(name string) (string, error) {
+				applyErr := executor.ApplyPodMonitor(
+					reqLogger,
+					cr,
+					role.roleSpec,
+					role.roleStatus,
+					metricsPorts,
+				)
+				if applyErr != nil {
+					return name, applyErr
+				}
+				return role.roleStatus.StatefulSet, nil
+			},
+			delete: func(name string) error {
+				return executor.DeletePodMonitor(cr.Namespace, name)
+			},
+		},
+	)
+}
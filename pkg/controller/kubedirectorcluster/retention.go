@@ -0,0 +1,163 @@
+// Copyright 2022 Hewlett Packard Enterprise Development LP
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubedirectorcluster
+
+import (
+	"github.com/go-logr/logr"
+
+	kdv1 "github.com/bluek8s/kubedirector/pkg/apis/kubedirector/v1beta1"
+	"github.com/bluek8s/kubedirector/pkg/executor"
+	"github.com/bluek8s/kubedirector/pkg/observer"
+	"github.com/bluek8s/kubedirector/pkg/shared"
+	"k8s.io/apimachinery/pkg/api/errors"
+)
+
+// pvcRetentionPolicy returns the PVC retention policy for members removed
+// from the cluster (whenScaled) and for all members when the cluster is
+// deleted (whenDeleted). Any property not set in the cluster's policy comes
+// from the KubeDirectorConfig default policy, or failing that is Delete.
+func pvcRetentionPolicy(
+	cr *kdv1.KubeDirectorCluster,
+) (string, string) {
+
+	whenScaled := kdv1.PVCRetentionPolicyDelete
+	whenDeleted := kdv1.PVCRetentionPolicyDelete
+	policies := []*kdv1.PVCRetentionPolicy{
+		shared.GetDefaultPVCRetentionPolicy(),
+		cr.Spec.PVCRetentionPolicy,
+	}
+	for _, policy := range policies {
+		if policy == nil {
+			continue
+		}
+		if policy.WhenScaled != nil {
+			whenScaled = *policy.WhenScaled
+		}
+		if policy.WhenDeleted != nil {
+			whenDeleted = *policy.WhenDeleted
+		}
+	}
+	return whenScaled, whenDeleted
+}
+
+// recordRetainedPVCs adds the given PVCs, kept from a removed member of the
+// given role, to the cluster status. Any earlier record for the same pod is
+// replaced.
+func recordRetainedPVCs(
+	cr *kdv1.KubeDirectorCluster,
+	retained kdv1.RetainedPVCs,
+) {
+
+	reattachRetainedPVCs(cr, retained.Pod)
+	cr.Status.RetainedPVCs = append(cr.Status.RetainedPVCs, retained)
+}
+
+// reattachRetainedPVCs removes the record of any PVCs retained for the given
+// pod from the cluster status. This is done when a new member with that pod
+// name is added, since its statefulset will then adopt those PVCs.
+func reattachRetainedPVCs(
+	cr *kdv1.KubeDirectorCluster,
+	podName string,
+) {
+
+	var remaining []kdv1.RetainedPVCs
+	for _, retained := range cr.Status.RetainedPVCs {
+		if retained.Pod != podName {
+			remaining = append(remaining, retained)
+		}
+	}
+	cr.Status.RetainedPVCs = remaining
+}
+
+// pruneRetainedPVCs drops the record of any retained PVCs that no longer
+// exist (e.g. because they were deleted by hand) from the cluster status.
+func pruneRetainedPVCs(
+	reqLogger logr.Logger,
+	cr *kdv1.KubeDirectorCluster,
+) {
+
+	if len(cr.Status.RetainedPVCs) == 0 {
+		return
+	}
+	var remaining []kdv1.RetainedPVCs
+	for _, retained := range cr.Status.RetainedPVCs {
+		var existing []string
+		for _, pvcName := range retained.PVCs {
+			_, pvcErr := observer.GetPVC(cr.Namespace, pvcName)
+			if (pvcErr == nil) || !errors.IsNotFound(pvcErr) {
+				existing = append(existing, pvcName)
+			}
+		}
+		if len(existing) == 0 {
+			shared.LogInfof(
+				reqLogger,
+				cr,
+				shared.EventReasonMember,
+				"retained PVCs for member{%s} are gone",
+				retained.Pod,
+			)
+			continue
+		}
+		retained.PVCs = existing
+		remaining = append(remaining, retained)
+	}
+	cr.Status.RetainedPVCs = remaining
+}
+
+// releaseClusterPVCs is used when the cluster is being deleted and its PVC
+// retention policy keeps PVCs on deletion. It removes the cluster's owner
+// reference from the PVCs of all members, including any PVCs retained from
+// earlier removed members, so that they are not garbage-collected along
+// with the cluster.
+func releaseClusterPVCs(
+	reqLogger logr.Logger,
+	cr *kdv1.KubeDirectorCluster,
+) error {
+
+	var pvcNames []string
+	for i := range cr.Status.Roles {
+		for j := range cr.Status.Roles[i].Members {
+			member := &(cr.Status.Roles[i].Members[j])
+			pvcNames = append(pvcNames, executor.MemberPVCs(member)...)
+		}
+	}
+	for _, retained := range cr.Status.RetainedPVCs {
+		pvcNames = append(pvcNames, retained.PVCs...)
+	}
+	for _, pvcName := range pvcNames {
+		releaseErr := executor.ReleasePVC(cr, pvcName)
+		if releaseErr != nil {
+			shared.LogErrorf(
+				reqLogger,
+				releaseErr,
+				cr,
+				shared.EventReasonCluster,
+				"failed to retain PVC{%s}",
+				pvcName,
+			)
+			return releaseErr
+		}
+	}
+	if len(pvcNames) != 0 {
+		shared.LogInfof(
+			reqLogger,
+			cr,
+			shared.EventReasonCluster,
+			"retaining %d PVCs after deletion",
+			len(pvcNames),
+		)
+	}
+	return nil
+}
@@ -22,10 +22,12 @@ import (
 	"github.com/go-logr/logr"
 
 	kdv1 "github.com/bluek8s/kubedirector/pkg/apis/kubedirector/v1beta1"
+	"github.com/bluek8s/kubedirector/pkg/catalog"
 	"github.com/bluek8s/kubedirector/pkg/executor"
 	"github.com/bluek8s/kubedirector/pkg/observer"
 	"github.com/bluek8s/kubedirector/pkg/shared"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 )
 
 // syncClusterRoles is responsible for dealing with roles being changed, added,
@@ -45,6 +47,13 @@ func syncClusterRoles(
 		return nil, clusterMembersUnknown, rolesErr
 	}
 
+	// Forget about any retained PVCs that have since been deleted.
+	pruneRetainedPVCs(reqLogger, cr)
+
+	// Track any change of the app used by this cluster. This must happen
+	// before handleRoleConfig below moves any statefulsets to the new app.
+	checkAppUpgrade(reqLogger, cr, roles)
+
 	// Role changes will be postponed if any members are currently in the
 	// creating state. Such members may have been informed of the current
 	// member set, and they are not yet ready to receive updates about
@@ -70,6 +79,16 @@ func syncClusterRoles(
 			if createErr != nil {
 				return nil, clusterMembersUnknown, createErr
 			}
+		case r.roleStatus != nil && r.roleStatus.RecreatingStatefulSet:
+			// The statefulset is being replaced to pick up larger volume
+			// claim templates. Its pods are left running meanwhile.
+			replaceErr := handleRoleStatefulSetReplace(reqLogger, cr, r, &anyMembersChanged)
+			if replaceErr != nil {
+				return nil, clusterMembersUnknown, replaceErr
+			}
+			if r.roleStatus.RecreatingStatefulSet {
+				allMembersReady = false
+			}
 		case r.statefulSet == nil && r.roleStatus != nil:
 			// Role exists but there is no statefulset for it in k8s.
 			// Hmm, weird. Statefulset was deleted out-of-band? Let's fix.
@@ -82,6 +101,8 @@ func syncClusterRoles(
 			// First see if we need to reconcile any out-of-band statefulset
 			// changes.
 			handleRoleConfig(reqLogger, cr, r)
+			// Grow the member PVCs if the role asks for more storage.
+			handleRoleStorageExpansion(reqLogger, cr, r)
 			// Now check for desired changes in role population.
 			if len(r.roleStatus.Members) == 0 && r.desiredPop == 0 {
 				// Role is going away and we have finished removing pods.
@@ -101,6 +122,9 @@ func syncClusterRoles(
 			)
 			panic(panicMsg)
 		}
+		handleRoleDisruptionBudget(reqLogger, cr, r)
+		handleRoleService(reqLogger, cr, r)
+		handleRoleMonitor(reqLogger, cr, r)
 		if !allRoleMembersReadyOrError(cr, r) {
 			allMembersReady = false
 		}
@@ -117,6 +141,16 @@ func syncClusterRoles(
 		}
 	}
 
+	// If any members are not yet running the current pod template for their
+	// role (because of a role spec change or an app upgrade), now is the
+	// time to restart some of them. The cluster is not considered ready
+	// until every member has been moved.
+	if returnState == clusterMembersStableReady {
+		if handleRollouts(reqLogger, cr, roles) {
+			returnState = clusterMembersStableUnready
+		}
+	}
+
 	return roles, returnState, nil
 }
 
@@ -277,6 +311,7 @@ func handleRoleCreate(
 		nativeSystemdSupport,
 		role.roleSpec,
 		role.roleStatus,
+		0,
 	)
 	if createErr != nil {
 		// Not much to do if we can't create it... we'll just keep trying
@@ -309,6 +344,7 @@ func handleRoleCreate(
 	} else {
 		role.roleStatus.StatefulSet = statefulSet.Name
 	}
+	recordRoleImage(cr, role)
 	addMemberStatuses(cr, role)
 	return nil
 }
@@ -377,7 +413,9 @@ func handleRoleReCreate(
 }
 
 // handleRoleConfig checks an existing statefulset to see if any of its
-// important properties (other than replicas count) need to be reconciled.
+// important properties (other than replicas count) need to be reconciled,
+// including updating its pod template for a role spec change or for a new
+// app during an app upgrade.
 // Failure to reconcile will not be treated as a reconciler-stopping error; we'll
 // just try again next time.
 func handleRoleConfig(
@@ -389,7 +427,9 @@ func handleRoleConfig(
 	updateErr := executor.UpdateStatefulSetNonReplicas(
 		reqLogger,
 		cr,
+		shared.GetNativeSystemdSupport(),
 		role.roleSpec,
+		role.roleStatus,
 		role.statefulSet)
 	if updateErr != nil {
 		shared.LogErrorf(
@@ -400,7 +440,183 @@ func handleRoleConfig(
 			"failed to update StatefulSet{%s}",
 			role.statefulSet.Name,
 		)
+		return
+	}
+	recordRoleImage(cr, role)
+}
+
+// recordRoleImage records the image used by the role's statefulset in the
+// role status. The statefulset must be up to date with the role spec.
+func recordRoleImage(
+	cr *kdv1.KubeDirectorCluster,
+	role *roleInfo,
+) {
+
+	if (role.roleSpec == nil) || (role.roleStatus == nil) {
+		return
+	}
+	if image, imageErr := catalog.ImageForRole(cr, role.roleSpec.Name); imageErr == nil {
+		role.roleStatus.ImageRepoTag = image
+	}
+}
+
+// roleObject describes an optional K8s object that is kept for a role, such
+// as its PodDisruptionBudget, and how to manage it; see handleRoleObject.
+type roleObject struct {
+	// kind names the object type in log messages.
+	kind string
+	// statusName is the role status property that records the object name.
+	statusName *string
+	// needed returns true if the role should have the object.
+	needed func() (bool, error)
+	// apply creates the object, or reconciles it if the given name (from
+	// the role status) identifies an existing one, and returns its name.
+	apply func(name string) (string, error)
+	// delete deletes the object with the given name.
+	delete func(name string) error
+}
+
+// handleRoleObject creates, updates, or deletes an optional per-role object
+// as necessary. The object is only wanted if the role still has a
+// statefulset and desired members, and if obj.needed says so. The object
+// name is recorded in the role status. Failure will not be treated as a
+// reconciler-stopping error; we'll just try again next time.
+func handleRoleObject(
+	reqLogger logr.Logger,
+	cr *kdv1.KubeDirectorCluster,
+	role *roleInfo,
+	obj roleObject,
+) {
+
+	needed := false
+	if (role.roleSpec != nil) && (role.desiredPop != 0) && (role.roleStatus.StatefulSet != "") {
+		var neededErr error
+		needed, neededErr = obj.needed()
+		if neededErr != nil {
+			shared.LogErrorf(
+				reqLogger,
+				neededErr,
+				cr,
+				shared.EventReasonRole,
+				"failed to determine whether role{%s} needs a %s",
+				role.roleSpec.Name,
+				obj.kind,
+			)
+			return
+		}
+	}
+
+	name := *obj.statusName
+	if !needed {
+		if name == "" {
+			return
+		}
+		shared.LogInfof(
+			reqLogger,
+			cr,
+			shared.EventReasonRole,
+			"deleting %s{%s} for role{%s}",
+			obj.kind,
+			name,
+			role.roleStatus.Name,
+		)
+		deleteErr := obj.delete(name)
+		if (deleteErr == nil) || errors.IsNotFound(deleteErr) || meta.IsNoMatchError(deleteErr) {
+			*obj.statusName = ""
+		} else {
+			shared.LogErrorf(
+				reqLogger,
+				deleteErr,
+				cr,
+				shared.EventReasonRole,
+				"failed to delete %s{%s}",
+				obj.kind,
+				name,
+			)
+		}
+		return
+	}
+
+	newName, applyErr := obj.apply(name)
+	if applyErr != nil {
+		shared.LogErrorf(
+			reqLogger,
+			applyErr,
+			cr,
+			shared.EventReasonRole,
+			"failed to apply %s for role{%s}",
+			obj.kind,
+			role.roleSpec.Name,
+		)
+		return
+	}
+	*obj.statusName = newName
+}
+
+// handleRoleDisruptionBudget manages the PodDisruptionBudget for a role,
+// which is needed if the app declares a disruption budget for the role.
+func handleRoleDisruptionBudget(
+	reqLogger logr.Logger,
+	cr *kdv1.KubeDirectorCluster,
+	role *roleInfo,
+) {
+
+	if role.roleStatus == nil {
+		return
 	}
+
+	var budget *kdv1.DisruptionBudget
+	handleRoleObject(
+		reqLogger,
+		cr,
+		role,
+		roleObject{
+			kind:       shared.PodDisruptionBudgetKind,
+			statusName: &role.roleStatus.PodDisruptionBudget,
+			needed: func() (bool, error) {
+				var budgetErr error
+				budget, budgetErr = catalog.RoleDisruptionBudget(cr, role.roleSpec.Name)
+				return budget != nil, budgetErr
+			},
+			apply: func(name string) (string, error) {
+				if name != "" {
+					pdb, pdbErr := observer.GetPodDisruptionBudget(cr.Namespace, name)
+					if pdbErr == nil {
+						return name, executor.UpdatePodDisruptionBudget(
+							reqLogger,
+							cr,
+							role.roleSpec,
+							budget,
+							pdb,
+						)
+					}
+					if !errors.IsNotFound(pdbErr) {
+						return name, pdbErr
+					}
+				}
+				shared.LogInfof(
+					reqLogger,
+					cr,
+					shared.EventReasonRole,
+					"creating PodDisruptionBudget for role{%s}",
+					role.roleSpec.Name,
+				)
+				newPdb, createErr := executor.CreatePodDisruptionBudget(
+					cr,
+					role.roleSpec,
+					role.roleStatus,
+					budget,
+				)
+				if createErr != nil {
+					return name, createErr
+				}
+				return newPdb.GetName(), nil
+			},
+			delete: func(name string) error {
+				return executor.DeletePodDisruptionBudget(cr.Namespace, name)
+			},
+		},
+	)
 }
 
 // handleRoleDelete takes care of deleting the associated statefulset after
@@ -502,11 +718,16 @@ func addMemberStatuses(
 		// Pod name and PVC name will be generated by K8s in a predictable
 		// way, so go ahead and populate those here.
 		memberName := role.roleStatus.StatefulSet + "-" + indexString
+		// Any PVCs retained from an earlier member with this name will be
+		// reused by the new member.
+		reattachRetainedPVCs(cr, memberName)
 		var pvcName string
-		if role.roleSpec.Storage == nil {
-			pvcName = ""
-		} else {
-			pvcName = executor.PvcNamePrefix + "-" + memberName
+		var pvcNames []string
+		for _, claimName := range executor.StorageClaimNames(role.roleSpec) {
+			pvcNames = append(pvcNames, executor.MemberPVCName(claimName, memberName))
+		}
+		if len(pvcNames) != 0 {
+			pvcName = pvcNames[0]
 		}
 		// check if there is block device to be mounted in the member.
 		// assign path value if there is else it'd be an empty string
@@ -531,6 +752,7 @@ func addMemberStatuses(
 				Pod:              memberName,
 				Service:          "",
 				PVC:              pvcName,
+				PVCs:             pvcNames,
 				NodeID:           atomic.AddInt64(lastNodeID, 1),
 				State:            string(memberCreatePending),
 				BlockDevicePaths: blockDevPaths,
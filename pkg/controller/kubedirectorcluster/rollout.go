@@ -0,0 +1,177 @@
+// Copyright 2022 Hewlett Packard Enterprise Development LP
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubedirectorcluster
+
+import (
+	"github.com/go-logr/logr"
+
+	kdv1 "github.com/bluek8s/kubedirector/pkg/apis/kubedirector/v1beta1"
+	"github.com/bluek8s/kubedirector/pkg/executor"
+	"github.com/bluek8s/kubedirector/pkg/observer"
+	"github.com/bluek8s/kubedirector/pkg/shared"
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// defaultMaxUnavailable is the number of members of a role that may be
+// restarted at once by a rollout, if the role spec does not say otherwise.
+const defaultMaxUnavailable = int32(1)
+
+// handleRollouts restarts members whose pods are not running the current pod
+// template of their role's statefulset, i.e. after a role spec change or
+// during an app upgrade (or rollback). Each restarted member goes back
+// through the create pending and creating states, so its configmeta and app
+// setup are re-checked. Only one role is rolled at a time, and at most
+// maxUnavailable members of that role are down at once. It should only be
+// called when the cluster is otherwise stable.
+//
+// During an app upgrade, a member is considered upgraded once it has been
+// configured with the target app from a pod at the statefulset's update
+// revision. If any member failed its upgrade, the rollout is paused until
+// the cluster spec is changed back to the previous app; a rollback is never
+// paused.
+//
+// The return value is true if any rollout is still in progress.
+func handleRollouts(
+	reqLogger logr.Logger,
+	cr *kdv1.KubeDirectorCluster,
+	roles []*roleInfo,
+) bool {
+
+	upgrade := cr.Status.UpgradeProgress
+	rolloutPending := (upgrade != nil)
+	var failedMembers []string
+	var restartRole *roleInfo
+	var restartMembers []*kdv1.MemberStatus
+	for _, r := range roles {
+		if (r.statefulSet == nil) || (r.roleSpec == nil) {
+			continue
+		}
+		// Wait until the statefulset has the new pod template, and the
+		// statefulset controller has calculated its update revision.
+		if r.statefulSet.Labels[executor.ClusterAppLabel] != cr.Spec.AppID {
+			return true
+		}
+		if r.statefulSet.Status.ObservedGeneration < r.statefulSet.Generation {
+			return true
+		}
+		updateRevision := r.statefulSet.Status.UpdateRevision
+		maxUnavailable := defaultMaxUnavailable
+		if r.roleSpec.MaxUnavailable != nil {
+			maxUnavailable = *r.roleSpec.MaxUnavailable
+		}
+		unavailable := int32(0)
+		var candidates []*kdv1.MemberStatus
+		for _, state := range []memberState{memberReady, memberConfigError} {
+			for _, m := range r.membersByState[state] {
+				if (state == memberReady) &&
+					(m.StateDetail.LastKnownContainerState != containerRunning) {
+					// Not healthy; counts against the members that we
+					// can take down.
+					unavailable++
+					continue
+				}
+				pod, podErr := observer.GetPod(cr.Namespace, m.Pod)
+				if podErr != nil {
+					if !apierrors.IsNotFound(podErr) {
+						shared.LogErrorf(
+							reqLogger,
+							podErr,
+							cr,
+							shared.EventReasonMember,
+							"failed to find member{%s} in role{%s}",
+							m.Pod,
+							r.roleStatus.Name,
+						)
+					}
+					return true
+				}
+				atUpdateRevision := (pod.Labels[appsv1.ControllerRevisionHashLabelKey] == updateRevision)
+				if atUpdateRevision {
+					if (upgrade == nil) || (m.StateDetail.ConfiguredApp == cr.Spec.AppID) {
+						continue
+					}
+					if state == memberConfigError {
+						failedMembers = append(failedMembers, m.Pod)
+						continue
+					}
+				}
+				candidates = append(candidates, m)
+			}
+		}
+		if len(candidates) == 0 {
+			continue
+		}
+		rolloutPending = true
+		if restartRole != nil {
+			continue
+		}
+		restartRole = r
+		budget := maxUnavailable - unavailable
+		if budget > int32(len(candidates)) {
+			budget = int32(len(candidates))
+		}
+		if budget > 0 {
+			restartMembers = candidates[:budget]
+		}
+	}
+	if upgrade != nil {
+		upgrade.FailedMembers = failedMembers
+		if (len(failedMembers) != 0) && !upgrade.RollingBack {
+			shared.LogInfof(
+				reqLogger,
+				cr,
+				shared.EventReasonCluster,
+				"upgrade to app{%s} paused; failed on %d member(s)",
+				cr.Spec.AppID,
+				len(failedMembers),
+			)
+			return true
+		}
+	}
+	for _, m := range restartMembers {
+		if upgrade != nil {
+			shared.LogInfof(
+				reqLogger,
+				cr,
+				shared.EventReasonMember,
+				"restarting member{%s} to move it to app{%s}",
+				m.Pod,
+				cr.Spec.AppID,
+			)
+		} else {
+			shared.LogInfof(
+				reqLogger,
+				cr,
+				shared.EventReasonMember,
+				"restarting member{%s} to apply changes to role{%s}",
+				m.Pod,
+				restartRole.roleStatus.Name,
+			)
+		}
+		deleteErr := executor.DeletePod(cr.Namespace, m.Pod)
+		if (deleteErr != nil) && !apierrors.IsNotFound(deleteErr) {
+			shared.LogErrorf(
+				reqLogger,
+				deleteErr,
+				cr,
+				shared.EventReasonMember,
+				"failed to restart member{%s}",
+				m.Pod,
+			)
+		}
+	}
+	return rolloutPending
+}
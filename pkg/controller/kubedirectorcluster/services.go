@@ -36,8 +36,10 @@ var serviceShouldBeReconciled = map[memberState]bool{
 }
 
 // syncClusterService is responsible for dealing with the per-member services.
-// It and syncMemberServices are the only functions in this file that are
-// invoked from another file (from the syncCluster function in cluster.go).
+// It, syncMemberServices, syncIstioRouting, handleRoleService, and
+// deleteIstioRouting are the only functions in this file that are invoked
+// from another file (from the syncCluster function in cluster.go,
+// syncClusterRoles in roles.go, or member deletion in members.go).
 // Managing service changes may result in operations on k8s services. This
 // function will also modify the status data structures to record the service
 // name.
@@ -107,6 +109,73 @@ func syncMemberServices(
 	return nil
 }
 
+// handleRoleService manages the role-wide service for a role, which is
+// needed if any of the role's service endpoints ask for one (see
+// handleRoleObject). This is called from syncClusterRoles, so that the
+// service is removed before the status of a deleted role goes away.
+func handleRoleService(
+	reqLogger logr.Logger,
+	cr *kdv1.KubeDirectorCluster,
+	role *roleInfo,
+) {
+
+	if role.roleStatus == nil {
+		return
+	}
+
+	handleRoleObject(
+		reqLogger,
+		cr,
+		role,
+		roleObject{
+			kind:       "service",
+			statusName: &role.roleStatus.RoleService,
+			needed: func() (bool, error) {
+				return executor.RoleServiceNeeded(cr, role.roleSpec)
+			},
+			apply: func(name string) (string, error) {
+				roleService, queryErr := queryService(reqLogger, cr, name)
+				if queryErr != nil {
+					return name, queryErr
+				}
+				if roleService != nil {
+					return name, executor.UpdateRoleService(
+						reqLogger,
+						cr,
+						role.roleSpec,
+						roleService,
+					)
+				}
+				if name != "" {
+					shared.LogInfof(
+						reqLogger,
+						cr,
+						shared.EventReasonRole,
+						"re-creating missing service for role{%s}",
+						role.roleSpec.Name,
+					)
+				}
+				newService, createErr := executor.CreateRoleService(
+					cr,
+					role.roleSpec,
+					role.roleStatus,
+				)
+				if (createErr != nil) || (newService == nil) {
+					return "", createErr
+				}
+				return newService.Name, nil
+			},
+			delete: func(name string) error {
+				deleteErr := executor.DeletePodService(reqLogger, cr.Namespace, name)
+				if (deleteErr == nil) || errors.IsNotFound(deleteErr) {
+					deleteIstioRouting(reqLogger, cr, name)
+				}
+				return deleteErr
+			},
+		},
+	)
+}
+
 // handleClusterServiceCreate will create a cluster "headless" service and
 // store its name in the cluster status. Failure to create this service will
 // be a reconciler-stopping error.
@@ -156,11 +225,125 @@ func handleClusterServiceConfig(
 	}
 }
 
-// handleMemberService makes sure that the per-member service exists if it
-// should. (If it should not, we don't worry about it here... member syncing
-// will clean it up.) If the service is created, it will store this service
-// name in the member status. Failure to create a service as needed will be a
-// reconciler-stopping error.
+// syncIstioRouting makes sure that each service of the cluster (the
+// headless service, and every per-member, internal, or role service) has an
+// Istio VirtualService and DestinationRule if Istio mode is enabled, and
+// removes them all if it has been disabled. The routing objects of a
+// service that gets deleted are removed along with it; see
+// deleteIstioRouting. The cluster status records the routing name of the
+// headless service, which also marks that routing objects may exist. Like
+// syncMemberServices, this is invoked from syncCluster in cluster.go.
+// Failure here will not be treated as a reconciler-stopping error; we'll
+// just try again next time.
+func syncIstioRouting(
+	reqLogger logr.Logger,
+	cr *kdv1.KubeDirectorCluster,
+	roles []*roleInfo,
+) {
+
+	istioEnabled := shared.GetIstioSettings() != nil
+	if !istioEnabled && (cr.Status.IstioRouting == "") {
+		return
+	}
+	var serviceNames []string
+	if cr.Status.ClusterService != "" {
+		serviceNames = append(serviceNames, cr.Status.ClusterService)
+	}
+	for _, role := range roles {
+		if role.roleStatus == nil {
+			continue
+		}
+		if role.roleStatus.RoleService != "" {
+			serviceNames = append(serviceNames, role.roleStatus.RoleService)
+		}
+		for _, member := range role.roleStatus.Members {
+			if (member.Service != "") && (member.Service != zeroPortsService) {
+				serviceNames = append(serviceNames, member.Service)
+			}
+			if member.InternalService != "" {
+				serviceNames = append(serviceNames, member.InternalService)
+			}
+		}
+	}
+
+	if !istioEnabled {
+		// Istio mode was turned off.
+		allDeleted := deleteIstioRouting(reqLogger, cr, cr.Status.IstioRouting)
+		for _, serviceName := range serviceNames {
+			if !deleteIstioRouting(reqLogger, cr, serviceName) {
+				allDeleted = false
+			}
+		}
+		if allDeleted {
+			cr.Status.IstioRouting = ""
+		}
+		return
+	}
+
+	// The headless service may have been re-created with a different name.
+	if (cr.Status.IstioRouting != "") && (cr.Status.IstioRouting != cr.Status.ClusterService) {
+		if !deleteIstioRouting(reqLogger, cr, cr.Status.IstioRouting) {
+			return
+		}
+		cr.Status.IstioRouting = ""
+	}
+	if cr.Status.ClusterService == "" {
+		return
+	}
+	// Record the routing name first, so that routing created below for the
+	// other services will be cleaned up even if this pass fails partway.
+	cr.Status.IstioRouting = cr.Status.ClusterService
+	for _, serviceName := range serviceNames {
+		service, queryErr := queryService(reqLogger, cr, serviceName)
+		if (queryErr != nil) || (service == nil) {
+			continue
+		}
+		applyErr := executor.ApplyIstioRouting(reqLogger, cr, service)
+		if applyErr != nil {
+			shared.LogErrorf(
+				reqLogger,
+				applyErr,
+				cr,
+				shared.EventReasonCluster,
+				"failed to apply Istio routing{%s}",
+				serviceName,
+			)
+		}
+	}
+}
+
+// deleteIstioRouting removes the Istio VirtualService and DestinationRule
+// of the given cluster service, if Istio routing may exist for the cluster.
+// It returns false (after logging the error) if they could not be deleted.
+func deleteIstioRouting(
+	reqLogger logr.Logger,
+	cr *kdv1.KubeDirectorCluster,
+	serviceName string,
+) bool {
+
+	if (cr.Status.IstioRouting == "") || (serviceName == "") {
+		return true
+	}
+	deleteErr := executor.DeleteIstioRouting(cr.Namespace, serviceName)
+	if deleteErr != nil {
+		shared.LogErrorf(
+			reqLogger,
+			deleteErr,
+			cr,
+			shared.EventReasonCluster,
+			"failed to delete Istio routing{%s}",
+			serviceName,
+		)
+		return false
+	}
+	return true
+}
+
+// handleMemberService makes sure that the per-member service (and internal
+// service and dashboard routes, if needed) exists if it should. (If it should
+// not, we don't worry about it here... member syncing will clean it up.) If a
+// service is created, it will store this service name in the member status.
+// Failure to create a service as needed will be a reconciler-stopping error.
 func handleMemberService(
 	reqLogger logr.Logger,
 	cr *kdv1.KubeDirectorCluster,
@@ -214,6 +397,98 @@ func handleMemberService(
 				memberService,
 			)
 		}
+		internalErr := handleMemberInternalService(reqLogger, cr, role, member)
+		if internalErr != nil {
+			return internalErr
+		}
+		handleMemberDashboards(reqLogger, cr, role, member)
+	}
+	return nil
+}
+
+// handleMemberInternalService makes sure that the per-member internal service
+// exists if the member's role needs one, and is removed if not. The service
+// name is stored in the member status. Failure to create the service as
+// needed will be a reconciler-stopping error.
+func handleMemberInternalService(
+	reqLogger logr.Logger,
+	cr *kdv1.KubeDirectorCluster,
+	role *roleInfo,
+	member *kdv1.MemberStatus,
+) error {
+
+	needed, neededErr := executor.PodInternalServiceNeeded(cr, role.roleSpec)
+	if neededErr != nil {
+		return neededErr
+	}
+	internalService, queryErr := queryService(
+		reqLogger,
+		cr,
+		member.InternalService,
+	)
+	if queryErr != nil {
+		return queryErr
+	}
+	if !needed {
+		if internalService != nil {
+			shared.LogInfof(
+				reqLogger,
+				cr,
+				shared.EventReasonMember,
+				"deleting internal service{%s} for member{%s}",
+				internalService.Name,
+				member.Pod,
+			)
+			deleteErr := executor.DeletePodService(
+				reqLogger,
+				cr.Namespace,
+				internalService.Name,
+			)
+			if deleteErr != nil && !errors.IsNotFound(deleteErr) {
+				shared.LogErrorf(
+					reqLogger,
+					deleteErr,
+					cr,
+					shared.EventReasonMember,
+					"failed to delete service{%s}",
+					internalService.Name,
+				)
+				return nil
+			}
+		}
+		deleteIstioRouting(reqLogger, cr, member.InternalService)
+		member.InternalService = ""
+		return nil
+	}
+	if internalService != nil {
+		executor.UpdatePodInternalService(
+			reqLogger,
+			cr,
+			role.roleSpec,
+			internalService,
+		)
+		return nil
+	}
+	newService, createErr := executor.CreatePodInternalService(
+		cr,
+		role.roleSpec,
+		member.Pod,
+	)
+	if createErr != nil {
+		shared.LogErrorf(
+			reqLogger,
+			createErr,
+			cr,
+			shared.EventReasonMember,
+			"failed to create internal service for member{%s} in role{%s}",
+			member.Pod,
+			role.roleStatus.Name,
+		)
+		member.InternalService = ""
+		return createErr
+	}
+	if newService != nil {
+		member.InternalService = newService.Name
 	}
 	return nil
 }
@@ -279,8 +554,8 @@ func handleMemberServiceConfig(
 	)
 }
 
-// queryService is a generalized lookup subroutine for finding either
-// a cluster "headless" service or a per-member service. It will return
+// queryService is a generalized lookup subroutine for finding a cluster
+// "headless" service, a per-member service, or a role-wide service. It will return
 // nil for the Service pointer if the object does not exist.
 func queryService(
 	reqLogger logr.Logger,
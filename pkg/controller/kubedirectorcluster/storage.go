@@ -0,0 +1,279 @@
+// Copyright 2022 Hewlett Packard Enterprise Development LP
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubedirectorcluster
+
+import (
+	"github.com/go-logr/logr"
+
+	kdv1 "github.com/bluek8s/kubedirector/pkg/apis/kubedirector/v1beta1"
+	"github.com/bluek8s/kubedirector/pkg/executor"
+	"github.com/bluek8s/kubedirector/pkg/observer"
+	"github.com/bluek8s/kubedirector/pkg/shared"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// handleRoleStorageExpansion grows the PVCs of the role's members when the
+// role spec asks for more storage than the claim templates of the role's
+// statefulset provide. Each member PVC is expanded directly, and the progress
+// of its resize is tracked in the member's state detail. Claim templates
+// cannot be changed in an existing statefulset, so once every member PVC has
+// been expanded and the role is otherwise settled, the role is marked for
+// having its statefulset replaced; see handleRoleStatefulSetReplace.
+func handleRoleStorageExpansion(
+	reqLogger logr.Logger,
+	cr *kdv1.KubeDirectorCluster,
+	role *roleInfo,
+) {
+
+	if (role.roleSpec == nil) || (role.roleSpec.Storage == nil) {
+		return
+	}
+	stale := executor.StatefulSetStorageStale(role.roleSpec, role.statefulSet)
+	desiredSizes := executor.StorageClaimSizes(role.roleSpec)
+	allExpanded := true
+	numMembers := len(role.roleStatus.Members)
+	for i := 0; i < numMembers; i++ {
+		member := &(role.roleStatus.Members[i])
+		switch memberState(member.State) {
+		case memberDeletePending, memberDeleting:
+			continue
+		}
+		// New members are checked too, since they may have reattached
+		// retained PVCs that are smaller than the role's current storage.
+		if !stale && (len(member.StateDetail.StorageResizes) == 0) &&
+			(memberState(member.State) != memberCreatePending) {
+			continue
+		}
+		if !syncMemberStorage(reqLogger, cr, role, member, desiredSizes) {
+			allExpanded = false
+		}
+	}
+	if !stale || !allExpanded || !roleSettled(role) {
+		return
+	}
+	shared.LogInfof(
+		reqLogger,
+		cr,
+		shared.EventReasonRole,
+		"replacing StatefulSet{%s} for role{%s} to use larger volumes",
+		role.statefulSet.Name,
+		role.roleStatus.Name,
+	)
+	role.roleStatus.RecreatingStatefulSet = true
+}
+
+// syncMemberStorage expands any of the member's PVCs that are smaller than
+// the given desired sizes, and records the state of any resize that is still
+// in progress. Returns false if any PVC could not be checked or expanded.
+func syncMemberStorage(
+	reqLogger logr.Logger,
+	cr *kdv1.KubeDirectorCluster,
+	role *roleInfo,
+	member *kdv1.MemberStatus,
+	desiredSizes map[string]resource.Quantity,
+) bool {
+
+	allExpanded := true
+	var resizes []kdv1.PVCResize
+	for _, claimName := range executor.StorageClaimNames(role.roleSpec) {
+		pvcName := executor.MemberPVCName(claimName, member.Pod)
+		pvc, pvcErr := observer.GetPVC(cr.Namespace, pvcName)
+		if pvcErr != nil {
+			if !errors.IsNotFound(pvcErr) {
+				shared.LogErrorf(
+					reqLogger,
+					pvcErr,
+					cr,
+					shared.EventReasonMember,
+					"failed to query PVC{%s}",
+					pvcName,
+				)
+				allExpanded = false
+			}
+			continue
+		}
+		desiredSize := desiredSizes[claimName]
+		requested := pvc.Spec.Resources.Requests[v1.ResourceStorage]
+		if desiredSize.Cmp(requested) > 0 {
+			shared.LogInfof(
+				reqLogger,
+				cr,
+				shared.EventReasonMember,
+				"expanding PVC{%s} for member{%s}: %s -> %s",
+				pvcName,
+				member.Pod,
+				requested.String(),
+				desiredSize.String(),
+			)
+			expandErr := executor.ExpandPVC(pvc, desiredSize)
+			if expandErr != nil {
+				shared.LogErrorf(
+					reqLogger,
+					expandErr,
+					cr,
+					shared.EventReasonMember,
+					"failed to expand PVC{%s}",
+					pvcName,
+				)
+				allExpanded = false
+				continue
+			}
+			requested = desiredSize
+		}
+		if resize := pvcResizeStatus(pvc, requested); resize != nil {
+			resizes = append(resizes, *resize)
+		}
+	}
+	if (len(resizes) == 0) && (len(member.StateDetail.StorageResizes) != 0) {
+		shared.LogInfof(
+			reqLogger,
+			cr,
+			shared.EventReasonMember,
+			"finished expanding storage for member{%s}",
+			member.Pod,
+		)
+	}
+	member.StateDetail.StorageResizes = resizes
+	return allExpanded
+}
+
+// pvcResizeStatus describes the progress of growing the given PVC to the
+// requested size, or returns nil if the PVC already has that capacity and
+// its filesystem has been resized.
+func pvcResizeStatus(
+	pvc *v1.PersistentVolumeClaim,
+	requested resource.Quantity,
+) *kdv1.PVCResize {
+
+	state := kdv1.PVCResizeRequested
+	for _, condition := range pvc.Status.Conditions {
+		if condition.Status != v1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case v1.PersistentVolumeClaimResizing:
+			state = kdv1.PVCResizeResizing
+		case v1.PersistentVolumeClaimFileSystemResizePending:
+			state = kdv1.PVCResizeFileSystemPending
+		}
+	}
+	capacity, hasCapacity := pvc.Status.Capacity[v1.ResourceStorage]
+	if (state == kdv1.PVCResizeRequested) && hasCapacity && (capacity.Cmp(requested) >= 0) {
+		return nil
+	}
+	resize := &kdv1.PVCResize{
+		PVC:       pvc.Name,
+		Requested: requested.String(),
+		State:     state,
+	}
+	if hasCapacity {
+		resize.Capacity = capacity.String()
+	}
+	return resize
+}
+
+// roleSettled returns true if all of the role's members are ready (or in
+// config error), and the role's statefulset has exactly those members.
+func roleSettled(
+	role *roleInfo,
+) bool {
+
+	settled := len(role.membersByState[memberReady]) +
+		len(role.membersByState[memberConfigError])
+	if settled != len(role.roleStatus.Members) {
+		return false
+	}
+	replicas := *(role.statefulSet.Spec.Replicas)
+	return (int(replicas) == settled) && (role.statefulSet.Status.Replicas == replicas)
+}
+
+// handleRoleStatefulSetReplace replaces the statefulset of a role that has
+// been marked for that by handleRoleStorageExpansion. The old statefulset is
+// deleted while leaving its pods running, and once it is gone a new one is
+// created with the same name, the current claim templates, and the current
+// replicas count; it then adopts the existing pods and PVCs. Failure to
+// create the new statefulset will be a reconciler-stopping error.
+func handleRoleStatefulSetReplace(
+	reqLogger logr.Logger,
+	cr *kdv1.KubeDirectorCluster,
+	role *roleInfo,
+	anyMembersChanged *bool,
+) error {
+
+	if role.statefulSet != nil {
+		if role.statefulSet.DeletionTimestamp == nil {
+			deleteErr := executor.DeleteStatefulSetOrphan(
+				cr.Namespace,
+				role.statefulSet.Name,
+			)
+			if deleteErr != nil && !errors.IsNotFound(deleteErr) {
+				shared.LogErrorf(
+					reqLogger,
+					deleteErr,
+					cr,
+					shared.EventReasonRole,
+					"failed to delete StatefulSet{%s}",
+					role.statefulSet.Name,
+				)
+			}
+		}
+		// Wait for k8s to finish removing the old statefulset.
+		return nil
+	}
+
+	role.roleStatus.RecreatingStatefulSet = false
+	if role.roleSpec == nil {
+		// The role was removed from the spec meanwhile, so there is no
+		// template to re-create the statefulset from.
+		return handleRoleReCreate(reqLogger, cr, role, anyMembersChanged)
+	}
+	replicas := int32(len(role.membersByState[memberCreatePending]) +
+		len(role.membersByState[memberCreating]) +
+		len(role.membersByState[memberReady]) +
+		len(role.membersByState[memberConfigError]))
+	statefulSet, createErr := executor.CreateStatefulSet(
+		reqLogger,
+		cr,
+		shared.GetNativeSystemdSupport(),
+		role.roleSpec,
+		role.roleStatus,
+		replicas,
+	)
+	if createErr != nil {
+		role.roleStatus.RecreatingStatefulSet = true
+		shared.LogErrorf(
+			reqLogger,
+			createErr,
+			cr,
+			shared.EventReasonRole,
+			"failed to re-create StatefulSet{%s} for role{%s}",
+			role.roleStatus.StatefulSet,
+			role.roleStatus.Name,
+		)
+		return createErr
+	}
+	role.statefulSet = statefulSet
+	shared.LogInfof(
+		reqLogger,
+		cr,
+		shared.EventReasonRole,
+		"re-created StatefulSet{%s} for role{%s}",
+		statefulSet.Name,
+		role.roleStatus.Name,
+	)
+	return nil
+}
@@ -0,0 +1,188 @@
+// Copyright 2022 Hewlett Packard Enterprise Development LP
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubedirectorcluster
+
+import (
+	"github.com/go-logr/logr"
+
+	kdv1 "github.com/bluek8s/kubedirector/pkg/apis/kubedirector/v1beta1"
+	"github.com/bluek8s/kubedirector/pkg/executor"
+	"github.com/bluek8s/kubedirector/pkg/observer"
+	"github.com/bluek8s/kubedirector/pkg/shared"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// suspendRequested returns true if the cluster spec asks for the cluster to
+// be suspended.
+func suspendRequested(
+	cr *kdv1.KubeDirectorCluster,
+) bool {
+
+	return (cr.Spec.Suspend != nil) && *(cr.Spec.Suspend)
+}
+
+// suspendBlockedReason returns a description of why the cluster cannot be
+// suspended yet, or an empty string if it can. Suspension waits for any
+// in-progress member setup, member deletion, notifications, or app upgrade
+// to finish, since those need the member containers to be running.
+func suspendBlockedReason(
+	cr *kdv1.KubeDirectorCluster,
+) string {
+
+	if cr.Status.UpgradeProgress != nil {
+		return "app upgrade in progress"
+	}
+	for _, roleStatus := range cr.Status.Roles {
+		for _, memberStatus := range roleStatus.Members {
+			switch memberState(memberStatus.State) {
+			case memberCreating, memberDeletePending, memberDeleting:
+				return "member{" + memberStatus.Pod + "} is " + memberStatus.State
+			}
+			if len(memberStatus.StateDetail.PendingNotifyCmds) != 0 {
+				return "member{" + memberStatus.Pod + "} has pending notifications"
+			}
+		}
+	}
+	return ""
+}
+
+// handleSuspend scales the statefulsets of all roles down to zero replicas,
+// once the cluster is in a state where that is safe. PVCs, member services,
+// and member statuses are left alone, aside from recording that the member
+// containers are gone. When the cluster is resumed, checkContainerStates
+// will see that the container ID of each member has changed and move it
+// back to create pending state, from where it will be re-created and have
+// its setup re-checked as for any other restarted member. Returns true if
+// the cluster is being (or has been) suspended, in which case the rest of
+// the reconciliation is skipped.
+func handleSuspend(
+	reqLogger logr.Logger,
+	cr *kdv1.KubeDirectorCluster,
+) (bool, error) {
+
+	if cr.Status.SuspendedTime == nil {
+		if blockedReason := suspendBlockedReason(cr); blockedReason != "" {
+			shared.LogInfof(
+				reqLogger,
+				cr,
+				shared.EventReasonNoEvent,
+				"suspend waiting: %s",
+				blockedReason,
+			)
+			return false, nil
+		}
+		shared.LogInfo(
+			reqLogger,
+			cr,
+			shared.EventReasonCluster,
+			"suspending",
+		)
+		now := metav1.Now()
+		cr.Status.SuspendedTime = &now
+	}
+
+	allPodsGone := true
+	for i := range cr.Status.Roles {
+		roleStatus := &(cr.Status.Roles[i])
+		if roleStatus.StatefulSet == "" {
+			continue
+		}
+		statefulSet, statefulSetErr := observer.GetStatefulSet(
+			cr.Namespace,
+			roleStatus.StatefulSet,
+		)
+		if statefulSetErr != nil {
+			if errors.IsNotFound(statefulSetErr) {
+				continue
+			}
+			return true, statefulSetErr
+		}
+		if *(statefulSet.Spec.Replicas) != 0 {
+			shared.LogInfof(
+				reqLogger,
+				cr,
+				shared.EventReasonRole,
+				"suspending role{%s}",
+				roleStatus.Name,
+			)
+			updateErr := executor.UpdateStatefulSetReplicas(
+				reqLogger,
+				cr,
+				0,
+				statefulSet,
+			)
+			if updateErr != nil {
+				shared.LogErrorf(
+					reqLogger,
+					updateErr,
+					cr,
+					shared.EventReasonRole,
+					"failed to change StatefulSet{%s} replicas",
+					statefulSet.Name,
+				)
+				return true, updateErr
+			}
+		}
+		for j := range roleStatus.Members {
+			memberStatus := &(roleStatus.Members[j])
+			_, podErr := observer.GetPod(cr.Namespace, memberStatus.Pod)
+			if errors.IsNotFound(podErr) {
+				memberStatus.StateDetail.LastKnownContainerState = containerMissing
+			} else {
+				allPodsGone = false
+			}
+		}
+	}
+
+	if !allPodsGone {
+		cr.Status.State = string(clusterUpdating)
+		return true, nil
+	}
+	if cr.Status.State != string(clusterSuspended) {
+		shared.LogInfo(
+			reqLogger,
+			cr,
+			shared.EventReasonCluster,
+			"suspended",
+		)
+		cr.Status.State = string(clusterSuspended)
+		notifyErr := notifyConnectedClusters(reqLogger, cr)
+		if notifyErr != nil {
+			return true, notifyErr
+		}
+	}
+	return true, nil
+}
+
+// handleResumed is called once all members of a resumed cluster are back in
+// ready (or config error) state. It bumps the spec generation so that the
+// members get fresh configmeta, and clears the suspended time. Connected
+// clusters are notified when the cluster next becomes stable.
+func handleResumed(
+	reqLogger logr.Logger,
+	cr *kdv1.KubeDirectorCluster,
+) {
+
+	shared.LogInfo(
+		reqLogger,
+		cr,
+		shared.EventReasonCluster,
+		"resumed; updating member configuration",
+	)
+	incremented := *cr.Status.SpecGenerationToProcess + int64(1)
+	cr.Status.SpecGenerationToProcess = &incremented
+	cr.Status.SuspendedTime = nil
+}
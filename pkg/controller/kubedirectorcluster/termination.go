@@ -0,0 +1,202 @@
+// Copyright 2022 Hewlett Packard Enterprise Development LP
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubedirectorcluster
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	kdv1 "github.com/bluek8s/kubedirector/pkg/apis/kubedirector/v1beta1"
+	"github.com/bluek8s/kubedirector/pkg/executor"
+	"github.com/bluek8s/kubedirector/pkg/shared"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// defaultTerminationMaxRestarts is the restart budget for a member if
+	// neither its role nor the KubeDirectorConfig specifies one.
+	defaultTerminationMaxRestarts = int32(3)
+
+	// terminationRestartResetInterval is how long a restarted member must
+	// stay up before its restart count is reset.
+	terminationRestartResetInterval = 10 * time.Minute
+)
+
+// terminationPolicyForRole returns the termination policy mode and restart
+// budget to use for members of the given role. Any property not set in the
+// role's policy comes from the KubeDirectorConfig default policy, or
+// failing that from the built-in defaults. role may be nil if the role is
+// being deleted.
+func terminationPolicyForRole(
+	role *kdv1.Role,
+) (string, int32) {
+
+	mode := kdv1.TerminationPolicyWait
+	maxRestarts := defaultTerminationMaxRestarts
+	policies := []*kdv1.TerminationPolicy{shared.GetDefaultTerminationPolicy()}
+	if role != nil {
+		policies = append(policies, role.TerminationPolicy)
+	}
+	for _, policy := range policies {
+		if policy == nil {
+			continue
+		}
+		if policy.Mode != nil {
+			mode = *policy.Mode
+		}
+		if policy.MaxRestarts != nil {
+			maxRestarts = *policy.MaxRestarts
+		}
+	}
+	return mode, maxRestarts
+}
+
+// handleTerminatedContainer applies the termination policy of the member's
+// role if the app container of a ready or config error member has
+// terminated; terminated is the container's terminated state, or nil if it
+// is not terminated. Restarting or recreating the member just deletes the
+// pod (and PVC); the member will go back through the create pending and
+// creating states once the replacement container shows up. This function
+// also resets the member's restart count once it has been back up for a
+// while.
+func handleTerminatedContainer(
+	reqLogger logr.Logger,
+	cr *kdv1.KubeDirectorCluster,
+	role *kdv1.Role,
+	memberStatus *kdv1.MemberStatus,
+	pod *corev1.Pod,
+	terminated *corev1.ContainerStateTerminated,
+) {
+
+	if (memberStatus.State != string(memberReady)) &&
+		(memberStatus.State != string(memberConfigError)) {
+		return
+	}
+	stateDetail := &memberStatus.StateDetail
+	now := metav1.Now()
+	if terminated == nil {
+		if (memberStatus.State == string(memberReady)) &&
+			(stateDetail.LastKnownContainerState == containerRunning) &&
+			(stateDetail.RestartCount != 0) &&
+			(stateDetail.LastRestartTime != nil) &&
+			(now.Sub(stateDetail.LastRestartTime.Time) > terminationRestartResetInterval) {
+			stateDetail.RestartCount = 0
+		}
+		return
+	}
+
+	terminatedTime := terminated.FinishedAt
+	if terminatedTime.IsZero() {
+		terminatedTime = now
+	}
+	stateDetail.LastTerminatedTime = &terminatedTime
+
+	// Don't act on a pod that is already going away, or on a termination
+	// that we have already acted on.
+	if pod.DeletionTimestamp != nil {
+		return
+	}
+	if (stateDetail.LastRestartTime != nil) &&
+		!stateDetail.LastRestartTime.Before(&terminatedTime) {
+		return
+	}
+
+	mode, maxRestarts := terminationPolicyForRole(role)
+	if mode == kdv1.TerminationPolicyWait {
+		return
+	}
+	if (mode == kdv1.TerminationPolicyMarkConfigError) ||
+		(stateDetail.RestartCount >= maxRestarts) {
+		if memberStatus.State == string(memberConfigError) {
+			return
+		}
+		var errorDetail string
+		if mode == kdv1.TerminationPolicyMarkConfigError {
+			errorDetail = "app container terminated"
+		} else {
+			errorDetail = fmt.Sprintf(
+				"app container terminated; restart budget of %d used up",
+				maxRestarts,
+			)
+		}
+		shared.LogInfof(
+			reqLogger,
+			cr,
+			shared.EventReasonMember,
+			"member{%s} moved to config error state: %s",
+			memberStatus.Pod,
+			errorDetail,
+		)
+		memberStatus.State = string(memberConfigError)
+		stateDetail.ConfigErrorDetail = &errorDetail
+		return
+	}
+
+	if (mode == kdv1.TerminationPolicyRecreateMember) && (memberStatus.PVC != "") {
+		shared.LogInfof(
+			reqLogger,
+			cr,
+			shared.EventReasonMember,
+			"app container terminated for member{%s}; recreating member",
+			memberStatus.Pod,
+		)
+		for _, pvcName := range executor.MemberPVCs(memberStatus) {
+			pvcDelErr := executor.DeletePVC(cr.Namespace, pvcName)
+			if (pvcDelErr != nil) && !apierrors.IsNotFound(pvcDelErr) {
+				shared.LogErrorf(
+					reqLogger,
+					pvcDelErr,
+					cr,
+					shared.EventReasonMember,
+					"failed to delete PVC{%s}",
+					pvcName,
+				)
+				return
+			}
+		}
+		// The persistent storage is going away, so any previously uploaded
+		// stuff will be lost and setup must start fresh.
+		stateDetail.LastConfigDataGeneration = nil
+		stateDetail.LastSetupGeneration = nil
+		stateDetail.ConfiguredApp = ""
+		stateDetail.PendingNotifyCmds = []*kdv1.NotificationDesc{}
+	} else {
+		shared.LogInfof(
+			reqLogger,
+			cr,
+			shared.EventReasonMember,
+			"app container terminated for member{%s}; restarting pod",
+			memberStatus.Pod,
+		)
+	}
+	podDelErr := executor.DeletePod(cr.Namespace, memberStatus.Pod)
+	if (podDelErr != nil) && !apierrors.IsNotFound(podDelErr) {
+		shared.LogErrorf(
+			reqLogger,
+			podDelErr,
+			cr,
+			shared.EventReasonMember,
+			"failed to restart member{%s}",
+			memberStatus.Pod,
+		)
+		return
+	}
+	stateDetail.RestartCount++
+	stateDetail.LastRestartTime = &now
+}
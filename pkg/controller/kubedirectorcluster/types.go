@@ -23,12 +23,14 @@ import (
 type clusterState string
 
 const (
-	clusterCreating clusterState = "creating"
-	clusterUpdating              = "updating"
-	clusterReady                 = "configured"
+	clusterCreating  clusterState = "creating"
+	clusterUpdating               = "updating"
+	clusterReady                  = "configured"
+	clusterSuspended              = "suspended"
 	// ClusterSpecModified is exported because it is actually only used by
-	// the validator; declaring it here just to keep all cluster states in
-	// one spot.
+	// the validator and by ClusterBusyReason (for the role scaler and backup
+	// controller); declaring it here just to keep all cluster states in one
+	// spot.
 	ClusterSpecModified = "spec modified"
 )
 
@@ -66,6 +68,7 @@ const (
 	containerWaiting      = "waiting"
 	containerInitializing = "initializing"
 	containerUnresponsive = "unresponsive"
+	containerDegraded     = "degraded"
 	containerTerminated   = "terminated"
 	containerMissing      = "absent"
 	containerUnknown      = "unknown"
@@ -82,7 +85,7 @@ const (
 	ln -sf %[2]s/bin/configcli %[2]s/bin/bd_vcli`
 	configcliTestFile       = shared.ConfigCliLoc + "/bin/configcli"
 	configcliLegacyTestFile = shared.ConfigCliLegacyLoc + "/bin/configcli"
-	appPrepStartscript      = "/opt/guestconfig/*/startscript"
+	appPrepStartscript      = shared.AppStartscript
 	appPrepInitCmdFmt       = `mkdir -p /opt/guestconfig &&
 	chmod 700 /opt/guestconfig &&
 	cd /opt/guestconfig &&
@@ -99,6 +102,16 @@ const (
 	nohup sh -c '` + appPrepStartscript +
 		` --configure 2>` + appPrepConfigStderr + ` 1>` + appPrepConfigStdout + `;
 	echo -n $? >> ` + appPrepConfigStatus + `' &`
+	appPrepUpgradeRunCmd = `rm -f /opt/guestconfig/configure.* &&
+	echo -n %s= > ` + appPrepConfigStatus + ` &&
+	nohup sh -c '` + appPrepStartscript +
+		` --upgrade --fromapp %s%s 2>` + appPrepConfigStderr + ` 1>` + appPrepConfigStdout + `;
+	echo -n $? >> ` + appPrepConfigStatus + `' &`
+	appPrepClonedRunCmd = `rm -f /opt/guestconfig/configure.* &&
+	echo -n %s= > ` + appPrepConfigStatus + ` &&
+	nohup sh -c '` + appPrepStartscript +
+		` --cloned --fromcluster %s 2>` + appPrepConfigStderr + ` 1>` + appPrepConfigStdout + `;
+	echo -n $? >> ` + appPrepConfigStatus + `' &`
 	fileInjectionCommand = `mkdir -p %s && cd %s &&
 	curl -L %s -o %s`
 	appPrepConfigReconnectCmd = `echo -n %s= > ` + appPrepConfigStatus + ` &&
@@ -0,0 +1,194 @@
+// Copyright 2022 Hewlett Packard Enterprise Development LP
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubedirectorcluster
+
+import (
+	"github.com/go-logr/logr"
+
+	kdv1 "github.com/bluek8s/kubedirector/pkg/apis/kubedirector/v1beta1"
+	"github.com/bluek8s/kubedirector/pkg/executor"
+	"github.com/bluek8s/kubedirector/pkg/shared"
+)
+
+// checkAppUpgrade notices when the cluster spec has moved to a different app
+// and maintains the upgradeProgress status stanza accordingly: starting an
+// upgrade, turning it into a rollback if the spec reverts to the previous
+// app, counting upgraded members, and finally clearing the stanza (and the
+// reference to the previous app) once every member has been configured with
+// the target app. It must be called before any statefulsets are modified for
+// the new app in this handler pass.
+func checkAppUpgrade(
+	reqLogger logr.Logger,
+	cr *kdv1.KubeDirectorCluster,
+	roles []*roleInfo,
+) {
+
+	// Members configured by a KubeDirector version that did not track the
+	// configured app have an empty configuredApp. Ready (or config error)
+	// members must have been configured using whatever app their statefulset
+	// was last generated from, so fill that in.
+	for _, r := range roles {
+		if (r.statefulSet == nil) || (r.roleStatus == nil) {
+			continue
+		}
+		statefulSetApp := r.statefulSet.Labels[executor.ClusterAppLabel]
+		if statefulSetApp == "" {
+			continue
+		}
+		for _, state := range []memberState{memberReady, memberConfigError} {
+			for _, m := range r.membersByState[state] {
+				if (m.StateDetail.ConfiguredApp == "") &&
+					(m.StateDetail.LastConfiguredContainer != "") {
+					m.StateDetail.ConfiguredApp = statefulSetApp
+				}
+			}
+		}
+	}
+
+	upgrade := cr.Status.UpgradeProgress
+	if upgrade == nil {
+		// Is there anything still using some other app?
+		prevApp := ""
+		for _, r := range roles {
+			if (r.statefulSet != nil) && (r.roleSpec != nil) {
+				statefulSetApp := r.statefulSet.Labels[executor.ClusterAppLabel]
+				if (statefulSetApp != "") && (statefulSetApp != cr.Spec.AppID) {
+					prevApp = statefulSetApp
+					break
+				}
+			}
+		}
+		for _, members := range calcMembersForRoles(roles) {
+			for _, m := range members {
+				if (m.StateDetail.ConfiguredApp != "") &&
+					(m.StateDetail.ConfiguredApp != cr.Spec.AppID) {
+					prevApp = m.StateDetail.ConfiguredApp
+					break
+				}
+			}
+		}
+		if prevApp == "" {
+			return
+		}
+		shared.LogInfof(
+			reqLogger,
+			cr,
+			shared.EventReasonCluster,
+			"upgrading from app{%s} to app{%s}",
+			prevApp,
+			cr.Spec.AppID,
+		)
+		upgrade = &kdv1.UpgradeProgress{
+			PrevApp:   prevApp,
+			TargetApp: cr.Spec.AppID,
+		}
+		cr.Status.UpgradeProgress = upgrade
+		shared.EnsureClusterAppReference(
+			cr.Namespace,
+			cr.Name,
+			*(cr.Spec.AppCatalog),
+			cr.Spec.AppID,
+		)
+	} else if upgrade.TargetApp != cr.Spec.AppID {
+		if upgrade.PrevApp == cr.Spec.AppID {
+			shared.LogInfof(
+				reqLogger,
+				cr,
+				shared.EventReasonCluster,
+				"rolling back from app{%s} to app{%s}",
+				upgrade.TargetApp,
+				upgrade.PrevApp,
+			)
+			upgrade.PrevApp = upgrade.TargetApp
+			upgrade.RollingBack = !upgrade.RollingBack
+		} else {
+			// The validator shouldn't allow this, but if it happens treat
+			// it as a new target for the current upgrade. Members track
+			// their own configured app so each will get the correct
+			// upgrade event.
+			shared.LogInfof(
+				reqLogger,
+				cr,
+				shared.EventReasonCluster,
+				"changing upgrade target from app{%s} to app{%s}",
+				upgrade.TargetApp,
+				cr.Spec.AppID,
+			)
+			shared.RemoveClusterAppReference(
+				cr.Namespace,
+				cr.Name,
+				*(cr.Spec.AppCatalog),
+				upgrade.TargetApp,
+			)
+			shared.EnsureClusterAppReference(
+				cr.Namespace,
+				cr.Name,
+				*(cr.Spec.AppCatalog),
+				cr.Spec.AppID,
+			)
+		}
+		upgrade.TargetApp = cr.Spec.AppID
+		upgrade.FailedMembers = nil
+	}
+
+	// Count progress, and see if we are done.
+	allStatefulSetsMoved := true
+	var totalMembers int32
+	var upgradedMembers int32
+	for _, r := range roles {
+		if (r.statefulSet != nil) && (r.roleSpec != nil) {
+			if r.statefulSet.Labels[executor.ClusterAppLabel] != upgrade.TargetApp {
+				allStatefulSetsMoved = false
+			}
+		}
+	}
+	for _, members := range calcMembersForRoles(roles) {
+		for _, m := range members {
+			totalMembers++
+			if m.StateDetail.ConfiguredApp == upgrade.TargetApp {
+				upgradedMembers++
+			}
+		}
+	}
+	upgrade.TotalMembers = totalMembers
+	upgrade.UpgradedMembers = upgradedMembers
+	if !allStatefulSetsMoved || (upgradedMembers != totalMembers) {
+		return
+	}
+	if upgrade.RollingBack {
+		shared.LogInfof(
+			reqLogger,
+			cr,
+			shared.EventReasonCluster,
+			"rollback to app{%s} complete",
+			upgrade.TargetApp,
+		)
+	} else {
+		shared.LogInfof(
+			reqLogger,
+			cr,
+			shared.EventReasonCluster,
+			"upgrade to app{%s} complete",
+			upgrade.TargetApp,
+		)
+	}
+	shared.RemoveClusterAppReference(
+		cr.Namespace,
+		cr.Name,
+		*(cr.Spec.AppCatalog),
+		upgrade.PrevApp,
+	)
+	cr.Status.UpgradeProgress = nil
+}
@@ -15,11 +15,23 @@
 package kubedirectorcluster
 
 import (
+	"fmt"
+	"regexp"
+	"strings"
+
 	kdv1 "github.com/bluek8s/kubedirector/pkg/apis/kubedirector/v1beta1"
+	"github.com/bluek8s/kubedirector/pkg/shared"
 	corev1 "k8s.io/api/core/v1"
 )
 
-// updateSchedulingErrorMessage updates MemberStateDetails with SchedulingErrorMessage
+// schedulerTaintRegexp matches the taint descriptions in an Unschedulable
+// pod condition message, e.g. "had taint {dedicated: gpu}, that the pod
+// didn't tolerate" or "had untolerated taint {dedicated: gpu}".
+var schedulerTaintRegexp = regexp.MustCompile(`taint (\{[^}]*\})`)
+
+// updateSchedulingErrorMessage updates MemberStateDetails with SchedulingErrorMessage.
+// If the pod could not be scheduled on some nodes because it does not
+// tolerate their taints, the message calls that out specifically.
 func updateSchedulingErrorMessage(
 	pod *corev1.Pod,
 	memberStatus *kdv1.MemberStatus,
@@ -29,9 +41,42 @@ func updateSchedulingErrorMessage(
 		for _, condition := range pod.Status.Conditions {
 			if condition.Type == corev1.PodScheduled {
 				if condition.Reason == corev1.PodReasonUnschedulable {
-					memberStatus.StateDetail.SchedulingErrorMessage = &condition.Message
+					message := condition.Message
+					if taintMessage := taintSchedulingMessage(message); taintMessage != "" {
+						message = taintMessage
+					}
+					memberStatus.StateDetail.SchedulingErrorMessage = &message
 				}
 			}
 		}
 	}
 }
+
+// taintSchedulingMessage returns a description of a taint/toleration
+// mismatch reported in the given scheduler message, or an empty string if
+// the scheduler did not report any untolerated taints.
+func taintSchedulingMessage(
+	message string,
+) string {
+
+	if !strings.Contains(message, "taint") {
+		return ""
+	}
+	var taints []string
+	for _, match := range schedulerTaintRegexp.FindAllStringSubmatch(message, -1) {
+		if !shared.StringInList(match[1], taints) {
+			taints = append(taints, match[1])
+		}
+	}
+	if len(taints) == 0 {
+		return fmt.Sprintf(
+			"pod does not tolerate the taints of some nodes; check the role tolerations (%s)",
+			message,
+		)
+	}
+	return fmt.Sprintf(
+		"pod does not tolerate node taint(s) %s; check the role tolerations (%s)",
+		strings.Join(taints, ", "),
+		message,
+	)
+}
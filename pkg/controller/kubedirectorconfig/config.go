@@ -25,6 +25,7 @@ import (
 	"github.com/bluek8s/kubedirector/pkg/shared"
 	"github.com/go-logr/logr"
 	"github.com/google/uuid"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 )
@@ -61,6 +62,7 @@ func (r *ReconcileKubeDirectorConfig) syncConfig(
 
 	// Set a defer func to write new status and/or finalizers if they change.
 	defer func() {
+		updateConditions(cr)
 		nowHasFinalizer := shared.HasFinalizer(cr)
 		// Bail out if nothing has changed. Note that if we are deleting we
 		// don't care if status has changed.
@@ -185,6 +187,33 @@ func (r *ReconcileKubeDirectorConfig) syncConfig(
 	return nil
 }
 
+// updateConditions sets the standard status conditions for the config,
+// based on its current state.
+func updateConditions(
+	cr *kdv1.KubeDirectorConfig,
+) {
+
+	if cr.Status.State == string(configReady) {
+		shared.SetCondition(
+			&cr.Status.Conditions,
+			kdv1.ConfigConditionReady,
+			corev1.ConditionTrue,
+			"Ready",
+			"",
+			cr.Generation,
+		)
+	} else {
+		shared.SetCondition(
+			&cr.Status.Conditions,
+			kdv1.ConfigConditionReady,
+			corev1.ConditionFalse,
+			"Creating",
+			fmt.Sprintf("config state is %s", cr.Status.State),
+			cr.Generation,
+		)
+	}
+}
+
 // ensureMasterKey is used to populate the masterEncryptionKey field on
 // incoming config CRs where it is nil. In the normal course of things the
 // webhook populates this default value, but in upgrade cases it might be
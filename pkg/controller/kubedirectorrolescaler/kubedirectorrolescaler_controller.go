@@ -0,0 +1,128 @@
+// Copyright 2022 Hewlett Packard Enterprise Development LP
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubedirectorrolescaler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	kdv1 "github.com/bluek8s/kubedirector/pkg/apis/kubedirector/v1beta1"
+	"github.com/bluek8s/kubedirector/pkg/shared"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+var log = logf.Log.WithName("controller_kubedirectorrolescaler")
+
+// Add creates a new KubeDirectorRoleScaler Controller and adds it to the
+// Manager. The Manager will set fields on the Controller and Start it when
+// the Manager is Started.
+func Add(
+	mgr manager.Manager,
+) error {
+
+	return add(mgr, newReconciler(mgr))
+}
+
+// newReconciler returns a new reconcile.Reconciler.
+func newReconciler(
+	mgr manager.Manager,
+) reconcile.Reconciler {
+
+	return &ReconcileKubeDirectorRoleScaler{scheme: mgr.GetScheme()}
+}
+
+// add adds a new Controller to mgr with r as the reconcile.Reconciler.
+func add(
+	mgr manager.Manager,
+	r reconcile.Reconciler,
+) error {
+
+	// Create a new controller
+	c, err := controller.New("kubedirectorrolescaler-controller", mgr, controller.Options{Reconciler: r})
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to primary resource KubeDirectorRoleScaler.
+	err = c.Watch(&source.Kind{Type: &kdv1.KubeDirectorRoleScaler{}}, &handler.EnqueueRequestForObject{})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// blank assignment to verify that ReconcileKubeDirectorRoleScaler implements
+// reconcile.Reconciler.
+var _ reconcile.Reconciler = &ReconcileKubeDirectorRoleScaler{}
+
+const (
+	// Period between the time when the controller requeues a request and
+	// it's scheduled again for reconciliation. Scalers are polled because
+	// whether a resize can proceed depends on the state of the kdcluster,
+	// which we don't watch here.
+	reconcilePeriod = 30 * time.Second
+)
+
+// ReconcileKubeDirectorRoleScaler reconciles a KubeDirectorRoleScaler object.
+type ReconcileKubeDirectorRoleScaler struct {
+	scheme *runtime.Scheme
+}
+
+// Reconcile reads that state of the cluster for a KubeDirectorRoleScaler
+// object and makes changes based on the state read and what is in the
+// KubeDirectorRoleScaler.Spec.
+// Note:
+// The Controller will requeue the Request to be processed again if the
+// returned error is non-nil or Result.Requeue is true, otherwise upon
+// completion it will remove the work from the queue.
+func (r *ReconcileKubeDirectorRoleScaler) Reconcile(
+	request reconcile.Request,
+) (reconcile.Result, error) {
+
+	reqLogger := log.WithValues("Request.Namespace", request.Namespace, "Request.Name", request.Name)
+	reconcileResult := reconcile.Result{RequeueAfter: reconcilePeriod}
+
+	// Fetch the KubeDirectorRoleScaler instance.
+	cr := &kdv1.KubeDirectorRoleScaler{}
+	err := shared.Get(context.TODO(), request.NamespacedName, cr)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			// Request object not found, could have been deleted after
+			// reconcile request. Deleting a scaler leaves its role at
+			// whatever size it currently has, so there is no cleanup to do.
+			// Return and don't requeue.
+			return reconcile.Result{}, nil
+		}
+		// Error reading the object - requeue the request.
+		return reconcileResult,
+			fmt.Errorf("could not fetch KubeDirectorRoleScaler instance: %s", err)
+	}
+
+	retryAfter, err := r.syncRoleScaler(reqLogger, cr)
+	if (retryAfter > 0) && (retryAfter < reconcilePeriod) {
+		reconcileResult.RequeueAfter = retryAfter
+	}
+	return reconcileResult, err
+}
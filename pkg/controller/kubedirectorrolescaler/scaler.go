@@ -0,0 +1,348 @@
+// Copyright 2022 Hewlett Packard Enterprise Development LP
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubedirectorrolescaler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	kdv1 "github.com/bluek8s/kubedirector/pkg/apis/kubedirector/v1beta1"
+	"github.com/bluek8s/kubedirector/pkg/catalog"
+	"github.com/bluek8s/kubedirector/pkg/controller/kubedirectorcluster"
+	"github.com/bluek8s/kubedirector/pkg/executor"
+	"github.com/bluek8s/kubedirector/pkg/observer"
+	"github.com/bluek8s/kubedirector/pkg/shared"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// defaultCooldownSeconds is the minimum time between resizes made through a
+// scaler that does not specify its own cooldown.
+const defaultCooldownSeconds = int32(60)
+
+// conflictRetryInterval is how soon a scaler tries again if the kdcluster
+// was changed by someone else while the scaler was resizing it.
+const conflictRetryInterval = time.Second
+
+// syncRoleScaler runs the reconciliation logic. It is invoked because of a
+// change in or addition of a KubeDirectorRoleScaler instance, or a periodic
+// polling to check on such a resource. If the scaler must wait for some
+// time (rather than for a change in the kdcluster) before it can resize its
+// role, that wait is returned so that the reconciler can check back sooner
+// than the polling period.
+//
+// A role is only resized by changing the members count in the kdcluster
+// spec, so the resize is carried out (and validated) exactly as if a user
+// had made that edit. To keep from stacking up resizes, a scaler does not
+// change the spec while a previous spec change is still being processed,
+// while members are being created or deleted, or while members have pending
+// notifications; and it waits at least its cooldown period after its own
+// previous resize.
+func (r *ReconcileKubeDirectorRoleScaler) syncRoleScaler(
+	reqLogger logr.Logger,
+	cr *kdv1.KubeDirectorRoleScaler,
+) (retryAfter time.Duration, err error) {
+
+	// Memoize state of the incoming object.
+	oldStatus := cr.Status.DeepCopy()
+
+	// Make sure we have a Status object to work with.
+	if cr.Status == nil {
+		cr.Status = &kdv1.KubeDirectorRoleScalerStatus{}
+	}
+
+	// Set a defer func to write new status if it changes. If this fails the
+	// reconciler will requeue the scaler and we'll try again.
+	defer func() {
+		if equality.Semantic.DeepEqual(cr.Status, oldStatus) {
+			return
+		}
+		updateErr := shared.StatusUpdate(context.TODO(), cr)
+		if updateErr != nil {
+			shared.LogErrorf(
+				reqLogger,
+				updateErr,
+				cr,
+				shared.EventReasonRoleScaler,
+				"failed to update status",
+			)
+			if err == nil {
+				err = updateErr
+			}
+		}
+	}()
+
+	setReady := func(
+		status corev1.ConditionStatus,
+		reason string,
+		format string,
+		args ...interface{},
+	) {
+		shared.SetCondition(
+			&cr.Status.Conditions,
+			kdv1.RoleScalerConditionReady,
+			status,
+			reason,
+			fmt.Sprintf(format, args...),
+			cr.Generation,
+		)
+	}
+	setLimited := func(
+		status corev1.ConditionStatus,
+		reason string,
+		format string,
+		args ...interface{},
+	) {
+		shared.SetCondition(
+			&cr.Status.Conditions,
+			kdv1.RoleScalerConditionLimited,
+			status,
+			reason,
+			fmt.Sprintf(format, args...),
+			cr.Generation,
+		)
+	}
+
+	cr.Status.Selector = labels.SelectorFromSet(
+		labels.Set{
+			shared.ClusterLabel:       cr.Spec.Cluster,
+			executor.ClusterRoleLabel: cr.Spec.Role,
+		},
+	).String()
+
+	cluster, clusterErr := observer.GetCluster(cr.Namespace, cr.Spec.Cluster)
+	if clusterErr != nil {
+		if errors.IsNotFound(clusterErr) {
+			cr.Status.Replicas = 0
+			setReady(
+				corev1.ConditionFalse,
+				"ClusterNotFound",
+				"kdcluster{%s} not found",
+				cr.Spec.Cluster,
+			)
+			return 0, nil
+		}
+		return 0, clusterErr
+	}
+
+	// Find the role in the kdcluster spec and status.
+	roleIndex := -1
+	for i, role := range cluster.Spec.Roles {
+		if role.Name == cr.Spec.Role {
+			roleIndex = i
+			break
+		}
+	}
+	if roleIndex == -1 {
+		cr.Status.Replicas = 0
+		setReady(
+			corev1.ConditionFalse,
+			"RoleNotFound",
+			"role{%s} not found in kdcluster{%s}",
+			cr.Spec.Role,
+			cr.Spec.Cluster,
+		)
+		return 0, nil
+	}
+	role := &(cluster.Spec.Roles[roleIndex])
+	cr.Status.Replicas = 0
+	if cluster.Status != nil {
+		for _, roleStatus := range cluster.Status.Roles {
+			if roleStatus.Name == cr.Spec.Role {
+				cr.Status.Replicas = int32(len(roleStatus.Members))
+				break
+			}
+		}
+	}
+
+	if cr.Spec.Replicas == nil {
+		setLimited(corev1.ConditionFalse, "NotScaling", "")
+		setReady(
+			corev1.ConditionTrue,
+			"NotScaling",
+			"no member count requested",
+		)
+		return 0, nil
+	}
+
+	// Work out the member count to use, given the role cardinality and the
+	// total member limit for the kdcluster.
+	appCR, appErr := catalog.GetApp(cluster)
+	if appErr != nil {
+		setReady(corev1.ConditionFalse, "AppNotFound", "%v", appErr)
+		return 0, nil
+	}
+	appRole := catalog.GetRoleFromID(appCR, cr.Spec.Role)
+	if appRole == nil {
+		setReady(
+			corev1.ConditionFalse,
+			"RoleNotFound",
+			"role{%s} not found in kdapp{%s}",
+			cr.Spec.Role,
+			cluster.Spec.AppID,
+		)
+		return 0, nil
+	}
+	minMembers, isScaleOut := catalog.GetRoleCardinality(appRole)
+	if !isScaleOut {
+		setReady(
+			corev1.ConditionFalse,
+			"RoleNotScalable",
+			"role{%s} has a fixed cardinality of %d",
+			cr.Spec.Role,
+			minMembers,
+		)
+		return 0, nil
+	}
+	otherMembers := int32(0)
+	for i, otherRole := range cluster.Spec.Roles {
+		if (i != roleIndex) && (otherRole.Members != nil) {
+			otherMembers += *otherRole.Members
+		}
+	}
+	maxMembers := shared.MaxKDMembers - otherMembers
+	targetMembers := *cr.Spec.Replicas
+	if targetMembers < minMembers {
+		targetMembers = minMembers
+		setLimited(
+			corev1.ConditionTrue,
+			"BelowMinimum",
+			"requested %d members but role{%s} needs at least %d",
+			*cr.Spec.Replicas,
+			cr.Spec.Role,
+			minMembers,
+		)
+	} else if targetMembers > maxMembers {
+		targetMembers = maxMembers
+		setLimited(
+			corev1.ConditionTrue,
+			"AboveMaximum",
+			"requested %d members but kdcluster{%s} can have at most %d members in total",
+			*cr.Spec.Replicas,
+			cr.Spec.Cluster,
+			shared.MaxKDMembers,
+		)
+	} else {
+		setLimited(corev1.ConditionFalse, "WithinLimits", "")
+	}
+
+	currentMembers := int32(0)
+	if role.Members != nil {
+		currentMembers = *role.Members
+	}
+	if targetMembers == currentMembers {
+		if cr.Status.Replicas == targetMembers {
+			setReady(
+				corev1.ConditionTrue,
+				"Scaled",
+				"role{%s} has %d members",
+				cr.Spec.Role,
+				targetMembers,
+			)
+		} else {
+			setReady(
+				corev1.ConditionFalse,
+				"Resizing",
+				"role{%s} is being resized to %d members",
+				cr.Spec.Role,
+				targetMembers,
+			)
+		}
+		return 0, nil
+	}
+
+	// A resize is needed. Hold off if the kdcluster is busy with something
+	// else or if we resized recently.
+	if busy := kubedirectorcluster.ClusterBusyReason(cluster, false); busy != "" {
+		setReady(corev1.ConditionFalse, "Waiting", "%s", busy)
+		return 0, nil
+	}
+	cooldown := defaultCooldownSeconds
+	if cr.Spec.CooldownSeconds != nil {
+		cooldown = *cr.Spec.CooldownSeconds
+	}
+	now := metav1.Now()
+	if cr.Status.LastScaleTime != nil {
+		nextScaleTime := cr.Status.LastScaleTime.Add(time.Duration(cooldown) * time.Second)
+		if now.Time.Before(nextScaleTime) {
+			setReady(
+				corev1.ConditionFalse,
+				"CoolingDown",
+				"next resize of role{%s} allowed at %s",
+				cr.Spec.Role,
+				nextScaleTime.UTC().Format(time.RFC3339),
+			)
+			return nextScaleTime.Sub(now.Time), nil
+		}
+	}
+
+	// Change the members count in the kdcluster spec. The kdcluster
+	// validator still has the final say on whether this is OK. This is an
+	// update rather than a patch, so that it fails if the kdcluster has been
+	// changed since we read it (for example by another scaler or by a user
+	// edit) instead of overwriting the roles array with our stale copy. In
+	// that case we'll come back soon and work from the current kdcluster.
+	updatedCluster := cluster.DeepCopy()
+	updatedCluster.Spec.Roles[roleIndex].Members = &targetMembers
+	updateErr := shared.Update(context.TODO(), updatedCluster)
+	if updateErr != nil {
+		if errors.IsConflict(updateErr) {
+			shared.LogInfof(
+				reqLogger,
+				cr,
+				shared.EventReasonNoEvent,
+				"kdcluster{%s} changed while resizing role{%s}; will retry",
+				cr.Spec.Cluster,
+				cr.Spec.Role,
+			)
+			return conflictRetryInterval, nil
+		}
+		shared.LogErrorf(
+			reqLogger,
+			updateErr,
+			cr,
+			shared.EventReasonRoleScaler,
+			"failed to resize role{%s} of kdcluster{%s}",
+			cr.Spec.Role,
+			cr.Spec.Cluster,
+		)
+		setReady(corev1.ConditionFalse, "ResizeFailed", "%v", updateErr)
+		return 0, nil
+	}
+	shared.LogInfof(
+		reqLogger,
+		cr,
+		shared.EventReasonRoleScaler,
+		"resizing role{%s} of kdcluster{%s} from %d to %d members",
+		cr.Spec.Role,
+		cr.Spec.Cluster,
+		currentMembers,
+		targetMembers,
+	)
+	cr.Status.LastScaleTime = &now
+	setReady(
+		corev1.ConditionFalse,
+		"Resizing",
+		"role{%s} is being resized to %d members",
+		cr.Spec.Role,
+		targetMembers,
+	)
+	return 0, nil
+}
@@ -251,7 +251,7 @@ func UpdateStorageInitProgress(
 		cr.Namespace,
 		(*memberStatus).Pod,
 		initContainerStatus.ContainerID,
-		initContainerName,
+		InitContainerName,
 		progressBarFile,
 	)
 	if fileExistsErr != nil {
@@ -284,7 +284,7 @@ func UpdateStorageInitProgress(
 		cr.Namespace,
 		(*memberStatus).Pod,
 		initContainerStatus.ContainerID,
-		initContainerName,
+		InitContainerName,
 		[]string{"tail", "-c", "1024", progressBarFile},
 		ioStreams,
 	)
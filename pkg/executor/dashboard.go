@@ -0,0 +1,367 @@
+// Copyright 2022 Hewlett Packard Enterprise Development LP
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package executor
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+
+	kdv1 "github.com/bluek8s/kubedirector/pkg/apis/kubedirector/v1beta1"
+	"github.com/bluek8s/kubedirector/pkg/catalog"
+	"github.com/bluek8s/kubedirector/pkg/observer"
+	"github.com/bluek8s/kubedirector/pkg/shared"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// DashboardRoute describes the Ingress or HTTPRoute that should expose one
+// dashboard endpoint of a member: the host name to use, and the member
+// service and port to send requests to. Dashboard is the corresponding
+// entry for the member status.
+type DashboardRoute struct {
+	Dashboard kdv1.MemberDashboard
+	Host      string
+	Backend   string
+	Port      int32
+}
+
+// DashboardRoutesForMember determines the Ingress or HTTPRoute objects that
+// should expose the dashboard endpoints of the given member, according to
+// the dashboardIngress settings of the KubeDirectorConfig. An endpoint gets
+// one if it is a dashboard that does not have internal exposure, once the
+// member service that carries its port exists. If the host pattern does not
+// use the {service} placeholder and the role has more than one dashboard,
+// the service ID is prepended to each host name to keep them distinct. The
+// result is empty if the config does not ask for dashboard ingress.
+func DashboardRoutesForMember(
+	cr *kdv1.KubeDirectorCluster,
+	role *kdv1.Role,
+	member *kdv1.MemberStatus,
+) ([]DashboardRoute, error) {
+
+	settings := shared.GetDashboardIngress()
+	if settings == nil {
+		return nil, nil
+	}
+	portInfoList, portsErr := catalog.PortsForRole(cr, role.Name)
+	if portsErr != nil {
+		return nil, portsErr
+	}
+	_, mainPorts, _, _ := podServicePorts(cr, role)
+	var dashboards []catalog.ServicePortInfo
+	for _, portInfo := range portInfoList {
+		if portInfo.IsDashboard && (portInfo.Exposure != kdv1.ServiceExposureInternal) {
+			dashboards = append(dashboards, portInfo)
+		}
+	}
+
+	kind := shared.IngressKind
+	scheme := "http"
+	if settings.Mode == kdv1.DashboardIngressModeGateway {
+		kind = shared.HTTPRouteKind
+		if settings.GatewayTLS {
+			scheme = "https"
+		}
+	} else if settings.TLSSecretName != nil {
+		scheme = "https"
+	}
+	prefixService := (len(dashboards) > 1) &&
+		!strings.Contains(settings.HostPattern, "{service}")
+
+	var result []DashboardRoute
+	for _, portInfo := range dashboards {
+		backend := member.InternalService
+		for _, port := range mainPorts {
+			if port.Port == portInfo.Port {
+				backend = member.Service
+				break
+			}
+		}
+		if backend == "" {
+			continue
+		}
+		host := strings.NewReplacer(
+			"{member}", member.Pod,
+			"{role}", role.Name,
+			"{cluster}", cr.Name,
+			"{namespace}", cr.Namespace,
+			"{service}", portInfo.ID,
+		).Replace(settings.HostPattern)
+		if prefixService {
+			host = portInfo.ID + "-" + host
+		}
+		result = append(
+			result,
+			DashboardRoute{
+				Dashboard: kdv1.MemberDashboard{
+					Service: portInfo.ID,
+					Kind:    kind,
+					Route:   podServiceName(cr, member.Pod) + "-" + portInfo.ID,
+					URL:     scheme + "://" + host + portInfo.Path,
+				},
+				Host:    host,
+				Backend: backend,
+				Port:    portInfo.Port,
+			},
+		)
+	}
+	return result, nil
+}
+
+// ApplyDashboardRoute creates in k8s the Ingress or HTTPRoute for a member's
+// dashboard endpoint, or reconciles an existing one if the settings used to
+// generate it have changed.
+func ApplyDashboardRoute(
+	reqLogger logr.Logger,
+	cr *kdv1.KubeDirectorCluster,
+	role *kdv1.Role,
+	route DashboardRoute,
+) error {
+
+	settings := shared.GetDashboardIngress()
+	if settings == nil {
+		return nil
+	}
+	annotations := annotationsForService(cr, role)
+	for name, value := range settings.Annotations {
+		annotations[name] = value
+	}
+	annotations[dashboardRouteHashAnnotation] = dashboardRouteHash(settings, route)
+	if route.Dashboard.Kind == shared.HTTPRouteKind {
+		return applyHTTPRoute(reqLogger, cr, role, settings, route, annotations)
+	}
+	return applyIngress(reqLogger, cr, role, settings, route, annotations)
+}
+
+// DeleteDashboardRoute deletes from k8s the Ingress or HTTPRoute recorded
+// for a member's dashboard endpoint.
+func DeleteDashboardRoute(
+	namespace string,
+	dashboard kdv1.MemberDashboard,
+) error {
+
+	if dashboard.Kind == shared.HTTPRouteKind {
+		toDelete := &unstructured.Unstructured{}
+		toDelete.SetGroupVersionKind(httpRouteGVK())
+		toDelete.SetName(dashboard.Route)
+		toDelete.SetNamespace(namespace)
+		return shared.Delete(context.TODO(), toDelete)
+	}
+	toDelete := &unstructured.Unstructured{}
+	toDelete.SetGroupVersionKind(ingressGVK())
+	toDelete.SetName(dashboard.Route)
+	toDelete.SetNamespace(namespace)
+	return shared.Delete(context.TODO(), toDelete)
+}
+
+// applyIngress creates or reconciles the Ingress for a dashboard endpoint.
+// Like the HTTPRoute, the Ingress is handled as an unstructured object, so
+// that the networking.k8s.io/v1 version can be used. The kdconfig validator
+// makes sure that the K8s version supports it.
+func applyIngress(
+	reqLogger logr.Logger,
+	cr *kdv1.KubeDirectorCluster,
+	role *kdv1.Role,
+	settings *kdv1.DashboardIngress,
+	route DashboardRoute,
+	annotations map[string]string,
+) error {
+
+	spec := map[string]interface{}{
+		"rules": []interface{}{
+			map[string]interface{}{
+				"host": route.Host,
+				"http": map[string]interface{}{
+					"paths": []interface{}{
+						map[string]interface{}{
+							"path":     "/",
+							"pathType": "Prefix",
+							"backend": map[string]interface{}{
+								"service": map[string]interface{}{
+									"name": route.Backend,
+									"port": map[string]interface{}{
+										"number": int64(route.Port),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	if settings.IngressClassName != nil {
+		spec["ingressClassName"] = *settings.IngressClassName
+	}
+	if settings.TLSSecretName != nil {
+		spec["tls"] = []interface{}{
+			map[string]interface{}{
+				"hosts":      []interface{}{route.Host},
+				"secretName": *settings.TLSSecretName,
+			},
+		}
+	}
+
+	ingress, getErr := observer.GetIngress(cr.Namespace, route.Dashboard.Route)
+	if getErr != nil {
+		if !errors.IsNotFound(getErr) {
+			return getErr
+		}
+		desired := &unstructured.Unstructured{}
+		desired.SetGroupVersionKind(ingressGVK())
+		desired.SetName(route.Dashboard.Route)
+		desired.SetNamespace(cr.Namespace)
+		desired.SetOwnerReferences(shared.OwnerReferences(cr))
+		desired.SetLabels(labelsForService(cr, role))
+		desired.SetAnnotations(annotations)
+		desired.Object["spec"] = spec
+		return shared.Create(context.TODO(), desired)
+	}
+	if (ingress.GetAnnotations()[dashboardRouteHashAnnotation] == annotations[dashboardRouteHashAnnotation]) &&
+		shared.OwnerReferencesPresent(cr, ingress.GetOwnerReferences()) {
+		return nil
+	}
+	shared.LogInfof(
+		reqLogger,
+		cr,
+		shared.EventReasonMember,
+		"updating Ingress{%s}",
+		ingress.GetName(),
+	)
+	patchedRes := ingress.DeepCopy()
+	patchedRes.SetOwnerReferences(shared.OwnerReferences(cr))
+	patchedRes.SetLabels(labelsForService(cr, role))
+	patchedRes.SetAnnotations(annotations)
+	patchedRes.Object["spec"] = spec
+	return shared.Patch(
+		context.TODO(),
+		ingress,
+		patchedRes,
+	)
+}
+
+// applyHTTPRoute creates or reconciles the Gateway API HTTPRoute for a
+// dashboard endpoint. The route is handled as an unstructured object so that
+// KubeDirector does not depend on a particular Gateway API client library.
+func applyHTTPRoute(
+	reqLogger logr.Logger,
+	cr *kdv1.KubeDirectorCluster,
+	role *kdv1.Role,
+	settings *kdv1.DashboardIngress,
+	route DashboardRoute,
+	annotations map[string]string,
+) error {
+
+	parentRef := map[string]interface{}{
+		"name": settings.GatewayName,
+	}
+	if settings.GatewayNamespace != "" {
+		parentRef["namespace"] = settings.GatewayNamespace
+	}
+	spec := map[string]interface{}{
+		"parentRefs": []interface{}{parentRef},
+		"hostnames":  []interface{}{route.Host},
+		"rules": []interface{}{
+			map[string]interface{}{
+				"backendRefs": []interface{}{
+					map[string]interface{}{
+						"name": route.Backend,
+						"port": int64(route.Port),
+					},
+				},
+			},
+		},
+	}
+
+	httpRoute, getErr := observer.GetHTTPRoute(cr.Namespace, route.Dashboard.Route)
+	if getErr != nil {
+		if !errors.IsNotFound(getErr) {
+			return getErr
+		}
+		desired := &unstructured.Unstructured{}
+		desired.SetGroupVersionKind(httpRouteGVK())
+		desired.SetName(route.Dashboard.Route)
+		desired.SetNamespace(cr.Namespace)
+		desired.SetOwnerReferences(shared.OwnerReferences(cr))
+		desired.SetLabels(labelsForService(cr, role))
+		desired.SetAnnotations(annotations)
+		desired.Object["spec"] = spec
+		return shared.Create(context.TODO(), desired)
+	}
+	if (httpRoute.GetAnnotations()[dashboardRouteHashAnnotation] == annotations[dashboardRouteHashAnnotation]) &&
+		shared.OwnerReferencesPresent(cr, httpRoute.GetOwnerReferences()) {
+		return nil
+	}
+	shared.LogInfof(
+		reqLogger,
+		cr,
+		shared.EventReasonMember,
+		"updating HTTPRoute{%s}",
+		httpRoute.GetName(),
+	)
+	patchedRes := httpRoute.DeepCopy()
+	patchedRes.SetOwnerReferences(shared.OwnerReferences(cr))
+	patchedRes.SetLabels(labelsForService(cr, role))
+	patchedRes.SetAnnotations(annotations)
+	patchedRes.Object["spec"] = spec
+	return shared.Patch(
+		context.TODO(),
+		httpRoute,
+		patchedRes,
+	)
+}
+
+// dashboardRouteHash returns a hash of the dashboard ingress settings and
+// the route properties used to generate an Ingress or HTTPRoute.
+func dashboardRouteHash(
+	settings *kdv1.DashboardIngress,
+	route DashboardRoute,
+) string {
+
+	routeJSON, _ := json.Marshal(
+		struct {
+			Settings *kdv1.DashboardIngress
+			Route    DashboardRoute
+		}{settings, route},
+	)
+	md5Sum := md5.Sum(routeJSON)
+	return hex.EncodeToString(md5Sum[:])
+}
+
+// ingressGVK returns the group, version, and kind of a K8s Ingress.
+func ingressGVK() schema.GroupVersionKind {
+
+	return schema.GroupVersionKind{
+		Group:   shared.IngressGroup,
+		Version: shared.IngressVersion,
+		Kind:    shared.IngressKind,
+	}
+}
+
+// httpRouteGVK returns the group, version, and kind of a Gateway API
+// HTTPRoute.
+func httpRouteGVK() schema.GroupVersionKind {
+
+	return schema.GroupVersionKind{
+		Group:   shared.GatewayAPIGroup,
+		Version: shared.GatewayAPIVersion,
+		Kind:    shared.HTTPRouteKind,
+	}
+}
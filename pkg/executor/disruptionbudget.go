@@ -0,0 +1,177 @@
+// Copyright 2022 Hewlett Packard Enterprise Development LP
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package executor
+
+import (
+	"context"
+
+	kdv1 "github.com/bluek8s/kubedirector/pkg/apis/kubedirector/v1beta1"
+	"github.com/bluek8s/kubedirector/pkg/shared"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// CreatePodDisruptionBudget creates in k8s a PodDisruptionBudget for the
+// members of the given role, with the same name as the role's statefulset.
+// The budget is handled as an unstructured object, so that the policy/v1
+// API can be used where K8s serves it.
+func CreatePodDisruptionBudget(
+	cr *kdv1.KubeDirectorCluster,
+	role *kdv1.Role,
+	roleStatus *kdv1.RoleStatus,
+	budget *kdv1.DisruptionBudget,
+) (*unstructured.Unstructured, error) {
+
+	gvk, gvkErr := shared.PodDisruptionBudgetGVK()
+	if gvkErr != nil {
+		return nil, gvkErr
+	}
+	pdb := &unstructured.Unstructured{}
+	pdb.SetGroupVersionKind(gvk)
+	pdb.SetName(roleStatus.StatefulSet)
+	pdb.SetNamespace(cr.Namespace)
+	pdb.SetOwnerReferences(shared.OwnerReferences(cr))
+	pdb.SetLabels(labelsForRole(cr, role))
+	pdb.SetAnnotations(annotationsForRole(cr, role))
+	pdb.Object["spec"] = getPodDisruptionBudgetSpec(cr, role, budget)
+	err := shared.Create(context.TODO(), pdb)
+	return pdb, err
+}
+
+// UpdatePodDisruptionBudget examines a current PodDisruptionBudget in k8s
+// and reconciles its owner reference and spec with the role's disruption
+// budget and desired member count.
+func UpdatePodDisruptionBudget(
+	reqLogger logr.Logger,
+	cr *kdv1.KubeDirectorCluster,
+	role *kdv1.Role,
+	budget *kdv1.DisruptionBudget,
+	pdb *unstructured.Unstructured,
+) error {
+
+	ownerRefsOk := shared.OwnerReferencesPresent(cr, pdb.GetOwnerReferences())
+	desiredSpec := getPodDisruptionBudgetSpec(cr, role, budget)
+	currentSpec, _, _ := unstructured.NestedMap(pdb.Object, "spec")
+	specOk := equality.Semantic.DeepEqual(currentSpec, desiredSpec)
+	if ownerRefsOk && specOk {
+		return nil
+	}
+	patchedRes := pdb.DeepCopy()
+	if !ownerRefsOk {
+		shared.LogInfof(
+			reqLogger,
+			cr,
+			shared.EventReasonNoEvent,
+			"repairing owner ref on PodDisruptionBudget{%s}",
+			pdb.GetName(),
+		)
+		patchedRes.SetOwnerReferences(shared.OwnerReferences(cr))
+	}
+	if !specOk {
+		shared.LogInfof(
+			reqLogger,
+			cr,
+			shared.EventReasonRole,
+			"updating PodDisruptionBudget{%s} for role{%s}",
+			pdb.GetName(),
+			role.Name,
+		)
+		// The spec is replaced as a whole, so that a minAvailable or
+		// maxUnavailable that is no longer wanted gets removed.
+		if _, found := currentSpec["minAvailable"]; found {
+			if _, wanted := desiredSpec["minAvailable"]; !wanted {
+				desiredSpec["minAvailable"] = nil
+			}
+		}
+		if _, found := currentSpec["maxUnavailable"]; found {
+			if _, wanted := desiredSpec["maxUnavailable"]; !wanted {
+				desiredSpec["maxUnavailable"] = nil
+			}
+		}
+		patchedRes.Object["spec"] = desiredSpec
+	}
+	patchErr := shared.Patch(
+		context.TODO(),
+		pdb,
+		patchedRes,
+	)
+	if patchErr == nil {
+		*pdb = *patchedRes
+	}
+	return patchErr
+}
+
+// DeletePodDisruptionBudget deletes a PodDisruptionBudget from k8s.
+func DeletePodDisruptionBudget(
+	namespace string,
+	pdbName string,
+) error {
+
+	gvk, gvkErr := shared.PodDisruptionBudgetGVK()
+	if gvkErr != nil {
+		return gvkErr
+	}
+	toDelete := &unstructured.Unstructured{}
+	toDelete.SetGroupVersionKind(gvk)
+	toDelete.SetName(pdbName)
+	toDelete.SetNamespace(namespace)
+	return shared.Delete(context.TODO(), toDelete)
+}
+
+// getPodDisruptionBudgetSpec generates the PodDisruptionBudget spec for a
+// role, in the form used in an unstructured object. The selector only uses
+// the cluster and role labels, since those never change for a member's pod.
+// A quorum budget requires a majority of the role's desired members to be
+// available.
+func getPodDisruptionBudgetSpec(
+	cr *kdv1.KubeDirectorCluster,
+	role *kdv1.Role,
+	budget *kdv1.DisruptionBudget,
+) map[string]interface{} {
+
+	spec := map[string]interface{}{
+		"selector": map[string]interface{}{
+			"matchLabels": map[string]interface{}{
+				shared.ClusterLabel: cr.Name,
+				ClusterRoleLabel:    role.Name,
+			},
+		},
+	}
+	if budget.Quorum {
+		spec["minAvailable"] = int64(*role.Members/2 + 1)
+	} else {
+		if budget.MinAvailable != nil {
+			spec["minAvailable"] = intOrStringValue(budget.MinAvailable)
+		}
+		if budget.MaxUnavailable != nil {
+			spec["maxUnavailable"] = intOrStringValue(budget.MaxUnavailable)
+		}
+	}
+	return spec
+}
+
+// intOrStringValue returns the value of an IntOrString in the form used in
+// an unstructured object.
+func intOrStringValue(
+	value *intstr.IntOrString,
+) interface{} {
+
+	if value.Type == intstr.Int {
+		return int64(value.IntVal)
+	}
+	return value.StrVal
+}
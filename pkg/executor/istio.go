@@ -0,0 +1,329 @@
+// Copyright 2022 Hewlett Packard Enterprise Development LP
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package executor
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	kdv1 "github.com/bluek8s/kubedirector/pkg/apis/kubedirector/v1beta1"
+	"github.com/bluek8s/kubedirector/pkg/observer"
+	"github.com/bluek8s/kubedirector/pkg/shared"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// istioPortProtocols are the port name prefixes that Istio uses to select
+// the protocol of a service port.
+var istioPortProtocols = []string{
+	"http",
+	"http2",
+	"https",
+	"grpc",
+	"grpc-web",
+	"mongo",
+	"mysql",
+	"redis",
+	"tcp",
+	"tls",
+	"udp",
+}
+
+// istioHTTPProtocols are the port name prefixes for which a VirtualService
+// uses http routes rather than tcp routes.
+var istioHTTPProtocols = []string{
+	"http",
+	"http2",
+	"grpc",
+	"grpc-web",
+}
+
+// ApplyIstioRouting creates in k8s the Istio DestinationRule and
+// VirtualService for one of the services of a cluster (the headless service,
+// or a per-member, internal, or role service), or reconciles existing ones
+// if the settings used to generate them have changed. Both objects are named
+// after the service. The DestinationRule sets the TLS mode for traffic sent
+// through the service, and the VirtualService routes each of the service
+// ports to it.
+func ApplyIstioRouting(
+	reqLogger logr.Logger,
+	cr *kdv1.KubeDirectorCluster,
+	service *corev1.Service,
+) error {
+
+	settings := shared.GetIstioSettings()
+	if settings == nil {
+		return nil
+	}
+	ports := service.Spec.Ports
+	host := service.Name + "." + cr.Namespace + shared.GetSvcClusterDomainBase()
+
+	tlsMode := kdv1.IstioTLSModeMutual
+	if settings.TLSMode != nil {
+		tlsMode = *settings.TLSMode
+	}
+	destinationRuleSpec := map[string]interface{}{
+		"host": host,
+		"trafficPolicy": map[string]interface{}{
+			"tls": map[string]interface{}{
+				"mode": tlsMode,
+			},
+		},
+	}
+
+	var httpRoutes []interface{}
+	var tcpRoutes []interface{}
+	for _, port := range ports {
+		route := map[string]interface{}{
+			"match": []interface{}{
+				map[string]interface{}{
+					"port": int64(port.Port),
+				},
+			},
+			"route": []interface{}{
+				map[string]interface{}{
+					"destination": map[string]interface{}{
+						"host": host,
+						"port": map[string]interface{}{
+							"number": int64(port.Port),
+						},
+					},
+				},
+			},
+		}
+		protocol := strings.SplitN(port.Name, "-", 2)[0]
+		if strings.HasPrefix(port.Name, "grpc-web-") {
+			protocol = "grpc-web"
+		}
+		if shared.StringInList(protocol, istioHTTPProtocols) {
+			httpRoutes = append(httpRoutes, route)
+		} else {
+			tcpRoutes = append(tcpRoutes, route)
+		}
+	}
+	virtualServiceSpec := map[string]interface{}{
+		"hosts": []interface{}{host},
+	}
+	if len(httpRoutes) != 0 {
+		virtualServiceSpec["http"] = httpRoutes
+	}
+	if len(tcpRoutes) != 0 {
+		virtualServiceSpec["tcp"] = tcpRoutes
+	}
+
+	drErr := applyIstioObject(
+		reqLogger,
+		cr,
+		service,
+		shared.DestinationRuleKind,
+		destinationRuleSpec,
+	)
+	if drErr != nil {
+		return drErr
+	}
+	return applyIstioObject(
+		reqLogger,
+		cr,
+		service,
+		shared.VirtualServiceKind,
+		virtualServiceSpec,
+	)
+}
+
+// DeleteIstioRouting deletes from k8s the Istio DestinationRule and
+// VirtualService with the given name. Objects that do not exist (or an
+// Istio installation that does not exist) are not treated as errors.
+func DeleteIstioRouting(
+	namespace string,
+	name string,
+) error {
+
+	kinds := []string{
+		shared.VirtualServiceKind,
+		shared.DestinationRuleKind,
+	}
+	for _, kind := range kinds {
+		toDelete := &unstructured.Unstructured{}
+		toDelete.SetGroupVersionKind(istioGVK(kind))
+		toDelete.SetName(name)
+		toDelete.SetNamespace(namespace)
+		err := shared.Delete(context.TODO(), toDelete)
+		if err != nil && !errors.IsNotFound(err) && !meta.IsNoMatchError(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// IstioProxyReady returns false if Istio mode is enabled and the given pod
+// has an Istio sidecar proxy that is not yet ready. Otherwise it returns
+// true.
+func IstioProxyReady(
+	pod *corev1.Pod,
+) bool {
+
+	if shared.GetIstioSettings() == nil {
+		return true
+	}
+	hasProxy := false
+	for _, container := range pod.Spec.Containers {
+		if container.Name == istioProxyContainerName {
+			hasProxy = true
+			break
+		}
+	}
+	for _, container := range pod.Spec.InitContainers {
+		if container.Name == istioProxyContainerName {
+			hasProxy = true
+			break
+		}
+	}
+	if !hasProxy {
+		return true
+	}
+	// The proxy is a regular container in older Istio versions, and a
+	// restartable init container when using native sidecars.
+	var statuses []corev1.ContainerStatus
+	statuses = append(statuses, pod.Status.ContainerStatuses...)
+	statuses = append(statuses, pod.Status.InitContainerStatuses...)
+	for _, status := range statuses {
+		if status.Name == istioProxyContainerName {
+			return status.Ready
+		}
+	}
+	return false
+}
+
+// addIstioPodAnnotations adds the annotations that configure the Istio
+// sidecar proxy of a member pod: the app container is held until the proxy
+// has started, and the configured ports are excluded from the proxy's
+// traffic capture. Annotations that are already present (e.g. from the
+// role's podAnnotations) are left alone.
+func addIstioPodAnnotations(
+	annotations map[string]string,
+	settings *kdv1.IstioSettings,
+) {
+
+	istioAnnotations := map[string]string{
+		istioProxyConfigAnnotation: "holdApplicationUntilProxyStarts: true",
+	}
+	if len(settings.ExcludePorts) != 0 {
+		var ports []string
+		for _, port := range settings.ExcludePorts {
+			ports = append(ports, strconv.Itoa(int(port)))
+		}
+		excludePorts := strings.Join(ports, ",")
+		istioAnnotations[istioExcludeInboundAnnotation] = excludePorts
+		istioAnnotations[istioExcludeOutboundAnnotation] = excludePorts
+	}
+	for name, value := range istioAnnotations {
+		if _, ok := annotations[name]; !ok {
+			annotations[name] = value
+		}
+	}
+}
+
+// applyIstioObject creates or reconciles the Istio object of the given kind
+// for a service of the cluster. The object has the same name and labels as
+// the service. It is handled as an unstructured object so that KubeDirector
+// does not depend on the Istio client library.
+func applyIstioObject(
+	reqLogger logr.Logger,
+	cr *kdv1.KubeDirectorCluster,
+	service *corev1.Service,
+	kind string,
+	spec map[string]interface{},
+) error {
+
+	name := service.Name
+	labels := make(map[string]string)
+	for labelName, labelValue := range service.Labels {
+		labels[labelName] = labelValue
+	}
+	annotations := annotationsForService(cr, nil)
+	annotations[istioRoutingHashAnnotation] = istioRoutingHash(spec)
+
+	existing, getErr := observer.GetIstioObject(cr.Namespace, kind, name)
+	if getErr != nil {
+		if !errors.IsNotFound(getErr) {
+			return getErr
+		}
+		desired := &unstructured.Unstructured{}
+		desired.SetGroupVersionKind(istioGVK(kind))
+		desired.SetName(name)
+		desired.SetNamespace(cr.Namespace)
+		desired.SetOwnerReferences(shared.OwnerReferences(cr))
+		desired.SetLabels(labels)
+		desired.SetAnnotations(annotations)
+		desired.Object["spec"] = spec
+		return shared.Create(context.TODO(), desired)
+	}
+	if (existing.GetAnnotations()[istioRoutingHashAnnotation] == annotations[istioRoutingHashAnnotation]) &&
+		equality.Semantic.DeepEqual(existing.GetLabels(), labels) &&
+		shared.OwnerReferencesPresent(cr, existing.GetOwnerReferences()) {
+		return nil
+	}
+	shared.LogInfof(
+		reqLogger,
+		cr,
+		shared.EventReasonCluster,
+		"updating %s{%s}",
+		kind,
+		name,
+	)
+	patchedRes := existing.DeepCopy()
+	patchedRes.SetOwnerReferences(shared.OwnerReferences(cr))
+	patchedRes.SetLabels(labels)
+	patchedRes.SetAnnotations(annotations)
+	patchedRes.Object["spec"] = spec
+	return shared.Patch(
+		context.TODO(),
+		existing,
+		patchedRes,
+	)
+}
+
+// istioRoutingHash returns a hash of the spec of a generated Istio object.
+func istioRoutingHash(
+	spec map[string]interface{},
+) string {
+
+	// json.Marshal sorts map keys, so the result is stable.
+	specJSON, _ := json.Marshal(spec)
+	md5Sum := md5.Sum(specJSON)
+	return hex.EncodeToString(md5Sum[:])
+}
+
+// istioGVK returns the group, version, and kind of an Istio networking
+// object of the given kind.
+func istioGVK(
+	kind string,
+) schema.GroupVersionKind {
+
+	return schema.GroupVersionKind{
+		Group:   shared.IstioNetworkingGroup,
+		Version: shared.IstioNetworkingVersion,
+		Kind:    kind,
+	}
+}
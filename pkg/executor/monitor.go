@@ -0,0 +1,233 @@
+// Copyright 2022 Hewlett Packard Enterprise Development LP
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package executor
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+
+	kdv1 "github.com/bluek8s/kubedirector/pkg/apis/kubedirector/v1beta1"
+	"github.com/bluek8s/kubedirector/pkg/catalog"
+	"github.com/bluek8s/kubedirector/pkg/observer"
+	"github.com/bluek8s/kubedirector/pkg/shared"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	defaultMetricsPath   = "/metrics"
+	defaultMetricsScheme = "http"
+)
+
+// PodMonitorsSupported returns true if the Prometheus Operator PodMonitor
+// resource type is available in this K8s cluster.
+func PodMonitorsSupported() (bool, error) {
+
+	return shared.ResourceKindIsServed(
+		shared.PrometheusMonitoringGroup,
+		shared.PrometheusMonitoringVersion,
+		shared.PodMonitorKind,
+	)
+}
+
+// MetricsPortsForRole returns the service endpoints of the given role that
+// serve Prometheus metrics.
+func MetricsPortsForRole(
+	cr *kdv1.KubeDirectorCluster,
+	role *kdv1.Role,
+) ([]catalog.ServicePortInfo, error) {
+
+	portInfoList, portsErr := catalog.PortsForRole(cr, role.Name)
+	if portsErr != nil {
+		return nil, portsErr
+	}
+	var result []catalog.ServicePortInfo
+	for _, portInfo := range portInfoList {
+		if portInfo.Metrics != nil {
+			result = append(result, portInfo)
+		}
+	}
+	return result, nil
+}
+
+// ApplyPodMonitor creates in k8s a Prometheus PodMonitor that scrapes the
+// given metrics endpoints on the members of the given role, or reconciles
+// an existing one if the endpoints have changed. The PodMonitor has the
+// same name as the role's statefulset.
+func ApplyPodMonitor(
+	reqLogger logr.Logger,
+	cr *kdv1.KubeDirectorCluster,
+	role *kdv1.Role,
+	roleStatus *kdv1.RoleStatus,
+	metricsPorts []catalog.ServicePortInfo,
+) error {
+
+	var endpoints []interface{}
+	for _, portInfo := range metricsPorts {
+		path, scheme := metricsPathAndScheme(portInfo.Metrics)
+		endpoint := map[string]interface{}{
+			"port":   portInfo.ID,
+			"path":   path,
+			"scheme": scheme,
+		}
+		if portInfo.Metrics.Interval != "" {
+			endpoint["interval"] = portInfo.Metrics.Interval
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	spec := map[string]interface{}{
+		"selector": map[string]interface{}{
+			"matchLabels": map[string]interface{}{
+				shared.ClusterLabel: cr.Name,
+				ClusterRoleLabel:    role.Name,
+			},
+		},
+		"podMetricsEndpoints": endpoints,
+	}
+	annotations := annotationsForRole(cr, role)
+	annotations[podMonitorHashAnnotation] = podMonitorHash(spec)
+
+	monitorName := roleStatus.StatefulSet
+	monitor, getErr := observer.GetPodMonitor(cr.Namespace, monitorName)
+	if getErr != nil {
+		if !errors.IsNotFound(getErr) {
+			return getErr
+		}
+		desired := &unstructured.Unstructured{}
+		desired.SetGroupVersionKind(podMonitorGVK())
+		desired.SetName(monitorName)
+		desired.SetNamespace(cr.Namespace)
+		desired.SetOwnerReferences(shared.OwnerReferences(cr))
+		desired.SetLabels(labelsForRole(cr, role))
+		desired.SetAnnotations(annotations)
+		desired.Object["spec"] = spec
+		return shared.Create(context.TODO(), desired)
+	}
+	if (monitor.GetAnnotations()[podMonitorHashAnnotation] == annotations[podMonitorHashAnnotation]) &&
+		shared.OwnerReferencesPresent(cr, monitor.GetOwnerReferences()) {
+		return nil
+	}
+	shared.LogInfof(
+		reqLogger,
+		cr,
+		shared.EventReasonRole,
+		"updating PodMonitor{%s} for role{%s}",
+		monitorName,
+		role.Name,
+	)
+	patchedRes := monitor.DeepCopy()
+	patchedRes.SetOwnerReferences(shared.OwnerReferences(cr))
+	patchedRes.SetLabels(labelsForRole(cr, role))
+	patchedRes.SetAnnotations(annotations)
+	patchedRes.Object["spec"] = spec
+	return shared.Patch(
+		context.TODO(),
+		monitor,
+		patchedRes,
+	)
+}
+
+// DeletePodMonitor deletes a Prometheus PodMonitor from k8s.
+func DeletePodMonitor(
+	namespace string,
+	monitorName string,
+) error {
+
+	toDelete := &unstructured.Unstructured{}
+	toDelete.SetGroupVersionKind(podMonitorGVK())
+	toDelete.SetName(monitorName)
+	toDelete.SetNamespace(namespace)
+	return shared.Delete(context.TODO(), toDelete)
+}
+
+// metricsAnnotations generates the conventional Prometheus scrape
+// annotations for the pods of the given role, if the role has a metrics
+// endpoint and the PodMonitor resource type is not available. These
+// annotations can only describe one endpoint, so the first metrics endpoint
+// of the role is used. An error is returned if we can't tell whether
+// PodMonitors are available, rather than guessing; the wrong guess would
+// leave the pods either scraped twice or not at all until they are
+// restarted.
+func metricsAnnotations(
+	cr *kdv1.KubeDirectorCluster,
+	role *kdv1.Role,
+) (map[string]string, error) {
+
+	metricsPorts, portsErr := MetricsPortsForRole(cr, role)
+	if portsErr != nil {
+		return nil, portsErr
+	}
+	if len(metricsPorts) == 0 {
+		return nil, nil
+	}
+	supported, supportedErr := PodMonitorsSupported()
+	if supportedErr != nil {
+		return nil, supportedErr
+	}
+	if supported {
+		return nil, nil
+	}
+	portInfo := metricsPorts[0]
+	path, scheme := metricsPathAndScheme(portInfo.Metrics)
+	return map[string]string{
+		prometheusScrapeAnnotation: "true",
+		prometheusPortAnnotation:   strconv.Itoa(int(portInfo.Port)),
+		prometheusPathAnnotation:   path,
+		prometheusSchemeAnnotation: scheme,
+	}, nil
+}
+
+// metricsPathAndScheme returns the path and scheme for scraping a metrics
+// endpoint, applying the defaults for any that are unset.
+func metricsPathAndScheme(
+	metrics *kdv1.ServiceMetrics,
+) (string, string) {
+
+	path := metrics.Path
+	if path == "" {
+		path = defaultMetricsPath
+	}
+	scheme := metrics.Scheme
+	if scheme == "" {
+		scheme = defaultMetricsScheme
+	}
+	return path, scheme
+}
+
+// podMonitorHash returns a hash of the spec of a generated PodMonitor.
+func podMonitorHash(
+	spec map[string]interface{},
+) string {
+
+	specJSON, _ := json.Marshal(spec)
+	md5Sum := md5.Sum(specJSON)
+	return hex.EncodeToString(md5Sum[:])
+}
+
+// podMonitorGVK returns the group, version, and kind of a Prometheus
+// Operator PodMonitor.
+func podMonitorGVK() schema.GroupVersionKind {
+
+	return schema.GroupVersionKind{
+		Group:   shared.PrometheusMonitoringGroup,
+		Version: shared.PrometheusMonitoringVersion,
+		Kind:    shared.PodMonitorKind,
+	}
+}
@@ -38,6 +38,10 @@ func CreateHeadlessService(
 	cr *kdv1.KubeDirectorCluster,
 ) (*corev1.Service, error) {
 
+	ports, portsErr := headlessServicePorts(cr)
+	if portsErr != nil {
+		return nil, portsErr
+	}
 	service := &corev1.Service{
 		TypeMeta: metav1.TypeMeta{
 			Kind:       "Service",
@@ -55,12 +59,7 @@ func CreateHeadlessService(
 				HeadlessServiceLabel: cr.Name,
 			},
 			PublishNotReadyAddresses: true,
-			Ports: []corev1.ServicePort{
-				{
-					Name: "port",
-					Port: 8888, // not used
-				},
-			},
+			Ports:                    ports,
 		},
 	}
 
@@ -92,93 +91,190 @@ func UpdateHeadlessService(
 	// need/expect to be under our control, other than the replicas count,
 	// correct them here.
 
-	// For now only checking the owner reference.
-	if shared.OwnerReferencesPresent(cr, service.OwnerReferences) {
+	// For now only checking the owner reference and the ports (which change
+	// if Istio mode is turned on or off).
+	ports, portsErr := headlessServicePorts(cr)
+	if portsErr != nil {
+		return portsErr
+	}
+	ownerRefsOk := shared.OwnerReferencesPresent(cr, service.OwnerReferences)
+	portsOk := servicePortsMatch(service, ports)
+	if ownerRefsOk && portsOk {
 		return nil
 	}
-	shared.LogInfof(
-		reqLogger,
-		cr,
-		shared.EventReasonNoEvent,
-		"repairing owner ref on service{%s}",
-		service.Name,
-	)
-	// We're just going to nuke any existing owner refs. (A bit more
-	// discussion of this in UpdateStatefulSetNonReplicas comments.)
-	patchedRes := *service
-	patchedRes.OwnerReferences = shared.OwnerReferences(cr)
+	patchedRes := service.DeepCopy()
+	if !ownerRefsOk {
+		shared.LogInfof(
+			reqLogger,
+			cr,
+			shared.EventReasonNoEvent,
+			"repairing owner ref on service{%s}",
+			service.Name,
+		)
+		// We're just going to nuke any existing owner refs. (A bit more
+		// discussion of this in UpdateStatefulSetNonReplicas comments.)
+		patchedRes.OwnerReferences = shared.OwnerReferences(cr)
+	}
+	if !portsOk {
+		shared.LogInfof(
+			reqLogger,
+			cr,
+			shared.EventReasonCluster,
+			"updating ports of service{%s}",
+			service.Name,
+		)
+		patchedRes.Spec.Ports = ports
+	}
 	return shared.Patch(
 		context.TODO(),
 		service,
-		&patchedRes,
+		patchedRes,
 	)
 }
 
+// headlessServicePorts determines the ports of the cluster "headless"
+// service. Normally it has a single port that is not used. In Istio mode it
+// instead lists the service endpoint ports of all roles, so that Istio will
+// recognize (and secure) traffic sent to members through their FQDNs.
+func headlessServicePorts(
+	cr *kdv1.KubeDirectorCluster,
+) ([]corev1.ServicePort, error) {
+
+	unusedPorts := []corev1.ServicePort{
+		{
+			Name: "port",
+			Port: 8888, // not used
+		},
+	}
+	if shared.GetIstioSettings() == nil {
+		return unusedPorts, nil
+	}
+	var ports []corev1.ServicePort
+	seen := make(map[int32]bool)
+	for _, role := range cr.Spec.Roles {
+		portInfoList, portsErr := catalog.PortsForRole(cr, role.Name)
+		if portsErr != nil {
+			return nil, portsErr
+		}
+		for _, portInfo := range portInfoList {
+			if seen[portInfo.Port] {
+				continue
+			}
+			seen[portInfo.Port] = true
+			ports = append(
+				ports,
+				corev1.ServicePort{
+					Port: portInfo.Port,
+					Name: createPortNameForService(portInfo),
+				},
+			)
+		}
+	}
+	if len(ports) == 0 {
+		return unusedPorts, nil
+	}
+	return ports, nil
+}
+
 // CreatePodService creates in k8s a service that exposes the designated
-// service endpoints of a virtual cluster member. Depending on the app type
-// definition, this will be either a NodePort service (default) or a
-// LoadBalancer service. If there are no ports to configure for this service,
-// no service object will be created and the function will return (nil, nil).
+// service endpoints of a virtual cluster member. Depending on the role's
+// service type (or the cluster's, if the role does not override it), this
+// will be either a NodePort service (default) or a LoadBalancer service, or
+// a ClusterIP service. Endpoints with internal or dashboard-only exposure are
+// left off of it if they are given their own internal service (see
+// CreatePodInternalService). If there are no ports to configure for this
+// service, no service object will be created and the function will return
+// (nil, nil).
 func CreatePodService(
 	cr *kdv1.KubeDirectorCluster,
 	role *kdv1.Role,
 	podName string,
 ) (*corev1.Service, error) {
 
-	serviceType := shared.ServiceType(*cr.Spec.ServiceType)
-
-	var name string
-	namingScheme := *cr.Spec.NamingScheme
-	if namingScheme == v1beta1.CrNameRole {
-		name = podName
-	} else if namingScheme == v1beta1.UID {
-		name = svcNamePrefix + podName
-	}
-
-	portInfoList, portsErr := catalog.PortsForRole(cr, role.Name)
+	serviceType, ports, _, portsErr := podServicePorts(cr, role)
 	if portsErr != nil {
 		return nil, portsErr
 	}
-	if len(portInfoList) == 0 {
+	if len(ports) == 0 {
 		return nil, nil
 	}
-	service := &corev1.Service{
-		TypeMeta: metav1.TypeMeta{
-			Kind:       "Service",
-			APIVersion: "v1",
-		},
-		ObjectMeta: metav1.ObjectMeta{
-			Name:            name,
-			Namespace:       cr.Namespace,
-			OwnerReferences: shared.OwnerReferences(cr),
-			Labels:          labelsForService(cr, role),
-			Annotations:     annotationsForService(cr, role),
-		},
-		Spec: corev1.ServiceSpec{
-			Selector:                 map[string]string{statefulSetPodLabel: podName},
-			Type:                     serviceType,
-			PublishNotReadyAddresses: true,
-		},
+	service := podService(
+		cr,
+		role,
+		podServiceName(cr, podName),
+		podName,
+		serviceType,
+		ports,
+	)
+	createErr := shared.Create(context.TODO(), service)
+	return service, createErr
+}
+
+// CreatePodInternalService creates in k8s a ClusterIP service for the
+// endpoints of a virtual cluster member that have internal or dashboard-only
+// exposure. This is only needed if the member's main service is not itself a
+// ClusterIP service and also has externally exposed endpoints; otherwise no
+// service object will be created and the function will return (nil, nil).
+func CreatePodInternalService(
+	cr *kdv1.KubeDirectorCluster,
+	role *kdv1.Role,
+	podName string,
+) (*corev1.Service, error) {
+
+	_, _, internalPorts, portsErr := podServicePorts(cr, role)
+	if portsErr != nil {
+		return nil, portsErr
 	}
-	for _, portInfo := range portInfoList {
-		servicePort := corev1.ServicePort{
-			Port: portInfo.Port,
-			Name: createPortNameForService(portInfo),
-		}
-		service.Spec.Ports = append(service.Spec.Ports, servicePort)
+	if len(internalPorts) == 0 {
+		return nil, nil
 	}
+	service := podService(
+		cr,
+		role,
+		podInternalServiceName(cr, podName),
+		podName,
+		corev1.ServiceTypeClusterIP,
+		internalPorts,
+	)
 	createErr := shared.Create(context.TODO(), service)
 	return service, createErr
 }
 
+// PodInternalServiceNeeded returns true if the members of the given role
+// should have an internal service; see CreatePodInternalService.
+func PodInternalServiceNeeded(
+	cr *kdv1.KubeDirectorCluster,
+	role *kdv1.Role,
+) (bool, error) {
+
+	_, _, internalPorts, portsErr := podServicePorts(cr, role)
+	if portsErr != nil {
+		return false, portsErr
+	}
+	return len(internalPorts) != 0, nil
+}
+
+// RoleServiceType returns the type of the per-member services of the given
+// role: the role's serviceType if set, otherwise the cluster's.
+func RoleServiceType(
+	cr *kdv1.KubeDirectorCluster,
+	role *kdv1.Role,
+) corev1.ServiceType {
+
+	if (role != nil) && (role.ServiceType != nil) {
+		return shared.ServiceType(*role.ServiceType)
+	}
+	return shared.ServiceType(*cr.Spec.ServiceType)
+}
+
 // UpdatePodService examines a current per-member service in k8s and may take
 // steps to reconcile it to the desired spec.
-// TBD: Currently this function handles changes only for serviceType and
-// ownerReferences, and is only called if the service is known to already
-// exist. If port-changing is supported in the future, either this function or
-// its caller must take care of possibly transitioning to and from the "no
-// ports" state which will involve deleting or creating the service object
-// rather than just modifying.
+// This function handles changes for serviceType, ports, and ownerReferences,
+// and is only called if the service is known to already exist. If only the
+// port names need to change (because Istio mode was turned on or off), they
+// are changed in place. If the ports themselves need to change (e.g. because
+// some endpoints are moving to or from the member's internal service), the
+// service is deleted so that it will be re-created on a later handler pass.
 func UpdatePodService(
 	reqLogger logr.Logger,
 	cr *kdv1.KubeDirectorCluster,
@@ -188,40 +284,40 @@ func UpdatePodService(
 ) error {
 
 	// First check the owner reference.
-	if !shared.OwnerReferencesPresent(cr, service.OwnerReferences) {
+	ownerErr := repairServiceOwnerRefs(reqLogger, cr, service)
+	if ownerErr != nil {
+		return ownerErr
+	}
+
+	// Then the ports.
+	reqServiceType, ports, _, portsErr := podServicePorts(cr, role)
+	if portsErr != nil {
+		return portsErr
+	}
+	if servicePortNumbersMatch(service, ports) {
+		if !servicePortsMatch(service, ports) {
+			renameErr := renameServicePorts(reqLogger, cr, service, ports)
+			if renameErr != nil {
+				return renameErr
+			}
+		}
+	} else {
 		shared.LogInfof(
 			reqLogger,
 			cr,
-			shared.EventReasonNoEvent,
-			"repairing owner ref on service{%s}",
+			shared.EventReasonMember,
+			"deleting service{%s} to change its ports",
 			service.Name,
 		)
-		// We're just going to nuke any existing owner refs. (A bit more
-		// discussion of this in UpdateStatefulSetNonReplicas comments.)
-		patchedRes := *service
-		patchedRes.OwnerReferences = shared.OwnerReferences(cr)
-		patchErr := shared.Patch(
-			context.TODO(),
-			service,
-			&patchedRes,
+		return DeletePodService(
+			reqLogger,
+			cr.Namespace,
+			service.Name,
 		)
-		if patchErr != nil {
-			shared.LogErrorf(
-				reqLogger,
-				patchErr,
-				cr,
-				shared.EventReasonNoEvent,
-				"failed to update service{%s}",
-				service.Name,
-			)
-			return patchErr
-		}
 	}
 
 	// Now deal with service type.
-	reqServiceType := shared.ServiceType(*cr.Spec.ServiceType)
-
-	// Compare cluster CR's service type against created service
+	// Compare the role's service type against created service
 	if reqServiceType == service.Spec.Type {
 		return nil
 	}
@@ -273,7 +369,160 @@ func UpdatePodService(
 	return nil
 }
 
-// DeletePodService deletes a per-member service from k8s.
+// UpdatePodInternalService examines a current per-member internal service in
+// k8s and may take steps to reconcile it to the desired spec. As with
+// UpdatePodService, port names are changed in place, but a service whose
+// ports need to change is deleted so that it will be re-created.
+func UpdatePodInternalService(
+	reqLogger logr.Logger,
+	cr *kdv1.KubeDirectorCluster,
+	role *kdv1.Role,
+	service *corev1.Service,
+) error {
+
+	ownerErr := repairServiceOwnerRefs(reqLogger, cr, service)
+	if ownerErr != nil {
+		return ownerErr
+	}
+	_, _, internalPorts, portsErr := podServicePorts(cr, role)
+	if portsErr != nil {
+		return portsErr
+	}
+	if servicePortsMatch(service, internalPorts) {
+		return nil
+	}
+	if servicePortNumbersMatch(service, internalPorts) {
+		return renameServicePorts(reqLogger, cr, service, internalPorts)
+	}
+	shared.LogInfof(
+		reqLogger,
+		cr,
+		shared.EventReasonMember,
+		"deleting service{%s} to change its ports",
+		service.Name,
+	)
+	return DeletePodService(
+		reqLogger,
+		cr.Namespace,
+		service.Name,
+	)
+}
+
+// CreateRoleService creates in k8s a service for the endpoints of a role
+// that ask for a role-wide service. Unlike the per-member services, it
+// selects all of the role's members and only sends traffic to the ready
+// ones. Its type is the role's service type, unless none of its endpoints
+// are externally exposed, in which case it is a ClusterIP service. If there
+// are no such endpoints, no service object will be created and the function
+// will return (nil, nil).
+func CreateRoleService(
+	cr *kdv1.KubeDirectorCluster,
+	role *kdv1.Role,
+	roleStatus *kdv1.RoleStatus,
+) (*corev1.Service, error) {
+
+	serviceType, ports, portsErr := roleServicePorts(cr, role)
+	if portsErr != nil {
+		return nil, portsErr
+	}
+	if len(ports) == 0 {
+		return nil, nil
+	}
+	service := &corev1.Service{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Service",
+			APIVersion: "v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            podServiceName(cr, roleStatus.StatefulSet),
+			Namespace:       cr.Namespace,
+			OwnerReferences: shared.OwnerReferences(cr),
+			Labels:          labelsForService(cr, role),
+			Annotations:     annotationsForService(cr, role),
+		},
+		Spec: corev1.ServiceSpec{
+			Selector: map[string]string{
+				shared.ClusterLabel: cr.Name,
+				ClusterRoleLabel:    role.Name,
+			},
+			Type:  serviceType,
+			Ports: ports,
+		},
+	}
+	createErr := shared.Create(context.TODO(), service)
+	return service, createErr
+}
+
+// RoleServiceNeeded returns true if the given role should have a role-wide
+// service; see CreateRoleService.
+func RoleServiceNeeded(
+	cr *kdv1.KubeDirectorCluster,
+	role *kdv1.Role,
+) (bool, error) {
+
+	_, ports, portsErr := roleServicePorts(cr, role)
+	if portsErr != nil {
+		return false, portsErr
+	}
+	return len(ports) != 0, nil
+}
+
+// UpdateRoleService examines a current role-wide service in k8s and
+// reconciles its owner reference, type, and ports. Unlike the per-member
+// services, this one is modified in place rather than re-created, so that
+// its cluster IP stays the same. Node ports are kept for any port numbers
+// that are not changing, even if the port is renamed.
+func UpdateRoleService(
+	reqLogger logr.Logger,
+	cr *kdv1.KubeDirectorCluster,
+	role *kdv1.Role,
+	service *corev1.Service,
+) error {
+
+	ownerErr := repairServiceOwnerRefs(reqLogger, cr, service)
+	if ownerErr != nil {
+		return ownerErr
+	}
+	serviceType, ports, portsErr := roleServicePorts(cr, role)
+	if portsErr != nil {
+		return portsErr
+	}
+	if (service.Spec.Type == serviceType) && servicePortsMatch(service, ports) {
+		return nil
+	}
+	shared.LogInfof(
+		reqLogger,
+		cr,
+		shared.EventReasonRole,
+		"updating service{%s} for role{%s}",
+		service.Name,
+		role.Name,
+	)
+	if serviceType != corev1.ServiceTypeClusterIP {
+		for i := range ports {
+			for _, oldPort := range service.Spec.Ports {
+				if oldPort.Port == ports[i].Port {
+					ports[i].NodePort = oldPort.NodePort
+					break
+				}
+			}
+		}
+	}
+	patchedRes := service.DeepCopy()
+	patchedRes.Spec.Type = serviceType
+	patchedRes.Spec.Ports = ports
+	patchErr := shared.Patch(
+		context.TODO(),
+		service,
+		patchedRes,
+	)
+	if patchErr == nil {
+		*service = *patchedRes
+	}
+	return patchErr
+}
+
+// DeletePodService deletes a per-member or role-wide service from k8s.
 func DeletePodService(
 	reqLogger logr.Logger,
 	namespace string,
@@ -357,3 +606,244 @@ func UpdateService(
 	}
 	return err
 }
+
+// repairServiceOwnerRefs makes sure that a per-member service is owned by
+// the virtual cluster.
+func repairServiceOwnerRefs(
+	reqLogger logr.Logger,
+	cr *kdv1.KubeDirectorCluster,
+	service *corev1.Service,
+) error {
+
+	if shared.OwnerReferencesPresent(cr, service.OwnerReferences) {
+		return nil
+	}
+	shared.LogInfof(
+		reqLogger,
+		cr,
+		shared.EventReasonNoEvent,
+		"repairing owner ref on service{%s}",
+		service.Name,
+	)
+	// We're just going to nuke any existing owner refs. (A bit more
+	// discussion of this in UpdateStatefulSetNonReplicas comments.)
+	patchedRes := *service
+	patchedRes.OwnerReferences = shared.OwnerReferences(cr)
+	patchErr := shared.Patch(
+		context.TODO(),
+		service,
+		&patchedRes,
+	)
+	if patchErr != nil {
+		shared.LogErrorf(
+			reqLogger,
+			patchErr,
+			cr,
+			shared.EventReasonNoEvent,
+			"failed to update service{%s}",
+			service.Name,
+		)
+	}
+	return patchErr
+}
+
+// podServicePorts determines the service ports for the members of the given
+// role, and returns the type of each member's main service along with the
+// ports for the main service and for its internal service. Endpoints with
+// internal or dashboard-only exposure get the internal service only if the
+// main service is not a ClusterIP service and also has externally exposed
+// endpoints; otherwise all ports go on the main service, which is a ClusterIP
+// service if none of its endpoints are externally exposed.
+func podServicePorts(
+	cr *kdv1.KubeDirectorCluster,
+	role *kdv1.Role,
+) (corev1.ServiceType, []corev1.ServicePort, []corev1.ServicePort, error) {
+
+	serviceType := RoleServiceType(cr, role)
+	portInfoList, portsErr := catalog.PortsForRole(cr, role.Name)
+	if portsErr != nil {
+		return serviceType, nil, nil, portsErr
+	}
+	var externalPorts []corev1.ServicePort
+	var internalPorts []corev1.ServicePort
+	var allPorts []corev1.ServicePort
+	for _, portInfo := range portInfoList {
+		servicePort := corev1.ServicePort{
+			Port: portInfo.Port,
+			Name: createPortNameForService(portInfo),
+		}
+		if portInfo.Exposure == kdv1.ServiceExposureExternal {
+			externalPorts = append(externalPorts, servicePort)
+		} else {
+			internalPorts = append(internalPorts, servicePort)
+		}
+		allPorts = append(allPorts, servicePort)
+	}
+	if len(externalPorts) == 0 {
+		return corev1.ServiceTypeClusterIP, allPorts, nil, nil
+	}
+	if (serviceType == corev1.ServiceTypeClusterIP) || (len(internalPorts) == 0) {
+		return serviceType, allPorts, nil, nil
+	}
+	return serviceType, externalPorts, internalPorts, nil
+}
+
+// roleServicePorts determines the type and ports of the role-wide service
+// for the given role, from the endpoints that ask for a role service.
+func roleServicePorts(
+	cr *kdv1.KubeDirectorCluster,
+	role *kdv1.Role,
+) (corev1.ServiceType, []corev1.ServicePort, error) {
+
+	portInfoList, portsErr := catalog.PortsForRole(cr, role.Name)
+	if portsErr != nil {
+		return corev1.ServiceTypeClusterIP, nil, portsErr
+	}
+	anyExternal := false
+	var ports []corev1.ServicePort
+	for _, portInfo := range portInfoList {
+		if !portInfo.RoleService {
+			continue
+		}
+		if portInfo.Exposure == kdv1.ServiceExposureExternal {
+			anyExternal = true
+		}
+		ports = append(
+			ports,
+			corev1.ServicePort{
+				Port: portInfo.Port,
+				Name: createPortNameForService(portInfo),
+			},
+		)
+	}
+	if !anyExternal {
+		return corev1.ServiceTypeClusterIP, ports, nil
+	}
+	return RoleServiceType(cr, role), ports, nil
+}
+
+// podService composes the spec for a per-member service.
+func podService(
+	cr *kdv1.KubeDirectorCluster,
+	role *kdv1.Role,
+	name string,
+	podName string,
+	serviceType corev1.ServiceType,
+	ports []corev1.ServicePort,
+) *corev1.Service {
+
+	return &corev1.Service{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Service",
+			APIVersion: "v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       cr.Namespace,
+			OwnerReferences: shared.OwnerReferences(cr),
+			Labels:          labelsForService(cr, role),
+			Annotations:     annotationsForService(cr, role),
+		},
+		Spec: corev1.ServiceSpec{
+			Selector:                 map[string]string{statefulSetPodLabel: podName},
+			Type:                     serviceType,
+			Ports:                    ports,
+			PublishNotReadyAddresses: true,
+		},
+	}
+}
+
+// podServiceName returns the name of the main per-member service.
+func podServiceName(
+	cr *kdv1.KubeDirectorCluster,
+	podName string,
+) string {
+
+	if *cr.Spec.NamingScheme == v1beta1.UID {
+		return svcNamePrefix + podName
+	}
+	return podName
+}
+
+// podInternalServiceName returns the name of the internal per-member service.
+func podInternalServiceName(
+	cr *kdv1.KubeDirectorCluster,
+	podName string,
+) string {
+
+	if *cr.Spec.NamingScheme == v1beta1.UID {
+		return internalSvcNamePrefix + podName
+	}
+	return podName + internalSvcNameSuffix
+}
+
+// servicePortsMatch returns true if the service has exactly the given ports
+// (comparing only their names and port numbers).
+func servicePortsMatch(
+	service *corev1.Service,
+	ports []corev1.ServicePort,
+) bool {
+
+	if len(service.Spec.Ports) != len(ports) {
+		return false
+	}
+	for i := range ports {
+		if (service.Spec.Ports[i].Name != ports[i].Name) ||
+			(service.Spec.Ports[i].Port != ports[i].Port) {
+			return false
+		}
+	}
+	return true
+}
+
+// servicePortNumbersMatch returns true if the service has the given ports,
+// in the same order, ignoring their names.
+func servicePortNumbersMatch(
+	service *corev1.Service,
+	ports []corev1.ServicePort,
+) bool {
+
+	if len(service.Spec.Ports) != len(ports) {
+		return false
+	}
+	for i := range ports {
+		if service.Spec.Ports[i].Port != ports[i].Port {
+			return false
+		}
+	}
+	return true
+}
+
+// renameServicePorts patches a per-member service to give its ports the
+// names of the given ports, which must have the same port numbers (see
+// servicePortNumbersMatch). This happens when Istio mode is turned on or
+// off. The service keeps its other properties, such as its node ports or
+// load balancer address.
+func renameServicePorts(
+	reqLogger logr.Logger,
+	cr *kdv1.KubeDirectorCluster,
+	service *corev1.Service,
+	ports []corev1.ServicePort,
+) error {
+
+	shared.LogInfof(
+		reqLogger,
+		cr,
+		shared.EventReasonMember,
+		"renaming ports of service{%s}",
+		service.Name,
+	)
+	patchedRes := service.DeepCopy()
+	for i := range ports {
+		patchedRes.Spec.Ports[i].Name = ports[i].Name
+	}
+	patchErr := shared.Patch(
+		context.TODO(),
+		service,
+		patchedRes,
+	)
+	if patchErr == nil {
+		*service = *patchedRes
+	}
+	return patchErr
+}
@@ -0,0 +1,79 @@
+// Copyright 2022 Hewlett Packard Enterprise Development LP
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package executor
+
+import (
+	"context"
+
+	kdv1 "github.com/bluek8s/kubedirector/pkg/apis/kubedirector/v1beta1"
+	"github.com/bluek8s/kubedirector/pkg/shared"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// CreateVolumeSnapshot creates in k8s a CSI VolumeSnapshot of the given PVC,
+// owned by the given backup. The snapshot is handled as an unstructured
+// object so that KubeDirector does not depend on a particular snapshot
+// client library.
+func CreateVolumeSnapshot(
+	backup *kdv1.KubeDirectorBackup,
+	snapshotName string,
+	pvcName string,
+) error {
+
+	snapshot := &unstructured.Unstructured{}
+	snapshot.SetGroupVersionKind(
+		schema.GroupVersionKind{
+			Group:   shared.VolumeSnapshotGroup,
+			Version: shared.VolumeSnapshotVersion,
+			Kind:    shared.VolumeSnapshotKind,
+		},
+	)
+	snapshot.SetName(snapshotName)
+	snapshot.SetNamespace(backup.Namespace)
+	snapshot.SetLabels(
+		map[string]string{
+			shared.ClusterLabel: backup.Spec.Cluster,
+			shared.BackupLabel:  backup.Name,
+		},
+	)
+	snapshot.SetOwnerReferences(shared.OwnerReferences(backup))
+	spec := map[string]interface{}{
+		"source": map[string]interface{}{
+			"persistentVolumeClaimName": pvcName,
+		},
+	}
+	if backup.Spec.VolumeSnapshotClassName != nil {
+		spec["volumeSnapshotClassName"] = *backup.Spec.VolumeSnapshotClassName
+	}
+	snapshot.Object["spec"] = spec
+	return shared.Create(context.TODO(), snapshot)
+}
+
+// UpdateStatusBackupDataBackup records the given completed backup in the
+// status backup of its kdcluster.
+func UpdateStatusBackupDataBackup(
+	statusBackup *kdv1.KubeDirectorStatusBackup,
+	backup *kdv1.KubeDirectorBackup,
+) error {
+
+	patchedRes := statusBackup.DeepCopy()
+	patchedRes.Spec.DataBackup = &kdv1.DataBackup{
+		Backup:         backup.Name,
+		CompletionTime: backup.Status.CompletionTime,
+		Snapshots:      backup.Status.Snapshots,
+	}
+	return shared.Patch(context.TODO(), statusBackup, patchedRes)
+}
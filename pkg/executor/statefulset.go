@@ -16,6 +16,9 @@ package executor
 
 import (
 	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"path/filepath"
 	"strconv"
@@ -33,6 +36,10 @@ import (
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	k8sClient "sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 // defaultMountFolders identifies the set of member filesystems directories
@@ -62,14 +69,17 @@ var appConfigLegacyDefaultMountFolders = []string{
 	"/usr",
 }
 
-// CreateStatefulSet creates in k8s a zero-replicas statefulset for
-// implementing the given role.
+// CreateStatefulSet creates in k8s a statefulset with the given number of
+// replicas for implementing the given role. New roles start with zero
+// replicas; a non-zero count is used when replacing the statefulset of a role
+// whose member pods are still running.
 func CreateStatefulSet(
 	reqLogger logr.Logger,
 	cr *kdv1.KubeDirectorCluster,
 	nativeSystemdSupport bool,
 	role *kdv1.Role,
 	roleStatus *kdv1.RoleStatus,
+	replicas int32,
 ) (*appsv1.StatefulSet, error) {
 
 	statefulSet, err := getStatefulset(
@@ -78,12 +88,32 @@ func CreateStatefulSet(
 		nativeSystemdSupport,
 		role,
 		roleStatus,
-		0,
+		replicas,
 	)
 	if err != nil {
 		return nil, err
 	}
-	return statefulSet, shared.Create(context.TODO(), statefulSet)
+	seccompProfile, seccompErr := roleSeccompProfile(cr, role)
+	if seccompErr != nil {
+		return nil, seccompErr
+	}
+	if seccompProfile == nil {
+		return statefulSet, shared.Create(context.TODO(), statefulSet)
+	}
+	// The pod seccompProfile can only be set through an unstructured
+	// object; see seccompProfileField.
+	unstructuredSet, convertErr := statefulSetWithSeccompProfile(statefulSet, seccompProfile)
+	if convertErr != nil {
+		return nil, convertErr
+	}
+	createErr := shared.Create(context.TODO(), unstructuredSet)
+	if createErr != nil {
+		return statefulSet, createErr
+	}
+	return statefulSet, runtime.DefaultUnstructuredConverter.FromUnstructured(
+		unstructuredSet.Object,
+		statefulSet,
+	)
 }
 
 // UpdateStatefulSetReplicas modifies an existing statefulset in k8s to have
@@ -150,11 +180,16 @@ func UpdateStatefulSetReplicas(
 
 // UpdateStatefulSetNonReplicas examines a current statefulset in k8s and may take
 // steps to reconcile it to the desired spec, for properties other than the
-// replicas count.
+// replicas count. If the pod template is changed, existing pods are not
+// restarted here; the statefulset uses the OnDelete update strategy so that
+// KubeDirector can control the restarts. On success the input statefulset
+// object is updated to reflect any changes.
 func UpdateStatefulSetNonReplicas(
 	reqLogger logr.Logger,
 	cr *kdv1.KubeDirectorCluster,
+	nativeSystemdSupport bool,
 	role *kdv1.Role,
+	roleStatus *kdv1.RoleStatus,
 	statefulSet *appsv1.StatefulSet,
 ) error {
 
@@ -168,28 +203,140 @@ func UpdateStatefulSetNonReplicas(
 	// need/expect to be under our control, other than the replicas count,
 	// correct them here.
 
-	// For now only checking the owner reference.
-	if shared.OwnerReferencesPresent(cr, statefulSet.OwnerReferences) {
+	// For now checking the owner reference, and whether the pod template
+	// needs to change because the role spec has changed or because the
+	// statefulset needs to move to a new app (for an app upgrade or
+	// rollback).
+	ownerRefsOk := shared.OwnerReferencesPresent(cr, statefulSet.OwnerReferences)
+	appOk := (statefulSet.Labels[ClusterAppLabel] == cr.Spec.AppID)
+	specHash := roleSpecHash(role)
+	prevSpecHash, hasSpecHash := statefulSet.Annotations[roleSpecHashAnnotation]
+	specOk := (prevSpecHash == specHash)
+	if ownerRefsOk && appOk && specOk {
 		return nil
 	}
-	shared.LogInfof(
-		reqLogger,
-		cr,
-		shared.EventReasonNoEvent,
-		"repairing owner ref on statefulset{%s}",
-		statefulSet.Name,
-	)
-	// So, what to do. Do we add our owner ref to the existing ones? What if
-	// something else is claiming to be controller? Probably some stale ref
-	// left by a bad backup/restore process? We're just going to nuke any
-	// existing owner refs.
-	patchedRes := *statefulSet
-	patchedRes.OwnerReferences = shared.OwnerReferences(cr)
+	patchedRes := statefulSet.DeepCopy()
+	templateChanged := false
+	if !ownerRefsOk {
+		shared.LogInfof(
+			reqLogger,
+			cr,
+			shared.EventReasonNoEvent,
+			"repairing owner ref on statefulset{%s}",
+			statefulSet.Name,
+		)
+		// So, what to do. Do we add our owner ref to the existing ones? What if
+		// something else is claiming to be controller? Probably some stale ref
+		// left by a bad backup/restore process? We're just going to nuke any
+		// existing owner refs.
+		patchedRes.OwnerReferences = shared.OwnerReferences(cr)
+	}
+	if !appOk || (hasSpecHash && !specOk) {
+		if !appOk {
+			shared.LogInfof(
+				reqLogger,
+				cr,
+				shared.EventReasonRole,
+				"changing app for statefulset{%s} from {%s} to {%s}",
+				statefulSet.Name,
+				statefulSet.Labels[ClusterAppLabel],
+				cr.Spec.AppID,
+			)
+		} else {
+			shared.LogInfof(
+				reqLogger,
+				cr,
+				shared.EventReasonRole,
+				"changing pod template for statefulset{%s} to match role{%s} spec",
+				statefulSet.Name,
+				role.Name,
+			)
+		}
+		desired, desiredErr := getStatefulset(
+			reqLogger,
+			cr,
+			nativeSystemdSupport,
+			role,
+			roleStatus,
+			*statefulSet.Spec.Replicas,
+		)
+		if desiredErr != nil {
+			return desiredErr
+		}
+		// Only the labels, annotations, and pod template are updated. The
+		// selector and volume claim templates are immutable, and the pod
+		// labels must continue to match that selector, so the pods keep
+		// their original values for any labels in the selector.
+		patchedRes.Labels = desired.Labels
+		patchedRes.Annotations = desired.Annotations
+		patchedRes.Spec.Template = desired.Spec.Template
+		templateChanged = true
+		for name, value := range statefulSet.Spec.Selector.MatchLabels {
+			patchedRes.Spec.Template.Labels[name] = value
+		}
+		// Pods are restarted by KubeDirector rather than by the statefulset
+		// controller. Statefulsets created by older KubeDirector versions
+		// may still have the default rolling update strategy, so set it
+		// here.
+		patchedRes.Spec.UpdateStrategy = desired.Spec.UpdateStrategy
+	} else if !specOk {
+		// Statefulsets created by older KubeDirector versions don't record
+		// the role spec hash. Role spec changes were not allowed while such
+		// a statefulset existed, so its pod template is current; just record
+		// the hash.
+		if patchedRes.Annotations == nil {
+			patchedRes.Annotations = make(map[string]string)
+		}
+		patchedRes.Annotations[roleSpecHashAnnotation] = specHash
+	}
+	if templateChanged {
+		// The pod seccompProfile can only be changed through unstructured
+		// objects; see seccompProfileField.
+		seccompProfile, seccompErr := roleSeccompProfile(cr, role)
+		if seccompErr != nil {
+			return seccompErr
+		}
+		origSet, origErr := statefulSetWithSeccompProfile(statefulSet, nil)
+		if origErr != nil {
+			return origErr
+		}
+		patchedSet, patchedErr := statefulSetWithSeccompProfile(patchedRes, seccompProfile)
+		if patchedErr != nil {
+			return patchedErr
+		}
+		if seccompProfile == nil {
+			// Clear any profile that was set for an earlier pod template.
+			securityContext, found, _ := unstructured.NestedMap(
+				patchedSet.Object,
+				"spec", "template", "spec", "securityContext",
+			)
+			if found {
+				securityContext["seccompProfile"] = nil
+				unstructured.SetNestedMap(
+					patchedSet.Object,
+					securityContext,
+					"spec", "template", "spec", "securityContext",
+				)
+			}
+		}
+		patchErr := shared.Patch(
+			context.TODO(),
+			origSet,
+			patchedSet,
+		)
+		if patchErr == nil {
+			*statefulSet = *patchedRes
+		}
+		return patchErr
+	}
 	patchErr := shared.Patch(
 		context.TODO(),
 		statefulSet,
-		&patchedRes,
+		patchedRes,
 	)
+	if patchErr == nil {
+		*statefulSet = *patchedRes
+	}
 	return patchErr
 }
 
@@ -212,6 +359,73 @@ func DeleteStatefulSet(
 	return shared.Delete(context.TODO(), toDelete)
 }
 
+// DeleteStatefulSetOrphan deletes a statefulset from k8s while leaving its
+// pods running. A new statefulset with the same selector will adopt them.
+func DeleteStatefulSetOrphan(
+	namespace string,
+	statefulSetName string,
+) error {
+
+	toDelete := &appsv1.StatefulSet{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "StatefulSet",
+			APIVersion: "apps/v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      statefulSetName,
+			Namespace: namespace,
+		},
+	}
+	return shared.Delete(
+		context.TODO(),
+		toDelete,
+		k8sClient.PropagationPolicy(metav1.DeletePropagationOrphan),
+	)
+}
+
+// StatefulSetStorageStale returns true if any of the filesystem volume claim
+// templates in the given statefulset requests less storage than the role
+// spec currently asks for. Claim templates cannot be changed in an existing
+// statefulset, so such a statefulset must be replaced for new members to get
+// the larger volumes.
+func StatefulSetStorageStale(
+	role *kdv1.Role,
+	statefulSet *appsv1.StatefulSet,
+) bool {
+
+	desiredSizes := StorageClaimSizes(role)
+	for _, template := range statefulSet.Spec.VolumeClaimTemplates {
+		desiredSize, ok := desiredSizes[template.Name]
+		if !ok {
+			continue
+		}
+		currentSize := template.Spec.Resources.Requests[v1.ResourceStorage]
+		if desiredSize.Cmp(currentSize) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// DeletePod deletes a pod from k8s. The owning statefulset will re-create it.
+func DeletePod(
+	namespace string,
+	podName string,
+) error {
+
+	toDelete := &v1.Pod{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Pod",
+			APIVersion: "v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      podName,
+			Namespace: namespace,
+		},
+	}
+	return shared.Delete(context.TODO(), toDelete)
+}
+
 // getStatefulset composes the spec for creating a statefulset in k8s, based
 // on the given virtual cluster CR and for the purposes of implementing the
 // given role.
@@ -227,7 +441,11 @@ func getStatefulset(
 	labels := labelsForStatefulSet(cr, role)
 	podLabels := labelsForPod(cr, role)
 	annotations := annotationsForStatefulSet(cr, role)
-	podAnnotations := annotationsForPod(cr, role)
+	annotations[roleSpecHashAnnotation] = roleSpecHash(role)
+	podAnnotations, podAnnotationsErr := annotationsForPod(cr, role)
+	if podAnnotationsErr != nil {
+		return nil, podAnnotationsErr
+	}
 	startupScript := getStartupScript(cr)
 
 	portInfoList, portsErr := catalog.PortsForRole(cr, role.Name)
@@ -244,6 +462,19 @@ func getStatefulset(
 		endpointPorts = append(endpointPorts, containerPort)
 	}
 
+	readinessEndpoint, livenessEndpoint, probesErr := catalog.ProbesForRole(cr, role.Name)
+	if probesErr != nil {
+		return nil, probesErr
+	}
+	var readinessProbe *v1.Probe
+	if readinessEndpoint != nil {
+		readinessProbe = generateProbe(readinessEndpoint, readinessEndpoint.ReadinessProbe)
+	}
+	var livenessProbe *v1.Probe
+	if livenessEndpoint != nil {
+		livenessProbe = generateProbe(livenessEndpoint, livenessEndpoint.LivenessProbe)
+	}
+
 	// Check to see if app has requested additional directories to be persisted
 	appPersistDirs, persistErr := catalog.AppPersistDirs(cr, role.Name)
 	if persistErr != nil {
@@ -357,6 +588,22 @@ func getStatefulset(
 		addToDirs(*appPersistDirs, &defaultPersistDirs, true, role.Name)
 	}
 
+	// Finally, any dirs that are placed on a named volume of the role are
+	// not kept on the main volume.
+	persistVolumes, persistVolumesErr := rolePersistVolumes(cr, role)
+	if persistVolumesErr != nil {
+		return nil, persistVolumesErr
+	}
+	if len(persistVolumes) != 0 {
+		mainDirs := persistDirs[:0]
+		for _, dir := range persistDirs {
+			if claimForDir(PvcNamePrefix, persistVolumes, dir) == PvcNamePrefix {
+				mainDirs = append(mainDirs, dir)
+			}
+		}
+		persistDirs = mainDirs
+	}
+
 	useServiceAccount := false
 	if role.ServiceAccountName != "" {
 		useServiceAccount = true
@@ -367,6 +614,7 @@ func getStatefulset(
 		PvcNamePrefix,
 		nativeSystemdSupport,
 		persistDirs,
+		persistVolumes,
 	)
 
 	if volumesErr != nil {
@@ -399,10 +647,53 @@ func getStatefulset(
 		return nil, imageErr
 	}
 
-	securityContext, securityErr := generateSecurityContext(cr)
+	roleSecurity, roleSecurityErr := catalog.RoleSecurityContext(cr, role.Name)
+	if roleSecurityErr != nil {
+		return nil, roleSecurityErr
+	}
+	restrictedMode := shared.GetRestrictedMode()
+
+	securityContext, securityErr := generateSecurityContext(cr, roleSecurity, restrictedMode)
 	if securityErr != nil {
 		return nil, securityErr
 	}
+	helperSecurityContext := generateContainerSecurityContext(roleSecurity, restrictedMode)
+	var initSecurityContext *v1.SecurityContext
+	if restrictedMode {
+		initSecurityContext = helperSecurityContext
+	}
+	if seccomp := seccompAnnotation(roleSecurity, restrictedMode); seccomp != "" {
+		podAnnotations[v1.SeccompPodAnnotationKey] = seccomp
+	}
+
+	// The startup script edits files that only root can write, so if the
+	// app container does not run as root the cluster service domain is
+	// added to the DNS search list through the pod spec instead.
+	var lifecycle *v1.Lifecycle
+	var dnsConfig *v1.PodDNSConfig
+	if runsAsRoot(roleSecurity, restrictedMode) {
+		lifecycle = &v1.Lifecycle{PostStart: &startupScript}
+	} else {
+		dnsConfig = &v1.PodDNSConfig{
+			Searches: []string{
+				cr.Status.ClusterService + "." + cr.Namespace + shared.GetSvcClusterDomainBase(),
+			},
+		}
+	}
+
+	sidecars, sidecarsErr := catalog.RoleSidecars(cr, role.Name)
+	if sidecarsErr != nil {
+		return nil, sidecarsErr
+	}
+	// In Istio mode a sidecar proxy gets injected too, so point kubectl at
+	// the app container and configure the proxy.
+	istioSettings := shared.GetIstioSettings()
+	if (len(sidecars) != 0) || (istioSettings != nil) {
+		podAnnotations[defaultContainerAnnotation] = AppContainerName
+	}
+	if istioSettings != nil {
+		addIstioPodAnnotations(podAnnotations, istioSettings)
+	}
 
 	vct := getVolumeClaimTemplate(cr, role, PvcNamePrefix)
 
@@ -419,8 +710,11 @@ func getStatefulset(
 		},
 		Spec: appsv1.StatefulSetSpec{
 			PodManagementPolicy: appsv1.ParallelPodManagement,
-			Replicas:            &replicas,
-			ServiceName:         cr.Status.ClusterService,
+			UpdateStrategy: appsv1.StatefulSetUpdateStrategy{
+				Type: appsv1.OnDeleteStatefulSetStrategyType,
+			},
+			Replicas:    &replicas,
+			ServiceName: cr.Status.ClusterService,
 			Selector: &metav1.LabelSelector{
 				MatchLabels: podLabels,
 			},
@@ -437,24 +731,39 @@ func getStatefulset(
 						PvcNamePrefix,
 						imageID,
 						persistDirs,
+						persistVolumes,
+						initSecurityContext,
 					),
 					Affinity:           role.Affinity,
 					ServiceAccountName: role.ServiceAccountName,
-					Containers: []v1.Container{
-						{
-							Name:            AppContainerName,
-							Image:           imageID,
-							Resources:       role.Resources,
-							Lifecycle:       &v1.Lifecycle{PostStart: &startupScript},
-							Ports:           endpointPorts,
-							VolumeMounts:    volumeMounts,
-							VolumeDevices:   volumeDevices,
-							SecurityContext: securityContext,
-							Env:             chkModifyEnvVars(role, setupInfo),
-							TTY:             hasTTY(cr, role.Name),
-							Stdin:           hasSTDIN(cr, role.Name),
+					SecurityContext:    generatePodSecurityContext(roleSecurity, restrictedMode),
+					DNSConfig:          dnsConfig,
+					Containers: append(
+						[]v1.Container{
+							{
+								Name:            AppContainerName,
+								Image:           imageID,
+								Resources:       role.Resources,
+								Lifecycle:       lifecycle,
+								Ports:           endpointPorts,
+								ReadinessProbe:  readinessProbe,
+								LivenessProbe:   livenessProbe,
+								VolumeMounts:    volumeMounts,
+								VolumeDevices:   volumeDevices,
+								SecurityContext: securityContext,
+								Env:             chkModifyEnvVars(role, setupInfo),
+								TTY:             hasTTY(cr, role.Name),
+								Stdin:           hasSTDIN(cr, role.Name),
+							},
 						},
-					},
+						getSidecarContainers(
+							role,
+							sidecars,
+							PvcNamePrefix,
+							persistVolumes,
+							helperSecurityContext,
+						)...,
+					),
 					Volumes: volumes,
 				},
 			},
@@ -462,6 +771,8 @@ func getStatefulset(
 		},
 	}
 
+	setPodScheduling(cr, role, &sset.Spec.Template.Spec)
+
 	namingScheme := *cr.Spec.NamingScheme
 	if (roleStatus == nil) || (roleStatus.StatefulSet == "") {
 		if namingScheme == v1beta1.CrNameRole {
@@ -477,6 +788,30 @@ func getStatefulset(
 	return sset, nil
 }
 
+// roleSpecHash calculates a hash of the role spec properties that are used
+// when generating the pod template (or are applied to members as they are
+// set up, like file injections). The members count, rollout settings,
+// termination policy, and service type are excluded, as are properties that
+// cannot be changed while the role has members.
+func roleSpecHash(
+	role *kdv1.Role,
+) string {
+
+	hashRole := *role
+	hashRole.Members = nil
+	hashRole.MaxUnavailable = nil
+	hashRole.TerminationPolicy = nil
+	hashRole.Storage = nil
+	hashRole.BlockStorage = nil
+	hashRole.ServiceLabels = nil
+	hashRole.ServiceAnnotations = nil
+	hashRole.ServiceType = nil
+	roleJSON, _ := json.Marshal(&hashRole)
+	// md5 is very cheap for small strings
+	md5Sum := md5.Sum(roleJSON)
+	return hex.EncodeToString(md5Sum[:])
+}
+
 // chkModifyEnvVars checks a role's resource requests. If an NVIDIA GPU resource
 // has NOT been requested for the role, a work-around is added (as an environment
 // variable), to avoid a GPU being surfaced anyway in a container related to
@@ -525,14 +860,16 @@ func chkModifyEnvVars(
 
 // getInitContainer prepares the init container spec to be used with the
 // given role (for initializing the directory content placed on shared
-// persistent storage). The result will be empty if the role does not use
-// shared persistent storage.
+// persistent storage, including any named volumes). The result will be
+// empty if the role does not use shared persistent storage.
 func getInitContainer(
 	cr *kdv1.KubeDirectorCluster,
 	role *kdv1.Role,
 	pvcNamePrefix string,
 	imageID string,
 	persistDirs []string,
+	persistVolumes []PersistedVolume,
+	securityContext *v1.SecurityContext,
 ) (initContainer []v1.Container) {
 
 	// We are depending on the default value of 0 here. Not setting it
@@ -543,32 +880,131 @@ func getInitContainer(
 		return
 	}
 
-	initVolumeMounts := generateInitVolumeMounts(pvcNamePrefix)
+	// Unless a security context is given (in restricted mode), run as root
+	// so that all of the persisted files can be copied.
+	if securityContext == nil {
+		securityContext = &v1.SecurityContext{
+			RunAsUser: &rootUID,
+		}
+	}
+
+	initVolumeMounts := generateInitVolumeMounts(pvcNamePrefix, persistVolumes)
 	initContainer = []v1.Container{
 		{
 			Args: []string{
 				"-c",
-				generateInitContainerLaunch(persistDirs),
+				generateInitContainerLaunch(persistDirs, persistVolumes),
 			},
 			Command: []string{
 				"/bin/bash",
 			},
-			Image:     imageID,
-			Name:      initContainerName,
-			Resources: role.Resources,
-			SecurityContext: &v1.SecurityContext{
-				RunAsUser: &rootUID,
-			},
-			VolumeMounts: initVolumeMounts,
+			Image:           imageID,
+			Name:            InitContainerName,
+			Resources:       role.Resources,
+			SecurityContext: securityContext,
+			VolumeMounts:    initVolumeMounts,
 		},
 	}
 	return
 }
 
+// generateProbe composes a container probe from a probe declared on a
+// service endpoint.
+func generateProbe(
+	endpoint *kdv1.ServiceEndpoint,
+	probe *kdv1.ServiceProbe,
+) *v1.Probe {
+
+	result := &v1.Probe{}
+	switch probe.Type {
+	case kdv1.ServiceProbeHTTP:
+		scheme := v1.URISchemeHTTP
+		if strings.ToLower(endpoint.URLScheme) == "https" {
+			scheme = v1.URISchemeHTTPS
+		}
+		path := endpoint.Path
+		if path == "" {
+			path = "/"
+		}
+		result.HTTPGet = &v1.HTTPGetAction{
+			Path:   path,
+			Port:   intstr.FromInt(int(*endpoint.Port)),
+			Scheme: scheme,
+		}
+	case kdv1.ServiceProbeTCP:
+		result.TCPSocket = &v1.TCPSocketAction{
+			Port: intstr.FromInt(int(*endpoint.Port)),
+		}
+	case kdv1.ServiceProbeExec:
+		result.Exec = &v1.ExecAction{
+			Command: probe.Command,
+		}
+	}
+	if probe.InitialDelaySeconds != nil {
+		result.InitialDelaySeconds = *probe.InitialDelaySeconds
+	}
+	if probe.PeriodSeconds != nil {
+		result.PeriodSeconds = *probe.PeriodSeconds
+	}
+	if probe.TimeoutSeconds != nil {
+		result.TimeoutSeconds = *probe.TimeoutSeconds
+	}
+	if probe.FailureThreshold != nil {
+		result.FailureThreshold = *probe.FailureThreshold
+	}
+	return result
+}
+
+// getSidecarContainers composes the specs for any sidecar containers that
+// the app declares for the given role. Sidecars are not involved in app
+// setup, so they only get the mounts for the persisted directories that they
+// ask for (and only if the role has persistent storage). Each directory is
+// mounted from whichever of the role's volumes it is persisted on.
+func getSidecarContainers(
+	role *kdv1.Role,
+	sidecars []kdv1.Sidecar,
+	pvcNamePrefix string,
+	persistVolumes []PersistedVolume,
+	securityContext *v1.SecurityContext,
+) []v1.Container {
+
+	var containers []v1.Container
+	for _, sidecar := range sidecars {
+		var volumeMounts []v1.VolumeMount
+		if role.Storage != nil {
+			for _, dir := range sidecar.PersistDirs {
+				volumeMounts = append(
+					volumeMounts,
+					generateClaimMounts(
+						claimForDir(pvcNamePrefix, persistVolumes, dir),
+						[]string{dir},
+					)...,
+				)
+			}
+		}
+		containers = append(
+			containers,
+			v1.Container{
+				Name:            sidecar.Name,
+				Image:           sidecar.Image,
+				Command:         sidecar.Command,
+				Args:            sidecar.Args,
+				Ports:           sidecar.Ports,
+				Resources:       sidecar.Resources,
+				Env:             sidecar.Env,
+				VolumeMounts:    volumeMounts,
+				SecurityContext: securityContext,
+			},
+		)
+	}
+	return containers
+}
+
 // getVolumeClaimTemplate prepares the PVC templates to be used with the
 // given role (for acquiring shared persistent storage). The result will be
 // empty if the role does not use shared persistent storage. If the spec contains
-// Storage field, a volume Volume Claim with Filesystem volume mode is created. If spec contains a BlockStorage field,
+// Storage field, a volume Volume Claim with Filesystem volume mode is created,
+// along with one for each of its named volumes. If spec contains a BlockStorage field,
 // BlockStorage field, a block Claim with Block volume mode is created.
 func getVolumeClaimTemplate(
 	cr *kdv1.KubeDirectorCluster,
@@ -595,6 +1031,27 @@ func getVolumeClaimTemplate(
 			},
 		}
 		volTemplate = append(volTemplate, volClaim)
+
+		for _, volume := range role.Storage.Volumes {
+			namedVolSize, _ := resource.ParseQuantity(volume.Size)
+			namedVolClaim := v1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: volumeClaimName(volume.Name),
+				},
+				Spec: v1.PersistentVolumeClaimSpec{
+					AccessModes: []v1.PersistentVolumeAccessMode{
+						v1.ReadWriteOnce,
+					},
+					Resources: v1.ResourceRequirements{
+						Requests: v1.ResourceList{
+							v1.ResourceStorage: namedVolSize,
+						},
+					},
+					StorageClassName: volume.StorageClass,
+				},
+			}
+			volTemplate = append(volTemplate, namedVolClaim)
+		}
 	}
 
 	if role.BlockStorage != nil {
@@ -714,13 +1171,33 @@ func generateCpCmd(
 // generateInitContainerLaunch generates the container entrypoint command for
 // init containers. This command will populate the initial contents of the
 // directories-to-be-persisted under the "/mnt" directory on the init
-// container filesystem, then terminate the container.
+// container filesystem (and those of each named volume under its own mount
+// directory), then terminate the container.
 func generateInitContainerLaunch(
 	persistDirs []string,
+	persistVolumes []PersistedVolume,
 ) string {
 
+	// Named volumes are populated first, each guarded by its own marker
+	// file. Storage init progress is only reported for the main volume, so
+	// these are just copied with cp.
+	var volumesCmd strings.Builder
+	for _, volume := range persistVolumes {
+		mountDir := volumeInitMountPrefix + volume.ClaimName
+		volumesCmd.WriteString(
+			fmt.Sprintf("! [ -f %s/%s ] && cp --parent -ax %s %s; touch %s/%s; ",
+				mountDir,
+				volumeInitMarker,
+				strings.Join(volume.Dirs, " "),
+				mountDir,
+				mountDir,
+				volumeInitMarker))
+	}
+
 	// To be safe in the case that this container is restarted by someone,
 	// don't do this copy if the kubedirector.init file already exists in /etc.
+	// This also skips the copy for a member of a cloned cluster, since its
+	// storage was cloned from an already-initialized member.
 	copyCondition := fmt.Sprintf("! [ -f /mnt%s ]", kubedirectorInit)
 
 	// In order to perform copying rsync will be used.
@@ -729,7 +1206,8 @@ func generateInitContainerLaunch(
 	rsyncInstalled := generateRsyncInstalledCmd()
 
 	// If the rsync command is not available the cp command will be used.
-	fullCmd := fmt.Sprintf("%s %s && ( [ ${RSYNC_CHECK_STATUS} != 0 ] && (%s) || (%s)); touch /mnt%s;",
+	fullCmd := fmt.Sprintf("%s%s %s && ( [ ${RSYNC_CHECK_STATUS} != 0 ] && (%s) || (%s)); touch /mnt%s;",
+		volumesCmd.String(),
 		rsyncInstalled,
 		copyCondition,
 		generateCpCmd(persistDirs),
@@ -826,8 +1304,6 @@ func generateVolumeProjectionMounts(
 				},
 			},
 		}
-	return []v1.VolumeMount{}, []v1.Volume{}
-
 }
 
 // GenerateVolumeMounts generates all of an app container's volume and mount
@@ -835,13 +1311,16 @@ func generateVolumeProjectionMounts(
 // that are appropriate for members of the given role. For systemctl support,
 // nativeSystemdSupport flag is examined along with the app requirement.
 // Additionally generate volume mount spec if a role has
-// requested for volume projections.
+// requested for volume projections. The persistDirs are mounted from the
+// main persistent volume, and the dirs of each of the persistVolumes from
+// that named volume.
 func GenerateVolumeMounts(
 	cr *kdv1.KubeDirectorCluster,
 	role *kdv1.Role,
 	pvcNamePrefix string,
 	nativeSystemdSupport bool,
 	persistDirs []string,
+	persistVolumes []PersistedVolume,
 ) ([]v1.VolumeMount, []v1.Volume, error) {
 
 	var volumeMounts []v1.VolumeMount
@@ -849,6 +1328,12 @@ func GenerateVolumeMounts(
 
 	if role.Storage != nil {
 		volumeMounts = generateClaimMounts(pvcNamePrefix, persistDirs)
+		for _, volume := range persistVolumes {
+			volumeMounts = append(
+				volumeMounts,
+				generateClaimMounts(volume.ClaimName, volume.Dirs)...,
+			)
+		}
 	}
 
 	tmpfsVolMnts, tmpfsVols := generateTmpfsSupport(cr)
@@ -911,18 +1396,91 @@ func generateClaimMounts(
 }
 
 // generateInitVolumeMounts creates the spec for mounting a persistent volume
-// into an init container.
+// into an init container, along with any named volumes of the role.
 func generateInitVolumeMounts(
 	pvcNamePrefix string,
+	persistVolumes []PersistedVolume,
 ) []v1.VolumeMount {
 
-	return []v1.VolumeMount{
+	volumeMounts := []v1.VolumeMount{
 		v1.VolumeMount{
 			MountPath: "/mnt",
 			Name:      pvcNamePrefix,
 			ReadOnly:  false,
 		},
 	}
+	for _, volume := range persistVolumes {
+		volumeMounts = append(
+			volumeMounts,
+			v1.VolumeMount{
+				MountPath: volumeInitMountPrefix + volume.ClaimName,
+				Name:      volume.ClaimName,
+				ReadOnly:  false,
+			},
+		)
+	}
+	return volumeMounts
+}
+
+// rolePersistVolumes returns the named persistent volumes provided for the
+// given role, along with the (absolute) dirs that the app places on each.
+// App volumes that the role does not provide are not included; their dirs
+// stay on the main volume.
+func rolePersistVolumes(
+	cr *kdv1.KubeDirectorCluster,
+	role *kdv1.Role,
+) ([]PersistedVolume, error) {
+
+	if (role.Storage == nil) || (len(role.Storage.Volumes) == 0) {
+		return nil, nil
+	}
+	appVolumes, appVolumesErr := catalog.AppPersistVolumes(cr, role.Name)
+	if appVolumesErr != nil {
+		return nil, appVolumesErr
+	}
+	var persistVolumes []PersistedVolume
+	for _, volume := range role.Storage.Volumes {
+		for _, appVolume := range appVolumes {
+			if appVolume.Name != volume.Name {
+				continue
+			}
+			dirs := make([]string, 0, len(appVolume.PersistDirs))
+			for _, dir := range appVolume.PersistDirs {
+				absDir, _ := filepath.Abs(dir)
+				dirs = append(dirs, absDir)
+			}
+			persistVolumes = append(
+				persistVolumes,
+				PersistedVolume{
+					ClaimName: volumeClaimName(volume.Name),
+					Dirs:      dirs,
+				},
+			)
+			break
+		}
+	}
+	return persistVolumes, nil
+}
+
+// claimForDir returns the name of the volume claim template for the volume
+// that the given dir is persisted on: the named volume with a dir that is
+// the same as or a parent of the given dir, if any, or else the main volume.
+func claimForDir(
+	pvcNamePrefix string,
+	persistVolumes []PersistedVolume,
+	dir string,
+) string {
+
+	absDir, _ := filepath.Abs(dir)
+	for _, volume := range persistVolumes {
+		for _, volumeDir := range volume.Dirs {
+			rel, _ := filepath.Rel(volumeDir, absDir)
+			if !strings.HasPrefix(rel, "..") {
+				return volume.ClaimName
+			}
+		}
+	}
+	return pvcNamePrefix
 }
 
 // generateSystemdSupport creates the volume and mount specs necessary for
@@ -1020,11 +1578,14 @@ func generateTmpfsSupport(
 	return volumeMounts, volumes
 }
 
-// generateSecurityContext creates security context with Add Capabilities property
-// based on app's capability list. If app doesn't require additional capabilities
-// return nil
+// generateSecurityContext creates the security context for the app
+// container. This is the container security context for the role (see
+// generateContainerSecurityContext), plus the app's capability list in
+// the Add Capabilities property. If neither is needed return nil.
 func generateSecurityContext(
 	cr *kdv1.KubeDirectorCluster,
+	roleSecurity *kdv1.SecurityContext,
+	restrictedMode bool,
 ) (*v1.SecurityContext, error) {
 
 	appCapabilities, err := catalog.AppCapabilities(cr)
@@ -1032,15 +1593,254 @@ func generateSecurityContext(
 		return nil, err
 	}
 
+	securityContext := generateContainerSecurityContext(roleSecurity, restrictedMode)
 	if len(appCapabilities) == 0 {
-		return nil, err
+		return securityContext, nil
+	}
+	if securityContext == nil {
+		securityContext = &v1.SecurityContext{}
+	}
+	if securityContext.Capabilities == nil {
+		securityContext.Capabilities = &v1.Capabilities{}
 	}
+	securityContext.Capabilities.Add = appCapabilities
+	return securityContext, nil
+}
 
-	return &v1.SecurityContext{
-		Capabilities: &v1.Capabilities{
-			Add: appCapabilities,
-		},
-	}, nil
+// generateContainerSecurityContext creates the security context properties
+// that apply to every container of a role: readOnlyRootFilesystem and the
+// dropped capabilities. In restricted mode all capabilities are dropped and
+// privilege escalation is not allowed. Returns nil if there is nothing to
+// set.
+func generateContainerSecurityContext(
+	roleSecurity *kdv1.SecurityContext,
+	restrictedMode bool,
+) *v1.SecurityContext {
+
+	var dropCapabilities []v1.Capability
+	var readOnlyRootFilesystem *bool
+	if roleSecurity != nil {
+		dropCapabilities = roleSecurity.DropCapabilities
+		readOnlyRootFilesystem = roleSecurity.ReadOnlyRootFilesystem
+	}
+	if !restrictedMode && (len(dropCapabilities) == 0) && (readOnlyRootFilesystem == nil) {
+		return nil
+	}
+	securityContext := &v1.SecurityContext{
+		ReadOnlyRootFilesystem: readOnlyRootFilesystem,
+	}
+	if restrictedMode {
+		allowPrivilegeEscalation := false
+		securityContext.AllowPrivilegeEscalation = &allowPrivilegeEscalation
+		dropCapabilities = []v1.Capability{"ALL"}
+	}
+	if len(dropCapabilities) != 0 {
+		securityContext.Capabilities = &v1.Capabilities{
+			Drop: dropCapabilities,
+		}
+	}
+	return securityContext
+}
+
+// generatePodSecurityContext creates the pod security context for a role
+// from its user, group, and fsGroup settings. In restricted mode the pod is
+// always required to run as non-root. Returns nil if the role has no
+// security context and restricted mode is off.
+func generatePodSecurityContext(
+	roleSecurity *kdv1.SecurityContext,
+	restrictedMode bool,
+) *v1.PodSecurityContext {
+
+	if (roleSecurity == nil) && !restrictedMode {
+		return nil
+	}
+	podSecurityContext := &v1.PodSecurityContext{}
+	if roleSecurity != nil {
+		podSecurityContext.RunAsUser = roleSecurity.RunAsUser
+		podSecurityContext.RunAsGroup = roleSecurity.RunAsGroup
+		podSecurityContext.RunAsNonRoot = roleSecurity.RunAsNonRoot
+		podSecurityContext.FSGroup = roleSecurity.FSGroup
+	}
+	if restrictedMode {
+		runAsNonRoot := true
+		podSecurityContext.RunAsNonRoot = &runAsNonRoot
+	}
+	return podSecurityContext
+}
+
+// seccompAnnotation returns the value of the pod seccomp annotation for the
+// role's seccomp profile. In restricted mode the runtime default profile is
+// used unless the role asks for a localhost profile. Returns an empty string
+// if no annotation is needed.
+func seccompAnnotation(
+	roleSecurity *kdv1.SecurityContext,
+	restrictedMode bool,
+) string {
+
+	var profile *kdv1.SeccompProfile
+	if roleSecurity != nil {
+		profile = roleSecurity.SeccompProfile
+	}
+	if profile == nil {
+		if restrictedMode {
+			return v1.SeccompProfileRuntimeDefault
+		}
+		return ""
+	}
+	switch profile.Type {
+	case kdv1.SeccompProfileRuntimeDefault:
+		return v1.SeccompProfileRuntimeDefault
+	case kdv1.SeccompProfileLocalhost:
+		if profile.LocalhostProfile != nil {
+			return "localhost/" + *profile.LocalhostProfile
+		}
+	case kdv1.SeccompProfileUnconfined:
+		return "unconfined"
+	}
+	return ""
+}
+
+// seccompProfileField returns the pod seccompProfile security context
+// property for the role's seccomp profile, in K8s API form. The K8s API
+// library that KubeDirector is built with predates this property (hence
+// the seccomp annotation, which older K8s versions use instead), so it is
+// added to the pod template through an unstructured object. Returns nil if
+// no profile is needed.
+func seccompProfileField(
+	roleSecurity *kdv1.SecurityContext,
+	restrictedMode bool,
+) map[string]interface{} {
+
+	var profile *kdv1.SeccompProfile
+	if roleSecurity != nil {
+		profile = roleSecurity.SeccompProfile
+	}
+	if profile == nil {
+		if restrictedMode {
+			return map[string]interface{}{"type": kdv1.SeccompProfileRuntimeDefault}
+		}
+		return nil
+	}
+	switch profile.Type {
+	case kdv1.SeccompProfileRuntimeDefault, kdv1.SeccompProfileUnconfined:
+		return map[string]interface{}{"type": profile.Type}
+	case kdv1.SeccompProfileLocalhost:
+		if profile.LocalhostProfile != nil {
+			return map[string]interface{}{
+				"type":             profile.Type,
+				"localhostProfile": *profile.LocalhostProfile,
+			}
+		}
+	}
+	return nil
+}
+
+// roleSeccompProfile returns the pod seccompProfile security context
+// property for the given role, or nil if no profile is needed.
+func roleSeccompProfile(
+	cr *kdv1.KubeDirectorCluster,
+	role *kdv1.Role,
+) (map[string]interface{}, error) {
+
+	roleSecurity, roleSecurityErr := catalog.RoleSecurityContext(cr, role.Name)
+	if roleSecurityErr != nil {
+		return nil, roleSecurityErr
+	}
+	return seccompProfileField(roleSecurity, shared.GetRestrictedMode()), nil
+}
+
+// statefulSetWithSeccompProfile converts a statefulset to an unstructured
+// object, setting the seccompProfile of its pod template if the given
+// profile is not nil.
+func statefulSetWithSeccompProfile(
+	statefulSet *appsv1.StatefulSet,
+	seccompProfile map[string]interface{},
+) (*unstructured.Unstructured, error) {
+
+	content, convertErr := runtime.DefaultUnstructuredConverter.ToUnstructured(statefulSet)
+	if convertErr != nil {
+		return nil, convertErr
+	}
+	result := &unstructured.Unstructured{Object: content}
+	result.SetGroupVersionKind(appsv1.SchemeGroupVersion.WithKind("StatefulSet"))
+	if seccompProfile != nil {
+		setErr := unstructured.SetNestedMap(
+			result.Object,
+			seccompProfile,
+			"spec", "template", "spec", "securityContext", "seccompProfile",
+		)
+		if setErr != nil {
+			return nil, setErr
+		}
+	}
+	return result, nil
+}
+
+// runsAsRoot reports whether the app container of a role may run as root,
+// i.e. it is not in restricted mode and the role does not ask for a non-root
+// user.
+func runsAsRoot(
+	roleSecurity *kdv1.SecurityContext,
+	restrictedMode bool,
+) bool {
+
+	if restrictedMode {
+		return false
+	}
+	if roleSecurity == nil {
+		return true
+	}
+	if (roleSecurity.RunAsNonRoot != nil) && *roleSecurity.RunAsNonRoot {
+		return false
+	}
+	return (roleSecurity.RunAsUser == nil) || (*roleSecurity.RunAsUser == 0)
+}
+
+// setPodScheduling fills in the scheduling properties of a role's pod spec,
+// from the role spec or else from any matching scheduling defaults in the
+// KubeDirectorConfig. The nodeSelector is the union of the default and role
+// nodeSelectors, with the role's values taking precedence.
+func setPodScheduling(
+	cr *kdv1.KubeDirectorCluster,
+	role *kdv1.Role,
+	podSpec *v1.PodSpec,
+) {
+
+	podSpec.Tolerations = role.Tolerations
+	podSpec.PriorityClassName = role.PriorityClassName
+	podSpec.SchedulerName = role.SchedulerName
+	podSpec.RuntimeClassName = role.RuntimeClassName
+	podSpec.TopologySpreadConstraints = role.TopologySpreadConstraints
+
+	defaults := shared.GetSchedulingDefaults(cr.Spec.AppID, cr.Namespace)
+	if defaults == nil {
+		podSpec.NodeSelector = role.NodeSelector
+		return
+	}
+	if podSpec.Tolerations == nil {
+		podSpec.Tolerations = defaults.Tolerations
+	}
+	if podSpec.PriorityClassName == "" {
+		podSpec.PriorityClassName = defaults.PriorityClassName
+	}
+	if podSpec.SchedulerName == "" {
+		podSpec.SchedulerName = defaults.SchedulerName
+	}
+	if podSpec.RuntimeClassName == nil {
+		podSpec.RuntimeClassName = defaults.RuntimeClassName
+	}
+	if podSpec.TopologySpreadConstraints == nil {
+		podSpec.TopologySpreadConstraints = defaults.TopologySpreadConstraints
+	}
+	podSpec.NodeSelector = defaults.NodeSelector
+	if len(role.NodeSelector) != 0 {
+		if podSpec.NodeSelector == nil {
+			podSpec.NodeSelector = make(map[string]string)
+		}
+		for key, value := range role.NodeSelector {
+			podSpec.NodeSelector[key] = value
+		}
+	}
 }
 
 // hasSTDIN is a utility function to find out
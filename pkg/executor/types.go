@@ -38,17 +38,66 @@ const (
 	// statefulset, pod, and service, with a value of the KubeDirectorApp's
 	// spec.label.name.
 	ClusterAppAnnotation = shared.KdDomainBase + "/kdapp-prettyName"
+	// roleSpecHashAnnotation is an annotation placed on every created
+	// statefulset, with a value that is a hash of the role spec properties
+	// used to generate its pod template.
+	roleSpecHashAnnotation = shared.KdDomainBase + "/roleSpecHash"
+	// dashboardRouteHashAnnotation is an annotation placed on every created
+	// Ingress or HTTPRoute for a dashboard endpoint, with a value that is a
+	// hash of the settings used to generate it.
+	dashboardRouteHashAnnotation = shared.KdDomainBase + "/dashboardRouteHash"
+	// istioRoutingHashAnnotation is an annotation placed on every created
+	// Istio VirtualService or DestinationRule, with a value that is a hash
+	// of the settings used to generate it.
+	istioRoutingHashAnnotation = shared.KdDomainBase + "/istioRoutingHash"
+	// podMonitorHashAnnotation is an annotation placed on every created
+	// Prometheus PodMonitor, with a value that is a hash of the spec used to
+	// generate it.
+	podMonitorHashAnnotation = shared.KdDomainBase + "/podMonitorHash"
+	// prometheusScrapeAnnotation, prometheusPortAnnotation,
+	// prometheusPathAnnotation, and prometheusSchemeAnnotation are the
+	// conventional pod annotations for Prometheus scraping, used if the
+	// PodMonitor resource type is not available.
+	prometheusScrapeAnnotation = "prometheus.io/scrape"
+	prometheusPortAnnotation   = "prometheus.io/port"
+	prometheusPathAnnotation   = "prometheus.io/path"
+	prometheusSchemeAnnotation = "prometheus.io/scheme"
+	// istioProxyConfigAnnotation, istioExcludeInboundAnnotation, and
+	// istioExcludeOutboundAnnotation are placed on pods in Istio mode to
+	// configure the injected sidecar proxy.
+	istioProxyConfigAnnotation     = "proxy.istio.io/config"
+	istioExcludeInboundAnnotation  = "traffic.sidecar.istio.io/excludeInboundPorts"
+	istioExcludeOutboundAnnotation = "traffic.sidecar.istio.io/excludeOutboundPorts"
+	// istioProxyContainerName is the name of the sidecar container that
+	// Istio injects into pods.
+	istioProxyContainerName = "istio-proxy"
 
 	statefulSetPodLabel = "statefulset.kubernetes.io/pod-name"
 	// AppContainerName is the name of KubeDirector app containers.
 	AppContainerName = "app"
+	// InitContainerName is the name of KubeDirector init containers.
+	InitContainerName = "init"
+	// defaultContainerAnnotation is placed on pods that have sidecars, so
+	// that kubectl exec and logs will use the app container by default.
+	defaultContainerAnnotation = "kubectl.kubernetes.io/default-container"
 	// PvcNamePrefix (along with a hyphen) is prepended to the name of each
 	// member PVC name that is auto-created for a statefulset.
-	PvcNamePrefix         = "p"
+	PvcNamePrefix = "p"
+	// volumePvcNamePrefix (along with a hyphen) is prepended to the name of
+	// each named persistent volume of a role to form the name of its volume
+	// claim template.
+	volumePvcNamePrefix = "v"
+	// volumeInitMountPrefix is prepended to the claim template name of a
+	// named persistent volume to form its mount path in the init container.
+	volumeInitMountPrefix = "/mnt-"
+	// volumeInitMarker is created on a named persistent volume once the init
+	// container has populated it.
+	volumeInitMarker      = "kubedirector.init"
 	svcNamePrefix         = "s-"
+	internalSvcNamePrefix = "si-"
+	internalSvcNameSuffix = "-int"
 	statefulSetNamePrefix = "kdss-"
 	headlessSvcNamePrefix = "kdhs-"
-	initContainerName     = "init"
 	execShell             = "bash"
 	configMetaFile        = "/etc/guestconfig/configmeta.json"
 	cgroupFSVolume        = "/sys/fs/cgroup"
@@ -91,3 +140,11 @@ type Streams struct {
 	Out    io.Writer
 	ErrOut io.Writer
 }
+
+// PersistedVolume identifies one of a role's named persistent volumes, by
+// the name of its volume claim template, along with the directories that are
+// persisted on it.
+type PersistedVolume struct {
+	ClaimName string
+	Dirs      []string
+}
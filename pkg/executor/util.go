@@ -78,20 +78,28 @@ func annotationsForStatefulSet(
 
 // annotationsForPod generates a set of annotations appropriate for a pod in
 // the given role. This includes any user-requested or global-config
-// annotations.
+// annotations, and the Prometheus scrape annotations if the role has a
+// metrics endpoint that can't be handled by a PodMonitor.
 func annotationsForPod(
 	cr *kdv1.KubeDirectorCluster,
 	role *kdv1.Role,
-) map[string]string {
+) (map[string]string, error) {
 
 	result := annotationsForStatefulSet(cr, role)
+	scrapeAnnotations, scrapeErr := metricsAnnotations(cr, role)
+	if scrapeErr != nil {
+		return nil, scrapeErr
+	}
+	for name, value := range scrapeAnnotations {
+		result[name] = value
+	}
 	for name, value := range role.PodAnnotations {
 		result[name] = value
 	}
 	for globalName, globalValue := range shared.GetPodAnnotations() {
 		result[globalName] = globalValue
 	}
-	return result
+	return result, nil
 }
 
 // annotationsForService generates a set of annotations appropriate for the
@@ -198,15 +206,26 @@ func labelsForService(
 
 // createPortNameForService creates the port name for a service endpoint.
 // It prefixes the ID with the lowercased URL scheme if given; otherwise
-// prefixing with "generic-".
+// prefixing with "generic-". In Istio mode the prefix tells Istio which
+// protocol the port uses, so a scheme that Istio does not recognize (or no
+// scheme) gives a "tcp-" prefix instead. Existing services are given the
+// new names in place when Istio mode is turned on or off; see
+// renameServicePorts.
 func createPortNameForService(
 	portInfo catalog.ServicePortInfo,
 ) string {
 
-	if portInfo.URLScheme == "" {
+	scheme := strings.ToLower(portInfo.URLScheme)
+	if shared.GetIstioSettings() != nil {
+		if !shared.StringInList(scheme, istioPortProtocols) {
+			return "tcp-" + portInfo.ID
+		}
+		return scheme + "-" + portInfo.ID
+	}
+	if scheme == "" {
 		return "generic-" + portInfo.ID
 	}
-	return strings.ToLower(portInfo.URLScheme) + "-" + portInfo.ID
+	return scheme + "-" + portInfo.ID
 }
 
 // MungObjectName is a utility function that truncates the object names
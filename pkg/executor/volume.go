@@ -17,10 +17,14 @@ package executor
 import (
 	"context"
 
+	kdv1 "github.com/bluek8s/kubedirector/pkg/apis/kubedirector/v1beta1"
 	"github.com/bluek8s/kubedirector/pkg/shared"
 
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 // DeletePVC deletes a persistent volume claim from k8s.
@@ -41,3 +45,173 @@ func DeletePVC(
 	}
 	return shared.Delete(context.TODO(), toDelete)
 }
+
+// ReleasePVC removes any owner references to the given virtual cluster from
+// a persistent volume claim, so that the claim is not garbage-collected when
+// the cluster is deleted. Nothing is done if the claim does not exist.
+func ReleasePVC(
+	cr *kdv1.KubeDirectorCluster,
+	pvcName string,
+) error {
+
+	pvc := &v1.PersistentVolumeClaim{}
+	getErr := shared.Get(
+		context.TODO(),
+		types.NamespacedName{Namespace: cr.Namespace, Name: pvcName},
+		pvc,
+	)
+	if getErr != nil {
+		if errors.IsNotFound(getErr) {
+			return nil
+		}
+		return getErr
+	}
+	var ownerRefs []metav1.OwnerReference
+	for _, ownerRef := range pvc.OwnerReferences {
+		if ownerRef.UID != cr.UID {
+			ownerRefs = append(ownerRefs, ownerRef)
+		}
+	}
+	if len(ownerRefs) == len(pvc.OwnerReferences) {
+		return nil
+	}
+	patchedRes := *pvc
+	patchedRes.OwnerReferences = ownerRefs
+	return shared.Patch(
+		context.TODO(),
+		pvc,
+		&patchedRes,
+	)
+}
+
+// CreatePVCFromDataSource creates the persistent volume claim for a member of
+// the given role from the named volume claim template, populated from the
+// given data source (a CSI VolumeSnapshot or another PVC). The claim matches
+// the one the role's statefulset would create from that template, so the
+// statefulset will adopt it when creating the member's pod. Nothing is done
+// if the role has no such template.
+func CreatePVCFromDataSource(
+	cr *kdv1.KubeDirectorCluster,
+	role *kdv1.Role,
+	claimName string,
+	podName string,
+	dataSource *v1.TypedLocalObjectReference,
+) error {
+
+	for _, template := range getVolumeClaimTemplate(cr, role, PvcNamePrefix) {
+		if template.Name != claimName {
+			continue
+		}
+		pvc := &v1.PersistentVolumeClaim{
+			TypeMeta: metav1.TypeMeta{
+				Kind:       "PersistentVolumeClaim",
+				APIVersion: "v1",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      MemberPVCName(claimName, podName),
+				Namespace: cr.Namespace,
+				Labels:    labelsForPod(cr, role),
+			},
+			Spec: template.Spec,
+		}
+		pvc.Spec.DataSource = dataSource
+		return shared.Create(context.TODO(), pvc)
+	}
+	return nil
+}
+
+// StorageClaimNames returns the names of the volume claim templates for the
+// persistent volumes of the given role: the main volume first, then any
+// named volumes. The result is empty if the role does not use persistent
+// storage. Block storage devices are not included.
+func StorageClaimNames(
+	role *kdv1.Role,
+) []string {
+
+	if role.Storage == nil {
+		return nil
+	}
+	claimNames := []string{PvcNamePrefix}
+	for _, volume := range role.Storage.Volumes {
+		claimNames = append(claimNames, volumeClaimName(volume.Name))
+	}
+	return claimNames
+}
+
+// StorageClaimSizes returns the requested size of each of the persistent
+// volumes of the given role, keyed by volume claim template name. Block
+// storage devices are not included.
+func StorageClaimSizes(
+	role *kdv1.Role,
+) map[string]resource.Quantity {
+
+	sizes := make(map[string]resource.Quantity)
+	if role.Storage == nil {
+		return sizes
+	}
+	sizes[PvcNamePrefix], _ = resource.ParseQuantity(role.Storage.Size)
+	for _, volume := range role.Storage.Volumes {
+		sizes[volumeClaimName(volume.Name)], _ = resource.ParseQuantity(volume.Size)
+	}
+	return sizes
+}
+
+// ExpandPVC requests a larger size for an existing persistent volume claim.
+// The storage class of the claim must allow volume expansion. On success the
+// input claim object is updated to reflect the change.
+func ExpandPVC(
+	pvc *v1.PersistentVolumeClaim,
+	size resource.Quantity,
+) error {
+
+	patchedRes := *pvc
+	patchedRes.Spec.Resources.Requests = make(v1.ResourceList)
+	for name, quantity := range pvc.Spec.Resources.Requests {
+		patchedRes.Spec.Resources.Requests[name] = quantity
+	}
+	patchedRes.Spec.Resources.Requests[v1.ResourceStorage] = size
+	patchErr := shared.Patch(
+		context.TODO(),
+		pvc,
+		&patchedRes,
+	)
+	if patchErr == nil {
+		*pvc = patchedRes
+	}
+	return patchErr
+}
+
+// MemberPVCName returns the name of the PVC that a statefulset creates for
+// the given member pod from the given volume claim template.
+func MemberPVCName(
+	claimName string,
+	podName string,
+) string {
+
+	return claimName + "-" + podName
+}
+
+// MemberPVCs returns the names of all of the persistent volume claims of a
+// member, main volume first. Members created before roles could have named
+// volumes only record their main PVC.
+func MemberPVCs(
+	member *kdv1.MemberStatus,
+) []string {
+
+	if len(member.PVCs) != 0 {
+		return member.PVCs
+	}
+	if member.PVC != "" {
+		return []string{member.PVC}
+	}
+	return nil
+}
+
+// volumeClaimName returns the name of the volume claim template for a named
+// persistent volume of a role.
+func volumeClaimName(
+	volumeName string,
+) string {
+
+	return volumePvcNamePrefix + "-" + volumeName
+}
@@ -25,6 +25,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	storagev1 "k8s.io/api/storage/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 )
@@ -77,6 +78,28 @@ func GetStatefulSet(
 	return result, err
 }
 
+// GetPodDisruptionBudget finds the k8s PodDisruptionBudget with the given
+// name in the given namespace. The budget is returned as an unstructured
+// object, since its API version depends on the K8s version.
+func GetPodDisruptionBudget(
+	namespace string,
+	pdbName string,
+) (*unstructured.Unstructured, error) {
+
+	gvk, gvkErr := shared.PodDisruptionBudgetGVK()
+	if gvkErr != nil {
+		return nil, gvkErr
+	}
+	result := &unstructured.Unstructured{}
+	result.SetGroupVersionKind(gvk)
+	err := shared.Get(
+		context.TODO(),
+		types.NamespacedName{Namespace: namespace, Name: pdbName},
+		result,
+	)
+	return result, err
+}
+
 // GetService finds the k8s Service with the given name in the given namespace.
 func GetService(
 	namespace string,
@@ -153,6 +176,124 @@ func GetPVC(
 	return result, err
 }
 
+// GetVolumeSnapshot finds the CSI VolumeSnapshot with the given name in the
+// given namespace. The snapshot is returned as an unstructured object.
+func GetVolumeSnapshot(
+	namespace string,
+	snapshotName string,
+) (*unstructured.Unstructured, error) {
+
+	result := &unstructured.Unstructured{}
+	result.SetGroupVersionKind(
+		schema.GroupVersionKind{
+			Group:   shared.VolumeSnapshotGroup,
+			Version: shared.VolumeSnapshotVersion,
+			Kind:    shared.VolumeSnapshotKind,
+		},
+	)
+	err := shared.Get(
+		context.TODO(),
+		types.NamespacedName{Namespace: namespace, Name: snapshotName},
+		result,
+	)
+	return result, err
+}
+
+// GetIngress finds the k8s Ingress with the given name in the given
+// namespace. The Ingress is returned as an unstructured networking.k8s.io/v1
+// object.
+func GetIngress(
+	namespace string,
+	ingressName string,
+) (*unstructured.Unstructured, error) {
+
+	result := &unstructured.Unstructured{}
+	result.SetGroupVersionKind(
+		schema.GroupVersionKind{
+			Group:   shared.IngressGroup,
+			Version: shared.IngressVersion,
+			Kind:    shared.IngressKind,
+		},
+	)
+	err := shared.Get(
+		context.TODO(),
+		types.NamespacedName{Namespace: namespace, Name: ingressName},
+		result,
+	)
+	return result, err
+}
+
+// GetHTTPRoute finds the Gateway API HTTPRoute with the given name in the
+// given namespace. The route is returned as an unstructured object.
+func GetHTTPRoute(
+	namespace string,
+	routeName string,
+) (*unstructured.Unstructured, error) {
+
+	result := &unstructured.Unstructured{}
+	result.SetGroupVersionKind(
+		schema.GroupVersionKind{
+			Group:   shared.GatewayAPIGroup,
+			Version: shared.GatewayAPIVersion,
+			Kind:    shared.HTTPRouteKind,
+		},
+	)
+	err := shared.Get(
+		context.TODO(),
+		types.NamespacedName{Namespace: namespace, Name: routeName},
+		result,
+	)
+	return result, err
+}
+
+// GetIstioObject finds the Istio networking object (e.g. VirtualService or
+// DestinationRule) of the given kind and name in the given namespace. The
+// object is returned as an unstructured object.
+func GetIstioObject(
+	namespace string,
+	kind string,
+	objectName string,
+) (*unstructured.Unstructured, error) {
+
+	result := &unstructured.Unstructured{}
+	result.SetGroupVersionKind(
+		schema.GroupVersionKind{
+			Group:   shared.IstioNetworkingGroup,
+			Version: shared.IstioNetworkingVersion,
+			Kind:    kind,
+		},
+	)
+	err := shared.Get(
+		context.TODO(),
+		types.NamespacedName{Namespace: namespace, Name: objectName},
+		result,
+	)
+	return result, err
+}
+
+// GetPodMonitor finds the Prometheus Operator PodMonitor with the given name
+// in the given namespace. The monitor is returned as an unstructured object.
+func GetPodMonitor(
+	namespace string,
+	monitorName string,
+) (*unstructured.Unstructured, error) {
+
+	result := &unstructured.Unstructured{}
+	result.SetGroupVersionKind(
+		schema.GroupVersionKind{
+			Group:   shared.PrometheusMonitoringGroup,
+			Version: shared.PrometheusMonitoringVersion,
+			Kind:    shared.PodMonitorKind,
+		},
+	)
+	err := shared.Get(
+		context.TODO(),
+		types.NamespacedName{Namespace: namespace, Name: monitorName},
+		result,
+	)
+	return result, err
+}
+
 // GetServiceAccount finds the k8s ServiceAccount with the given name in the given
 // namespace.
 func GetServiceAccount(
@@ -24,6 +24,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
@@ -280,3 +281,50 @@ func K8sVersionIsAtLeast(
 	}
 	return true, nil
 }
+
+// ResourceKindIsServed asks the K8s API server whether it serves the given
+// kind of resource in the given API group and version, e.g. whether the CRD
+// for that kind has been installed. Returns true iff the kind is served.
+func ResourceKindIsServed(
+	group string,
+	version string,
+	kind string,
+) (bool, error) {
+
+	groupVersion := schema.GroupVersion{Group: group, Version: version}
+	resources, resourcesErr := clientSet.Discovery().ServerResourcesForGroupVersion(
+		groupVersion.String(),
+	)
+	if resourcesErr != nil {
+		if errors.IsNotFound(resourcesErr) {
+			return false, nil
+		}
+		return false, resourcesErr
+	}
+	for _, resource := range resources.APIResources {
+		if resource.Kind == kind {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// PodDisruptionBudgetGVK returns the group, version, and kind to use for
+// PodDisruptionBudgets: policy/v1 if the K8s cluster serves it (K8s 1.21 and
+// later), else policy/v1beta1 (which was removed in K8s 1.25).
+func PodDisruptionBudgetGVK() (schema.GroupVersionKind, error) {
+
+	result := schema.GroupVersionKind{
+		Group:   PolicyGroup,
+		Version: "v1",
+		Kind:    PodDisruptionBudgetKind,
+	}
+	v1Served, servedErr := ResourceKindIsServed(result.Group, result.Version, result.Kind)
+	if servedErr != nil {
+		return result, servedErr
+	}
+	if !v1Served {
+		result.Version = "v1beta1"
+	}
+	return result, nil
+}
@@ -0,0 +1,61 @@
+// Copyright 2022 Hewlett Packard Enterprise Development LP
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shared
+
+import (
+	kdv1 "github.com/bluek8s/kubedirector/pkg/apis/kubedirector/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SetCondition adds a condition of the given type to the list, or updates
+// the existing one. The last transition time is only changed if the
+// condition status changes, so that setting an unchanged condition does not
+// cause a status update.
+func SetCondition(
+	conditions *[]kdv1.Condition,
+	conditionType string,
+	status corev1.ConditionStatus,
+	reason string,
+	message string,
+	observedGeneration int64,
+) {
+
+	for i := range *conditions {
+		condition := &((*conditions)[i])
+		if condition.Type != conditionType {
+			continue
+		}
+		if condition.Status != status {
+			condition.Status = status
+			condition.LastTransitionTime = metav1.Now()
+		}
+		condition.Reason = reason
+		condition.Message = message
+		condition.ObservedGeneration = observedGeneration
+		return
+	}
+	*conditions = append(
+		*conditions,
+		kdv1.Condition{
+			Type:               conditionType,
+			Status:             status,
+			ObservedGeneration: observedGeneration,
+			LastTransitionTime: metav1.Now(),
+			Reason:             reason,
+			Message:            message,
+		},
+	)
+}
@@ -199,6 +199,124 @@ func GetForceSharedMemorySizeSupport() *bool {
 	return nil
 }
 
+// GetDefaultTerminationPolicy extracts the default member termination policy
+// from the globalConfig CR data if present, otherwise returns nil.
+func GetDefaultTerminationPolicy() *kdv1.TerminationPolicy {
+
+	globalConfigLock.RLock()
+	defer globalConfigLock.RUnlock()
+	if globalConfig != nil && globalConfig.Spec.DefaultTerminationPolicy != nil {
+		return globalConfig.Spec.DefaultTerminationPolicy.DeepCopy()
+	}
+	return nil
+}
+
+// GetDefaultPVCRetentionPolicy extracts the default PVC retention policy
+// from the globalConfig CR data if present, otherwise returns nil.
+func GetDefaultPVCRetentionPolicy() *kdv1.PVCRetentionPolicy {
+
+	globalConfigLock.RLock()
+	defer globalConfigLock.RUnlock()
+	if globalConfig != nil && globalConfig.Spec.DefaultPVCRetentionPolicy != nil {
+		return globalConfig.Spec.DefaultPVCRetentionPolicy.DeepCopy()
+	}
+	return nil
+}
+
+// GetDashboardIngress extracts the dashboard ingress settings from the
+// globalConfig CR data if present, otherwise returns nil.
+func GetDashboardIngress() *kdv1.DashboardIngress {
+
+	globalConfigLock.RLock()
+	defer globalConfigLock.RUnlock()
+	if globalConfig != nil && globalConfig.Spec.DashboardIngress != nil {
+		return globalConfig.Spec.DashboardIngress.DeepCopy()
+	}
+	return nil
+}
+
+// GetIstioSettings extracts the Istio settings from the globalConfig CR data
+// if present and enabled, otherwise returns nil.
+func GetIstioSettings() *kdv1.IstioSettings {
+
+	globalConfigLock.RLock()
+	defer globalConfigLock.RUnlock()
+	if globalConfig != nil && globalConfig.Spec.Istio != nil &&
+		globalConfig.Spec.Istio.Enabled {
+		return globalConfig.Spec.Istio.DeepCopy()
+	}
+	return nil
+}
+
+// GetRestrictedMode extracts the restricted mode flag from the globalConfig
+// CR data if present, otherwise returns false.
+func GetRestrictedMode() bool {
+
+	globalConfigLock.RLock()
+	defer globalConfigLock.RUnlock()
+	if globalConfig != nil && globalConfig.Spec.RestrictedMode != nil {
+		return *globalConfig.Spec.RestrictedMode
+	}
+	return false
+}
+
+// GetSchedulingDefaults merges the scheduling defaults from the globalConfig
+// CR data that match the given kdapp and namespace, with later entries taking
+// precedence. Returns nil if no entries match.
+func GetSchedulingDefaults(
+	appID string,
+	namespace string,
+) *kdv1.SchedulingDefaults {
+
+	globalConfigLock.RLock()
+	defer globalConfigLock.RUnlock()
+	if globalConfig == nil {
+		return nil
+	}
+	var merged *kdv1.SchedulingDefaults
+	for _, defaults := range globalConfig.Spec.SchedulingDefaults {
+		if (defaults.AppID != "") && (defaults.AppID != appID) {
+			continue
+		}
+		if (defaults.Namespace != "") && (defaults.Namespace != namespace) {
+			continue
+		}
+		if merged == nil {
+			merged = &kdv1.SchedulingDefaults{
+				AppID:     appID,
+				Namespace: namespace,
+			}
+		}
+		if defaults.Tolerations != nil {
+			merged.Tolerations = defaults.Tolerations
+		}
+		if len(defaults.NodeSelector) != 0 {
+			if merged.NodeSelector == nil {
+				merged.NodeSelector = make(map[string]string)
+			}
+			for key, value := range defaults.NodeSelector {
+				merged.NodeSelector[key] = value
+			}
+		}
+		if defaults.PriorityClassName != "" {
+			merged.PriorityClassName = defaults.PriorityClassName
+		}
+		if defaults.SchedulerName != "" {
+			merged.SchedulerName = defaults.SchedulerName
+		}
+		if defaults.RuntimeClassName != nil {
+			merged.RuntimeClassName = defaults.RuntimeClassName
+		}
+		if defaults.TopologySpreadConstraints != nil {
+			merged.TopologySpreadConstraints = defaults.TopologySpreadConstraints
+		}
+	}
+	if merged == nil {
+		return nil
+	}
+	return merged.DeepCopy()
+}
+
 // RemoveGlobalConfig removes the current globalConfig
 func RemoveGlobalConfig() {
 
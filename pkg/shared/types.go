@@ -41,6 +41,52 @@ const (
 	// writing status, to indicate whether or not a status backup exists.
 	StatusBackupAnnotation = KdDomainBase + "/status-backup-exists"
 
+	// BackupLabel is a label placed on every created VolumeSnapshot, with a
+	// value of the KubeDirectorBackup CR name.
+	BackupLabel = KdDomainBase + "/kdbackup"
+
+	// VolumeSnapshotGroup, VolumeSnapshotVersion, and VolumeSnapshotKind
+	// identify the CSI VolumeSnapshot resource type.
+	VolumeSnapshotGroup   = "snapshot.storage.k8s.io"
+	VolumeSnapshotVersion = "v1"
+	VolumeSnapshotKind    = "VolumeSnapshot"
+
+	// IngressGroup, IngressVersion, and IngressKind identify the K8s Ingress
+	// resource type, used for dashboard endpoints in the ingress dashboard
+	// mode.
+	IngressGroup   = "networking.k8s.io"
+	IngressVersion = "v1"
+	IngressKind    = "Ingress"
+
+	// GatewayAPIGroup, GatewayAPIVersion, and HTTPRouteKind identify the
+	// Gateway API HTTPRoute resource type, used for dashboard endpoints in
+	// the gateway dashboard mode.
+	GatewayAPIGroup   = "gateway.networking.k8s.io"
+	GatewayAPIVersion = "v1beta1"
+	HTTPRouteKind     = "HTTPRoute"
+
+	// IstioNetworkingGroup and IstioNetworkingVersion identify the Istio
+	// traffic management resource types, and VirtualServiceKind and
+	// DestinationRuleKind the kinds generated for each kdcluster in Istio
+	// mode.
+	IstioNetworkingGroup   = "networking.istio.io"
+	IstioNetworkingVersion = "v1beta1"
+	VirtualServiceKind     = "VirtualService"
+	DestinationRuleKind    = "DestinationRule"
+
+	// PolicyGroup and PodDisruptionBudgetKind identify the K8s
+	// PodDisruptionBudget resource type. Its version is policy/v1 if the
+	// K8s cluster serves that, else policy/v1beta1.
+	PolicyGroup             = "policy"
+	PodDisruptionBudgetKind = "PodDisruptionBudget"
+
+	// PrometheusMonitoringGroup, PrometheusMonitoringVersion, and
+	// PodMonitorKind identify the Prometheus Operator PodMonitor resource
+	// type, used to scrape app metrics endpoints.
+	PrometheusMonitoringGroup   = "monitoring.coreos.com"
+	PrometheusMonitoringVersion = "v1"
+	PodMonitorKind              = "PodMonitor"
+
 	// DefaultServiceType - default service type if not specified in
 	// the configCR
 	DefaultServiceType = "LoadBalancer"
@@ -59,20 +105,30 @@ const (
 	// old setup layout.
 	ConfigCliLegacyLoc = "/usr"
 
+	// AppStartscript is the location of the startscript from the app's setup
+	// package within the member container.
+	AppStartscript = "/opt/guestconfig/*/startscript"
+
 	// DefaultMaxLogSizeDump is the max size for stderr/stdout log dump fields
 	// that is used when a kdapp does not explicitly specify a max.
 	DefaultMaxLogSizeDump int32 = 256
+
+	// MaxKDMembers is the maximum total member count, across all roles, of
+	// a kdcluster.
+	MaxKDMembers int32 = 1000
 )
 
 // Event reason constants for recording events
 const (
-	EventReasonNoEvent   = ""
-	EventReasonCluster   = "Cluster"
-	EventReasonRole      = "Role"
-	EventReasonMember    = "Member"
-	EventReasonConfig    = "Config"
-	EventReasonConfigMap = "ConfigMap"
-	EventReasonSecret    = "Secret"
+	EventReasonNoEvent    = ""
+	EventReasonCluster    = "Cluster"
+	EventReasonRole       = "Role"
+	EventReasonMember     = "Member"
+	EventReasonConfig     = "Config"
+	EventReasonConfigMap  = "ConfigMap"
+	EventReasonSecret     = "Secret"
+	EventReasonRoleScaler = "RoleScaler"
+	EventReasonBackup     = "Backup"
 )
 
 // Settings for appCatalog
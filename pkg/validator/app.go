@@ -17,18 +17,25 @@ package validator
 import (
 	"encoding/json"
 	"fmt"
+	"path"
+	"regexp"
 	"strconv"
 	"strings"
 
 	kdv1 "github.com/bluek8s/kubedirector/pkg/apis/kubedirector/v1beta1"
 	"github.com/bluek8s/kubedirector/pkg/catalog"
+	"github.com/bluek8s/kubedirector/pkg/executor"
 	"github.com/bluek8s/kubedirector/pkg/shared"
 	av1beta1 "k8s.io/api/admission/v1beta1"
 	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
+// percentRegexp matches a percentage value, as used in disruption budgets.
+var percentRegexp = regexp.MustCompile(`^[0-9]+%$`)
+
 type appPatchSpec struct {
 	Op    string        `json:"op"`
 	Path  string        `json:"path"`
@@ -150,6 +157,419 @@ func validateSelectedRoles(
 	return valErrors
 }
 
+// validateConfigChoices checks the configChoices array in the config section.
+// Choice IDs must be unique, as must the selection IDs within a choice, and
+// each choice's default must name one of its selections. Each selection may
+// only refer to valid roles and services, and its roleServices may only
+// refer to roles that the selection selects. A role selected by a choice
+// must not also be selected by the top-level config or by any other choice.
+// Any generated error messages will be added to the input list and returned.
+func validateConfigChoices(
+	appCR *kdv1.KubeDirectorApp,
+	allRoleIDs []string,
+	allServiceIDs []string,
+	valErrors []string,
+) []string {
+
+	choiceSeen := make(map[string]bool)
+	roleChoice := make(map[string]string)
+	for _, choice := range appCR.Spec.Config.ConfigChoices {
+		if _, ok := choiceSeen[choice.ID]; ok {
+			valErrors = append(valErrors, nonUniqueChoiceID)
+		}
+		choiceSeen[choice.ID] = true
+
+		var selectionIDs []string
+		for _, selection := range choice.Selections {
+			selectionIDs = append(selectionIDs, selection.ID)
+			if !shared.ListIsUnique(selection.SelectedRoles) {
+				valErrors = append(
+					valErrors,
+					fmt.Sprintf(nonUniqueSelectionRole, selection.ID, choice.ID),
+				)
+			}
+			for _, role := range selection.SelectedRoles {
+				if !shared.StringInList(role, allRoleIDs) {
+					invalidMsg := fmt.Sprintf(
+						invalidSelectionRoleID,
+						role,
+						selection.ID,
+						choice.ID,
+						strings.Join(allRoleIDs, ","),
+					)
+					valErrors = append(valErrors, invalidMsg)
+					continue
+				}
+				// The selections of a single choice are alternatives, so
+				// they may select the same role.
+				if otherChoice, ok := roleChoice[role]; ok && (otherChoice != choice.ID) {
+					valErrors = append(
+						valErrors,
+						fmt.Sprintf(choiceRoleConflict, role, choice.ID),
+					)
+					continue
+				}
+				roleChoice[role] = choice.ID
+				if shared.StringInList(role, appCR.Spec.Config.SelectedRoles) {
+					valErrors = append(
+						valErrors,
+						fmt.Sprintf(choiceRoleConflict, role, choice.ID),
+					)
+				}
+			}
+			roleSeen := make(map[string]bool)
+			for _, nodeRole := range selection.RoleServices {
+				if _, ok := roleSeen[nodeRole.RoleID]; ok {
+					valErrors = append(
+						valErrors,
+						fmt.Sprintf(nonUniqueSelectionService, selection.ID, choice.ID),
+					)
+				}
+				roleSeen[nodeRole.RoleID] = true
+				if !shared.StringInList(nodeRole.RoleID, selection.SelectedRoles) {
+					invalidMsg := fmt.Sprintf(
+						invalidSelectionNodeRoleID,
+						nodeRole.RoleID,
+						selection.ID,
+						choice.ID,
+						strings.Join(selection.SelectedRoles, ","),
+					)
+					valErrors = append(valErrors, invalidMsg)
+				}
+				for _, serviceID := range nodeRole.ServiceIDs {
+					if !shared.StringInList(serviceID, allServiceIDs) {
+						invalidMsg := fmt.Sprintf(
+							invalidSelectionServiceID,
+							serviceID,
+							selection.ID,
+							choice.ID,
+							strings.Join(allServiceIDs, ","),
+						)
+						valErrors = append(valErrors, invalidMsg)
+					}
+				}
+			}
+		}
+		if !shared.ListIsUnique(selectionIDs) {
+			valErrors = append(
+				valErrors,
+				fmt.Sprintf(nonUniqueSelectionID, choice.ID),
+			)
+		}
+		if !shared.StringInList(choice.Default, selectionIDs) {
+			invalidMsg := fmt.Sprintf(
+				invalidChoiceDefault,
+				choice.Default,
+				choice.ID,
+				strings.Join(selectionIDs, ","),
+			)
+			valErrors = append(valErrors, invalidMsg)
+		}
+	}
+
+	for _, nodeRole := range appCR.Spec.Config.RoleServices {
+		if choiceID, ok := roleChoice[nodeRole.RoleID]; ok {
+			valErrors = append(
+				valErrors,
+				fmt.Sprintf(choiceRoleServiceConflict, nodeRole.RoleID, choiceID),
+			)
+		}
+	}
+	return valErrors
+}
+
+// validateRoleDependencies checks the dependsOn array of each role. Each
+// element must be the ID of some other role, and the dependencies must not
+// form a cycle. Any generated error messages will be added to the input list
+// and returned.
+func validateRoleDependencies(
+	appCR *kdv1.KubeDirectorApp,
+	allRoleIDs []string,
+	valErrors []string,
+) []string {
+
+	dependencies := make(map[string][]string)
+	for _, nodeRole := range appCR.Spec.NodeRoles {
+		if !shared.ListIsUnique(nodeRole.DependsOn) {
+			valErrors = append(
+				valErrors,
+				fmt.Sprintf(nonUniqueDependsOn, nodeRole.ID),
+			)
+		}
+		for _, dependency := range nodeRole.DependsOn {
+			if dependency == nodeRole.ID {
+				valErrors = append(
+					valErrors,
+					fmt.Sprintf(selfDependsOn, nodeRole.ID),
+				)
+				continue
+			}
+			if !shared.StringInList(dependency, allRoleIDs) {
+				invalidMsg := fmt.Sprintf(
+					invalidDependsOnRoleID,
+					dependency,
+					nodeRole.ID,
+					strings.Join(allRoleIDs, ","),
+				)
+				valErrors = append(valErrors, invalidMsg)
+				continue
+			}
+			dependencies[nodeRole.ID] = append(dependencies[nodeRole.ID], dependency)
+		}
+	}
+
+	// Depth-first search for a cycle. Roles on the current search path are
+	// marked as visiting; roles whose dependencies are all known to be
+	// acyclic are marked as done.
+	const (
+		visiting = 1
+		done     = 2
+	)
+	marks := make(map[string]int)
+	var searchPath []string
+	var findCycle func(roleID string) []string
+	findCycle = func(roleID string) []string {
+		switch marks[roleID] {
+		case done:
+			return nil
+		case visiting:
+			for i, pathRoleID := range searchPath {
+				if pathRoleID == roleID {
+					return append(append([]string{}, searchPath[i:]...), roleID)
+				}
+			}
+		}
+		marks[roleID] = visiting
+		searchPath = append(searchPath, roleID)
+		for _, dependency := range dependencies[roleID] {
+			if cycle := findCycle(dependency); cycle != nil {
+				return cycle
+			}
+		}
+		searchPath = searchPath[:len(searchPath)-1]
+		marks[roleID] = done
+		return nil
+	}
+	for _, nodeRole := range appCR.Spec.NodeRoles {
+		if cycle := findCycle(nodeRole.ID); cycle != nil {
+			valErrors = append(
+				valErrors,
+				fmt.Sprintf(dependsOnCycle, strings.Join(cycle, " -> ")),
+			)
+			break
+		}
+	}
+	return valErrors
+}
+
+// validateAllowedImages checks that each element of the allowedImages array
+// of each role is a well-formed pattern. Any generated error messages will be
+// added to the input list and returned.
+func validateAllowedImages(
+	appCR *kdv1.KubeDirectorApp,
+	valErrors []string,
+) []string {
+
+	for _, nodeRole := range appCR.Spec.NodeRoles {
+		for _, pattern := range nodeRole.AllowedImages {
+			if _, matchErr := path.Match(pattern, ""); matchErr != nil {
+				invalidMsg := fmt.Sprintf(
+					invalidAllowedImage,
+					pattern,
+					nodeRole.ID,
+				)
+				valErrors = append(valErrors, invalidMsg)
+			}
+		}
+	}
+	return valErrors
+}
+
+// validateSidecars checks the sidecars array of each role. Sidecar names
+// must be unique within the role, and must not be the name of a container
+// that KubeDirector itself creates. Each persisted dir of a sidecar must be
+// (or be inside) one of the role's persisted dirs, since only those are
+// populated on the member's volumes. Any generated error messages will be
+// added to the input list and returned.
+func validateSidecars(
+	appCR *kdv1.KubeDirectorApp,
+	valErrors []string,
+) []string {
+
+	for _, nodeRole := range appCR.Spec.NodeRoles {
+		// The role's dirs are not populated from the app defaults yet if
+		// this is a new app CR.
+		rolePersistDirs := nodeRole.PersistDirs
+		if rolePersistDirs == nil {
+			rolePersistDirs = appCR.Spec.DefaultPersistDirs
+		}
+		var sidecarNames []string
+		for _, sidecar := range nodeRole.Sidecars {
+			sidecarNames = append(sidecarNames, sidecar.Name)
+			if (sidecar.Name == executor.AppContainerName) ||
+				(sidecar.Name == executor.InitContainerName) {
+				valErrors = append(
+					valErrors,
+					fmt.Sprintf(reservedSidecarName, sidecar.Name, nodeRole.ID),
+				)
+			}
+			for _, dir := range sidecar.PersistDirs {
+				persisted := false
+				if rolePersistDirs != nil {
+					for _, roleDir := range *rolePersistDirs {
+						if dirContains(roleDir, dir) {
+							persisted = true
+							break
+						}
+					}
+				}
+				if !persisted {
+					valErrors = append(
+						valErrors,
+						fmt.Sprintf(unpersistedSidecarDir, dir, sidecar.Name, nodeRole.ID),
+					)
+				}
+			}
+		}
+		if !shared.ListIsUnique(sidecarNames) {
+			valErrors = append(
+				valErrors,
+				fmt.Sprintf(nonUniqueSidecarName, nodeRole.ID),
+			)
+		}
+	}
+	return valErrors
+}
+
+// validatePersistVolumes checks the named volumes declared by each role for
+// holding persisted directories. Volume names must be unique within the
+// role, and no two dirs of the role's volumes may overlap. Also no volume
+// may hold /etc, since KubeDirector keeps its own state there on the main
+// volume. Any generated error messages will be added to the input list and
+// returned.
+func validatePersistVolumes(
+	appCR *kdv1.KubeDirectorApp,
+	valErrors []string,
+) []string {
+
+	type volumeDir struct {
+		volume string
+		dir    string
+	}
+	for _, nodeRole := range appCR.Spec.NodeRoles {
+		var volumeNames []string
+		var volumeDirs []volumeDir
+		for _, volume := range nodeRole.PersistVolumes {
+			volumeNames = append(volumeNames, volume.Name)
+			for _, dir := range volume.PersistDirs {
+				if dirContains(dir, "/etc") {
+					valErrors = append(
+						valErrors,
+						fmt.Sprintf(reservedPersistVolumeDir, dir, volume.Name, nodeRole.ID),
+					)
+				}
+				volumeDirs = append(volumeDirs, volumeDir{volume.Name, dir})
+			}
+		}
+		if !shared.ListIsUnique(volumeNames) {
+			valErrors = append(
+				valErrors,
+				fmt.Sprintf(nonUniquePersistVolumeName, nodeRole.ID),
+			)
+		}
+		for i, first := range volumeDirs {
+			for _, second := range volumeDirs[i+1:] {
+				if dirContains(first.dir, second.dir) || dirContains(second.dir, first.dir) {
+					valErrors = append(
+						valErrors,
+						fmt.Sprintf(
+							overlappingPersistVolumeDirs,
+							first.dir,
+							first.volume,
+							nodeRole.ID,
+							second.dir,
+							second.volume,
+						),
+					)
+				}
+			}
+		}
+	}
+	return valErrors
+}
+
+// dirContains returns true if dir is the same as, or an ancestor of, the
+// other dir.
+func dirContains(
+	dir string,
+	otherDir string,
+) bool {
+
+	dir = path.Clean("/" + dir)
+	otherDir = path.Clean("/" + otherDir)
+	return (dir == otherDir) || (dir == "/") || strings.HasPrefix(otherDir, dir+"/")
+}
+
+// validateSecurityContexts checks the security context of each role, and
+// whether the app can run in restricted mode if KubeDirector is in that
+// mode. Any generated error messages will be added to the input list and
+// returned.
+func validateSecurityContexts(
+	appCR *kdv1.KubeDirectorApp,
+	valErrors []string,
+) []string {
+
+	if shared.GetRestrictedMode() {
+		return validateRestrictedApp(appCR, valErrors)
+	}
+	for _, nodeRole := range appCR.Spec.NodeRoles {
+		valErrors = validateSecurityContext(nodeRole.SecurityContext, nodeRole.ID, valErrors)
+	}
+	return valErrors
+}
+
+// validateDisruptionBudgets checks that each role's disruption budget sets
+// exactly one kind of limit, and that any minAvailable or maxUnavailable
+// value is a non-negative integer or a percentage. Any generated error
+// messages will be added to the input list and returned.
+func validateDisruptionBudgets(
+	appCR *kdv1.KubeDirectorApp,
+	valErrors []string,
+) []string {
+
+	for _, nodeRole := range appCR.Spec.NodeRoles {
+		budget := nodeRole.DisruptionBudget
+		if budget == nil {
+			continue
+		}
+		numSet := 0
+		if budget.Quorum {
+			numSet++
+		}
+		names := []string{"minAvailable", "maxUnavailable"}
+		for i, value := range []*intstr.IntOrString{budget.MinAvailable, budget.MaxUnavailable} {
+			if value == nil {
+				continue
+			}
+			numSet++
+			if ((value.Type == intstr.Int) && (value.IntVal < 0)) ||
+				((value.Type == intstr.String) && !percentRegexp.MatchString(value.StrVal)) {
+				valErrors = append(
+					valErrors,
+					fmt.Sprintf(invalidDisruptionBudgetValue, names[i], value.String(), nodeRole.ID),
+				)
+			}
+		}
+		if numSet != 1 {
+			valErrors = append(
+				valErrors,
+				fmt.Sprintf(invalidDisruptionBudget, nodeRole.ID),
+			)
+		}
+	}
+	return valErrors
+}
+
 // validateRoles checks each role for property constraints not expressible
 // in the schema. If any overrideable properties are unspecified, the corresponding
 // global values are used. This will add an PATCH spec for mutation the app CR.
@@ -380,15 +800,43 @@ func validateRoles(
 }
 
 // validateServices checks each service for property constraints not
-// expressible in the schema. Currently this just means checking that the
-// service endpoint must specify url_schema if isDashboard is true. Any
-// generated error messages will be added to the input list and returned.
+// expressible in the schema. The service endpoint must specify url_schema if
+// isDashboard is true (which dashboardOnly exposure also requires), it must
+// have a port if it asks for a role service or declares metrics, and any
+// probes must be usable with the endpoint. Also no role may have more than
+// one service that declares each kind of probe, since a container only has
+// one of each. Any generated error messages will be added to the input list
+// and returned.
 func validateServices(
 	appCR *kdv1.KubeDirectorApp,
 	valErrors []string,
 ) []string {
 
-	for _, service := range appCR.Spec.Services {
+	checkProbe := func(
+		service *kdv1.Service,
+		probe *kdv1.ServiceProbe,
+		probeName string,
+	) {
+
+		if probe == nil {
+			return
+		}
+		if (probe.Type != kdv1.ServiceProbeExec) && (service.Endpoint.Port == nil) {
+			valErrors = append(
+				valErrors,
+				fmt.Sprintf(probeWithoutPort, probeName, service.ID),
+			)
+		}
+		if (probe.Type == kdv1.ServiceProbeExec) && (len(probe.Command) == 0) {
+			valErrors = append(
+				valErrors,
+				fmt.Sprintf(probeWithoutCommand, probeName, service.ID),
+			)
+		}
+	}
+
+	for i := range appCR.Spec.Services {
+		service := &(appCR.Spec.Services[i])
 		if service.Endpoint.IsDashboard {
 			if service.Endpoint.URLScheme == "" {
 				invalidMsg := fmt.Sprintf(
@@ -397,7 +845,92 @@ func validateServices(
 				)
 				valErrors = append(valErrors, invalidMsg)
 			}
+		} else if service.Endpoint.Exposure == kdv1.ServiceExposureDashboardOnly {
+			invalidMsg := fmt.Sprintf(
+				dashboardOnlyNotDashboard,
+				service.ID,
+			)
+			valErrors = append(valErrors, invalidMsg)
+		}
+		if service.Endpoint.HasRoleService && (service.Endpoint.Port == nil) {
+			valErrors = append(
+				valErrors,
+				fmt.Sprintf(roleServiceWithoutPort, service.ID),
+			)
 		}
+		if (service.Endpoint.Metrics != nil) && (service.Endpoint.Port == nil) {
+			valErrors = append(
+				valErrors,
+				fmt.Sprintf(metricsWithoutPort, service.ID),
+			)
+		}
+		checkProbe(service, service.Endpoint.ReadinessProbe, "readinessProbe")
+		checkProbe(service, service.Endpoint.LivenessProbe, "livenessProbe")
+	}
+
+	// Each roleServices list (in the top-level config or in a config
+	// choice selection) has the complete set of services for its roles.
+	roleServicesLists := [][]kdv1.RoleService{appCR.Spec.Config.RoleServices}
+	for _, choice := range appCR.Spec.Config.ConfigChoices {
+		for _, selection := range choice.Selections {
+			roleServicesLists = append(roleServicesLists, selection.RoleServices)
+		}
+	}
+	for _, roleServices := range roleServicesLists {
+		for _, roleService := range roleServices {
+			var readinessServices []string
+			var livenessServices []string
+			for _, serviceID := range roleService.ServiceIDs {
+				service := catalog.GetServiceFromID(appCR, serviceID)
+				if service == nil {
+					continue
+				}
+				if service.Endpoint.ReadinessProbe != nil {
+					readinessServices = append(readinessServices, serviceID)
+				}
+				if service.Endpoint.LivenessProbe != nil {
+					livenessServices = append(livenessServices, serviceID)
+				}
+			}
+			if len(readinessServices) > 1 {
+				invalidMsg := fmt.Sprintf(
+					multipleRoleProbes,
+					roleService.RoleID,
+					"readinessProbe",
+					strings.Join(readinessServices, ","),
+				)
+				valErrors = append(valErrors, invalidMsg)
+			}
+			if len(livenessServices) > 1 {
+				invalidMsg := fmt.Sprintf(
+					multipleRoleProbes,
+					roleService.RoleID,
+					"livenessProbe",
+					strings.Join(livenessServices, ","),
+				)
+				valErrors = append(valErrors, invalidMsg)
+			}
+		}
+	}
+	return valErrors
+}
+
+// validateUpgradableFrom checks that the upgradableFrom list has unique
+// elements and does not reference the app itself. Any generated error
+// messages will be added to the input list and returned.
+func validateUpgradableFrom(
+	appCR *kdv1.KubeDirectorApp,
+	valErrors []string,
+) []string {
+
+	if !shared.ListIsUnique(appCR.Spec.UpgradableFrom) {
+		valErrors = append(valErrors, nonUniqueUpgradableFrom)
+	}
+	if shared.StringInList(appCR.Name, appCR.Spec.UpgradableFrom) {
+		valErrors = append(
+			valErrors,
+			fmt.Sprintf(selfUpgradableFrom, appCR.Name),
+		)
 	}
 	return valErrors
 }
@@ -460,8 +993,16 @@ func admitAppCR(
 	valErrors = validateRefUniqueness(&appCR, valErrors)
 	valErrors = validateServiceRoles(&appCR, allRoleIDs, allServiceIDs, valErrors)
 	valErrors = validateSelectedRoles(&appCR, allRoleIDs, valErrors)
+	valErrors = validateConfigChoices(&appCR, allRoleIDs, allServiceIDs, valErrors)
+	valErrors = validateRoleDependencies(&appCR, allRoleIDs, valErrors)
+	valErrors = validateAllowedImages(&appCR, valErrors)
+	valErrors = validateSidecars(&appCR, valErrors)
+	valErrors = validatePersistVolumes(&appCR, valErrors)
+	valErrors = validateSecurityContexts(&appCR, valErrors)
+	valErrors = validateDisruptionBudgets(&appCR, valErrors)
 	patches, valErrors = validateRoles(&appCR, patches, valErrors)
 	valErrors = validateServices(&appCR, valErrors)
+	valErrors = validateUpgradableFrom(&appCR, valErrors)
 
 	if len(valErrors) == 0 {
 		if len(patches) != 0 {
@@ -501,6 +1042,9 @@ func admitAppCR(
 			// to null. See the commit comments in the PR that closes issue
 			// #319 for more details.
 			prevAppCR.Spec.DefaultSetupPackage = appCR.Spec.DefaultSetupPackage
+			// The upgradableFrom list only matters when a kdcluster changes
+			// to this app, so it is fine to edit it while the app is in use.
+			prevAppCR.Spec.UpgradableFrom = appCR.Spec.UpgradableFrom
 			if !equality.Semantic.DeepEqual(appCR.Spec, prevAppCR.Spec) {
 				referencesStr := strings.Join(references, ", ")
 				appInUseMsg := fmt.Sprintf(
@@ -41,8 +41,6 @@ import (
 
 type secretValidateResult int
 
-const maxKDMembers = 1000
-
 const (
 	secretIsValid secretValidateResult = iota
 	secretPrefixNotMatched
@@ -64,6 +62,7 @@ type clusterPatchValue struct {
 	ValueKDSecret      *kdv1.KDSecret
 	ValueSecretKey     *kdv1.SecretKey
 	ValueDict          *dictValue
+	ValueRoles         *[]kdv1.Role
 }
 
 func (obj clusterPatchValue) MarshalJSON() ([]byte, error) {
@@ -83,6 +82,9 @@ func (obj clusterPatchValue) MarshalJSON() ([]byte, error) {
 	if obj.ValueDict != nil {
 		return json.Marshal(obj.ValueDict)
 	}
+	if obj.ValueRoles != nil {
+		return json.Marshal(obj.ValueRoles)
+	}
 	return json.Marshal(obj.ValueStr)
 }
 
@@ -213,13 +215,13 @@ func validateCardinality(
 		}
 
 		totalMembers += *role.Members
-		if totalMembers > maxKDMembers {
+		if totalMembers > shared.MaxKDMembers {
 			anyError = true
 			valErrors = append(
 				valErrors,
-				fmt.Sprint(
+				fmt.Sprintf(
 					maxMemberLimit,
-					maxKDMembers,
+					shared.MaxKDMembers,
 				),
 			)
 			break
@@ -281,7 +283,7 @@ func validateClusterRoles(
 	if !uniqueRoles {
 		valErrors = append(valErrors, nonUniqueRoleID)
 	}
-	for _, activeRole := range catalog.GetSelectedRoleIDs(appCR) {
+	for _, activeRole := range catalog.GetSelectedRoleIDs(cr, appCR) {
 		if !shared.StringInList(activeRole, configuredRoles) {
 			role := catalog.GetRoleFromID(appCR, activeRole)
 			// If our app CR validation is on point this should never be nil,
@@ -302,22 +304,164 @@ func validateClusterRoles(
 	return valErrors
 }
 
+// validateRoleImages checks that any image override in a role is allowed by
+// the allowedImages patterns of the corresponding app role. Any generated
+// error messages will be added to the input list and returned.
+func validateRoleImages(
+	cr *kdv1.KubeDirectorCluster,
+	appCR *kdv1.KubeDirectorApp,
+	valErrors []string,
+) []string {
+
+	for _, role := range cr.Spec.Roles {
+		if role.ImageRepoTag == nil {
+			continue
+		}
+		nodeRole := catalog.GetRoleFromID(appCR, role.Name)
+		if nodeRole == nil {
+			// Invalid role IDs are reported by validateClusterRoles.
+			continue
+		}
+		if !catalog.ImageAllowedForRole(nodeRole, *role.ImageRepoTag) {
+			notAllowedMsg := fmt.Sprintf(
+				imageNotAllowed,
+				*role.ImageRepoTag,
+				role.Name,
+				appCR.Name,
+				strings.Join(nodeRole.AllowedImages, ","),
+			)
+			valErrors = append(valErrors, notAllowedMsg)
+		}
+	}
+	return valErrors
+}
+
+// validateRoleSecurity checks the security context of each role. If the
+// cluster is being created or moved to a different app, it also checks that
+// the app can run while KubeDirector is in restricted mode; existing
+// clusters are not blocked from other changes if restricted mode is turned
+// on later. Any generated error messages will be added to the input list and
+// returned.
+func validateRoleSecurity(
+	cr *kdv1.KubeDirectorCluster,
+	prevCr *kdv1.KubeDirectorCluster,
+	appCR *kdv1.KubeDirectorApp,
+	operation av1beta1.Operation,
+	valErrors []string,
+) []string {
+
+	for _, role := range cr.Spec.Roles {
+		valErrors = validateSecurityContext(role.SecurityContext, role.Name, valErrors)
+	}
+	if (operation == av1beta1.Create) || (cr.Spec.AppID != prevCr.Spec.AppID) {
+		valErrors = validateRestrictedApp(appCR, valErrors)
+	}
+	return valErrors
+}
+
+// validateClusterConfigChoices checks that the configChoices property only
+// names config choices declared by the app, and only selections declared by
+// those choices. Any app choice that is not specified will get its default
+// selection, and a patch is generated to record that. Any generated error
+// messages will be added to the input list and returned.
+func validateClusterConfigChoices(
+	cr *kdv1.KubeDirectorCluster,
+	appCR *kdv1.KubeDirectorApp,
+	valErrors []string,
+	patches []clusterPatchSpec,
+) ([]string, []clusterPatchSpec) {
+
+	var choiceIDs []string
+	for _, choice := range appCR.Spec.Config.ConfigChoices {
+		choiceIDs = append(choiceIDs, choice.ID)
+	}
+	for choiceID, selectionID := range cr.Spec.ConfigChoices {
+		choice := catalog.GetConfigChoiceFromID(appCR, choiceID)
+		if choice == nil {
+			invalidMsg := fmt.Sprintf(
+				invalidClusterChoice,
+				choiceID,
+				strings.Join(choiceIDs, ","),
+			)
+			valErrors = append(valErrors, invalidMsg)
+			continue
+		}
+		var selectionIDs []string
+		for _, selection := range choice.Selections {
+			selectionIDs = append(selectionIDs, selection.ID)
+		}
+		if !shared.StringInList(selectionID, selectionIDs) {
+			invalidMsg := fmt.Sprintf(
+				invalidClusterSelection,
+				selectionID,
+				choiceID,
+				strings.Join(selectionIDs, ","),
+			)
+			valErrors = append(valErrors, invalidMsg)
+		}
+	}
+
+	if len(appCR.Spec.Config.ConfigChoices) == 0 {
+		return valErrors, patches
+	}
+	if cr.Spec.ConfigChoices == nil {
+		choices := make(dictValue)
+		for _, choice := range appCR.Spec.Config.ConfigChoices {
+			choices[choice.ID] = choice.Default
+		}
+		cr.Spec.ConfigChoices = choices
+		patches = append(
+			patches,
+			clusterPatchSpec{
+				Op:   "add",
+				Path: "/spec/configChoices",
+				Value: clusterPatchValue{
+					ValueDict: &choices,
+				},
+			},
+		)
+		return valErrors, patches
+	}
+	for _, choice := range appCR.Spec.Config.ConfigChoices {
+		if _, ok := cr.Spec.ConfigChoices[choice.ID]; ok {
+			continue
+		}
+		defaultSelection := choice.Default
+		cr.Spec.ConfigChoices[choice.ID] = defaultSelection
+		choicePath := strings.ReplaceAll(
+			strings.ReplaceAll(choice.ID, "~", "~0"),
+			"/",
+			"~1",
+		)
+		patches = append(
+			patches,
+			clusterPatchSpec{
+				Op:   "add",
+				Path: "/spec/configChoices/" + choicePath,
+				Value: clusterPatchValue{
+					ValueStr: &defaultSelection,
+				},
+			},
+		)
+	}
+	return valErrors, patches
+}
+
 // validateGeneralClusterChanges checks for modifications to any property that
 // is not ever allowed to change after initial deployment. Currently this
-// covers the top-level app and appCatalog. Any generated error messages will
-// be added to the input list and returned.
+// covers the top-level appCatalog, and the configChoices unless the app is
+// also changing. The top-level app may only change along a declared upgrade
+// path; see validateAppUpgrade. Any generated error messages will be added to
+// the input list and returned.
 func validateGeneralClusterChanges(
 	cr *kdv1.KubeDirectorCluster,
 	prevCr *kdv1.KubeDirectorCluster,
+	appCR *kdv1.KubeDirectorApp,
 	valErrors []string,
 ) []string {
 
 	if cr.Spec.AppID != prevCr.Spec.AppID {
-		appModifiedMsg := fmt.Sprintf(
-			modifiedProperty,
-			"app",
-		)
-		valErrors = append(valErrors, appModifiedMsg)
+		valErrors = validateAppUpgrade(cr, prevCr, appCR, valErrors)
 	}
 	// appCatalog should not be nil at this point in the flow if everything
 	// has worked as expected, but it doesn't hurt to be robust against that.
@@ -338,15 +482,120 @@ func validateGeneralClusterChanges(
 		)
 		valErrors = append(valErrors, appCatalogModifiedMsg)
 	}
+	if !equality.Semantic.DeepEqual(cr.Spec.CloneFrom, prevCr.Spec.CloneFrom) {
+		cloneFromModifiedMsg := fmt.Sprintf(
+			modifiedProperty,
+			"cloneFrom",
+		)
+		valErrors = append(valErrors, cloneFromModifiedMsg)
+	}
+	// The config choices determine which roles are configured together, so
+	// they can only change along with the app (where new choices may need
+	// their defaults populated).
+	if cr.Spec.AppID == prevCr.Spec.AppID {
+		if !equality.Semantic.DeepEqual(cr.Spec.ConfigChoices, prevCr.Spec.ConfigChoices) {
+			configChoicesModifiedMsg := fmt.Sprintf(
+				modifiedProperty,
+				"configChoices",
+			)
+			valErrors = append(valErrors, configChoicesModifiedMsg)
+		}
+	}
 
 	return valErrors
 }
 
-// validateRoleChanges checks for modifications to role properties. The
-// members property of a role can always be changed (within cardinality
-// constraints that are checked elsewhere). However other properties cannot
-// be changed unless the role currently has no members. Any generated error
-// messages will be added to the input list and returned.
+// validateAppUpgrade checks a change of the top-level app. If no upgrade is
+// currently in progress, the new app must list the current app in its
+// upgradableFrom property, and any role with persistent storage must persist
+// the same directories in both apps. If an upgrade is in progress, the only
+// allowed change is back to the app being upgraded from (a rollback). Any
+// generated error messages will be added to the input list and returned.
+func validateAppUpgrade(
+	cr *kdv1.KubeDirectorCluster,
+	prevCr *kdv1.KubeDirectorCluster,
+	appCR *kdv1.KubeDirectorApp,
+	valErrors []string,
+) []string {
+
+	if (prevCr.Status != nil) && (prevCr.Status.UpgradeProgress != nil) {
+		upgrade := prevCr.Status.UpgradeProgress
+		if cr.Spec.AppID != upgrade.PrevApp {
+			inProgressMsg := fmt.Sprintf(
+				appUpgradeInProgress,
+				upgrade.PrevApp,
+				upgrade.TargetApp,
+				upgrade.PrevApp,
+			)
+			valErrors = append(valErrors, inProgressMsg)
+		}
+		return valErrors
+	}
+
+	if !shared.StringInList(prevCr.Spec.AppID, appCR.Spec.UpgradableFrom) {
+		invalidUpgradeMsg := fmt.Sprintf(
+			invalidAppUpgrade,
+			cr.Spec.AppID,
+			prevCr.Spec.AppID,
+		)
+		valErrors = append(valErrors, invalidUpgradeMsg)
+		return valErrors
+	}
+
+	// Existing PVCs were populated from the directories persisted by the
+	// previous app. A different set of directories can't be reconciled
+	// without re-initializing member storage, so reject that.
+	prevAppCR, prevAppErr := catalog.FindApp(prevCr)
+	if prevAppErr != nil {
+		valErrors = append(
+			valErrors,
+			fmt.Sprintf(invalidAppMessage, prevCr.Spec.AppID),
+		)
+		return valErrors
+	}
+	setupLayout := func(nodeRole *kdv1.NodeRole) string {
+		if nodeRole.SetupPackage.IsNull {
+			return "none"
+		}
+		if nodeRole.SetupPackage.Info.UseNewSetupLayout {
+			return "new"
+		}
+		return "legacy"
+	}
+	for _, role := range cr.Spec.Roles {
+		if role.Storage == nil {
+			continue
+		}
+		prevNodeRole := catalog.GetRoleFromID(prevAppCR, role.Name)
+		nodeRole := catalog.GetRoleFromID(appCR, role.Name)
+		if (prevNodeRole == nil) || (nodeRole == nil) {
+			// Unknown roles are reported by validateClusterRoles.
+			continue
+		}
+		if (setupLayout(prevNodeRole) != setupLayout(nodeRole)) ||
+			!equality.Semantic.DeepEqual(prevNodeRole.PersistDirs, nodeRole.PersistDirs) ||
+			!equality.Semantic.DeepEqual(prevNodeRole.PersistVolumes, nodeRole.PersistVolumes) {
+			valErrors = append(
+				valErrors,
+				fmt.Sprintf(
+					incompatiblePersistDirs,
+					role.Name,
+					cr.Spec.AppID,
+					prevCr.Spec.AppID,
+				),
+			)
+		}
+	}
+	return valErrors
+}
+
+// validateRoleChanges checks for modifications to role properties. Most
+// properties of a role can be changed at any time; existing members will be
+// restarted as necessary to pick up the changes. However some properties
+// cannot be changed unless the role currently has no members, because they
+// are baked into immutable parts of the role's statefulset (the pod selector
+// and the volume claim templates) or into the per-member services. Any
+// generated error messages will be added to the input list and returned.
 func validateRoleChanges(
 	cr *kdv1.KubeDirectorCluster,
 	prevCr *kdv1.KubeDirectorCluster,
@@ -372,10 +621,8 @@ func validateRoleChanges(
 		// members; i.e. no populated role status at all. Note that this is
 		// different from just checking the "members" count of prevRole;
 		// "members" is just the desired value which may not yet be reconciled.
-		// Restricting change to when all role members are gone is good because:
-		// - We won't show misleading configuration for existing members.
-		// - We aren't required to do any explicit statefulset reconfig. The
-		//   previous role's statefulset will have been deleted.
+		// If there are no members, the previous role's statefulset will have
+		// been deleted and a new one will be created from the new spec.
 		if _, ok := prevRoleHasStatus[role.Name]; !ok {
 			continue
 		}
@@ -391,15 +638,132 @@ func validateRoleChanges(
 			continue
 		}
 		// There is status (i.e. current members) and a current spec. Reject
-		// the new spec if anything other than the members count is different.
-		compareRole := *role
-		compareRole.Members = prevRole.Members
-		if !equality.Semantic.DeepEqual(&compareRole, prevRole) {
-			roleModifiedMsg := fmt.Sprintf(
-				modifiedRole,
-				role.Name,
+		// the new spec if it changes anything that can't be applied to the
+		// existing statefulset and members.
+		valErrors = validateRoleStorageChange(role, prevRole, valErrors)
+		if !equality.Semantic.DeepEqual(role.BlockStorage, prevRole.BlockStorage) {
+			valErrors = append(
+				valErrors,
+				fmt.Sprintf(modifiedRoleProperty, "blockStorage", role.Name),
+			)
+		}
+		if !equality.Semantic.DeepEqual(role.ServiceLabels, prevRole.ServiceLabels) {
+			valErrors = append(
+				valErrors,
+				fmt.Sprintf(modifiedRoleProperty, "serviceLabels", role.Name),
+			)
+		}
+		if !equality.Semantic.DeepEqual(role.ServiceAnnotations, prevRole.ServiceAnnotations) {
+			valErrors = append(
+				valErrors,
+				fmt.Sprintf(modifiedRoleProperty, "serviceAnnotations", role.Name),
+			)
+		}
+		// Pod labels are part of the statefulset's pod selector, so new
+		// labels can be added but existing ones must stay as they are.
+		for name, prevValue := range prevRole.PodLabels {
+			if value, ok := role.PodLabels[name]; !ok || (value != prevValue) {
+				valErrors = append(
+					valErrors,
+					fmt.Sprintf(modifiedPodLabel, name, role.Name),
+				)
+			}
+		}
+	}
+	return valErrors
+}
+
+// validateRoleStorageChange checks whether a change to the storage property
+// of a role with existing members can be applied. The only change allowed is
+// growing the main volume or named volumes, and only if their storage class
+// allows volume expansion; KubeDirector then expands the existing member PVCs
+// and replaces the role's statefulset. A smaller size gets its own more
+// specific error message. Any generated error messages will be added to the
+// input list and returned.
+func validateRoleStorageChange(
+	role *kdv1.Role,
+	prevRole *kdv1.Role,
+	valErrors []string,
+) []string {
+
+	if equality.Semantic.DeepEqual(role.Storage, prevRole.Storage) {
+		return valErrors
+	}
+	if (role.Storage == nil) || (prevRole.Storage == nil) ||
+		!equality.Semantic.DeepEqual(role.Storage.StorageClass, prevRole.Storage.StorageClass) ||
+		(len(role.Storage.Volumes) != len(prevRole.Storage.Volumes)) {
+		return append(
+			valErrors,
+			fmt.Sprintf(modifiedRoleProperty, "storage", role.Name),
+		)
+	}
+
+	// Pair up each volume (main volume first) with its previous definition,
+	// collecting the storage classes of the ones being grown.
+	type sizeChange struct {
+		size         string
+		prevSize     string
+		storageClass *string
+	}
+	changes := []sizeChange{
+		{
+			size:         role.Storage.Size,
+			prevSize:     prevRole.Storage.Size,
+			storageClass: role.Storage.StorageClass,
+		},
+	}
+	for i, volume := range role.Storage.Volumes {
+		prevVolume := prevRole.Storage.Volumes[i]
+		if (volume.Name != prevVolume.Name) ||
+			!equality.Semantic.DeepEqual(volume.StorageClass, prevVolume.StorageClass) {
+			return append(
+				valErrors,
+				fmt.Sprintf(modifiedRoleProperty, "storage", role.Name),
+			)
+		}
+		changes = append(
+			changes,
+			sizeChange{
+				size:         volume.Size,
+				prevSize:     prevVolume.Size,
+				storageClass: volume.StorageClass,
+			},
+		)
+	}
+	var grownClasses []string
+	for _, change := range changes {
+		size, sizeErr := resource.ParseQuantity(change.size)
+		prevSize, prevSizeErr := resource.ParseQuantity(change.prevSize)
+		if (sizeErr != nil) || (prevSizeErr != nil) {
+			return append(
+				valErrors,
+				fmt.Sprintf(modifiedRoleProperty, "storage", role.Name),
+			)
+		}
+		switch size.Cmp(prevSize) {
+		case -1:
+			return append(
+				valErrors,
+				fmt.Sprintf(storageShrink, role.Name),
+			)
+		case 1:
+			// Same size expressed differently is not a change, but a
+			// larger size must be checked against the storage class.
+			if (change.storageClass != nil) &&
+				!shared.StringInList(*change.storageClass, grownClasses) {
+				grownClasses = append(grownClasses, *change.storageClass)
+			}
+		}
+	}
+	for _, storageClassName := range grownClasses {
+		storageClass, scErr := observer.GetStorageClass(storageClassName)
+		if (scErr != nil) ||
+			(storageClass.AllowVolumeExpansion == nil) ||
+			!*(storageClass.AllowVolumeExpansion) {
+			valErrors = append(
+				valErrors,
+				fmt.Sprintf(storageNotExpandable, role.Name, storageClassName),
 			)
-			valErrors = append(valErrors, roleModifiedMsg)
 		}
 	}
 	return valErrors
@@ -517,6 +881,110 @@ func validateRoleStorageClass(
 	return valErrors, patches
 }
 
+// validateRoleVolumes checks the named volumes in the storage of each role.
+// Each must correspond to one of the persistVolumes declared for the role by
+// the app, and have a valid size. A volume with no storageClassName uses the
+// (possibly defaulted) class of the role's main volume, so this must run
+// after validateRoleStorageClass.
+func validateRoleVolumes(
+	cr *kdv1.KubeDirectorCluster,
+	appCR *kdv1.KubeDirectorApp,
+	valErrors []string,
+	patches []clusterPatchSpec,
+) ([]string, []clusterPatchSpec) {
+
+	numRoles := len(cr.Spec.Roles)
+	for i := 0; i < numRoles; i++ {
+		role := &(cr.Spec.Roles[i])
+		if (role.Storage == nil) || (len(role.Storage.Volumes) == 0) {
+			continue
+		}
+		var appVolumeNames []string
+		nodeRole := catalog.GetRoleFromID(appCR, role.Name)
+		if nodeRole != nil {
+			for _, appVolume := range nodeRole.PersistVolumes {
+				appVolumeNames = append(appVolumeNames, appVolume.Name)
+			}
+		}
+		var volumeNames []string
+		numVolumes := len(role.Storage.Volumes)
+		for j := 0; j < numVolumes; j++ {
+			volume := &(role.Storage.Volumes[j])
+			volumeNames = append(volumeNames, volume.Name)
+			if !shared.StringInList(volume.Name, appVolumeNames) {
+				valErrors = append(
+					valErrors,
+					fmt.Sprintf(
+						invalidStorageVolume,
+						volume.Name,
+						role.Name,
+						cr.Spec.AppID,
+					),
+				)
+			}
+			volSize, sizeErr := resource.ParseQuantity(volume.Size)
+			if sizeErr != nil {
+				valErrors = append(
+					valErrors,
+					fmt.Sprintf(
+						invalidStorageVolumeDef,
+						volume.Name,
+						role.Name,
+					),
+				)
+			} else if volSize.Sign() != 1 {
+				valErrors = append(
+					valErrors,
+					fmt.Sprintf(
+						invalidStorageVolumeSize,
+						volume.Name,
+						role.Name,
+					),
+				)
+			}
+			if volume.StorageClass != nil {
+				_, scErr := observer.GetStorageClass(*volume.StorageClass)
+				if scErr != nil {
+					valErrors = append(
+						valErrors,
+						fmt.Sprintf(
+							invalidVolumeStorageClass,
+							*volume.StorageClass,
+							volume.Name,
+							role.Name,
+						),
+					)
+				}
+				continue
+			}
+			if role.Storage.StorageClass == nil {
+				// Already reported by validateRoleStorageClass.
+				continue
+			}
+			volume.StorageClass = role.Storage.StorageClass
+			patches = append(
+				patches,
+				clusterPatchSpec{
+					Op: "add",
+					Path: "/spec/roles/" + strconv.Itoa(i) + "/storage/volumes/" +
+						strconv.Itoa(j) + "/storageClassName",
+					Value: clusterPatchValue{
+						ValueStr: volume.StorageClass,
+					},
+				},
+			)
+		}
+		if !shared.ListIsUnique(volumeNames) {
+			valErrors = append(
+				valErrors,
+				fmt.Sprintf(nonUniqueStorageVolumeName, role.Name),
+			)
+		}
+	}
+
+	return valErrors, patches
+}
+
 // validateRoleSharedMemory checks for valid quantity syntax. Also the K8s
 // version must be >= 1.22.
 func validateRoleSharedMemory(
@@ -659,8 +1127,99 @@ func validateApp(
 	return appCR, patches, ""
 }
 
+// validateCloneSource checks that the source cluster of a new clone exists
+// in the same namespace and uses the same app. If the clone does not specify
+// any roles, the roles of the source cluster are copied, and likewise for
+// config choices. Copied secret keys are decrypted so that they will be
+// re-encrypted for the clone. Any generated error messages will be added to
+// the input list and returned, along with any generated patches.
+func validateCloneSource(
+	cr *kdv1.KubeDirectorCluster,
+	valErrors []string,
+	patches []clusterPatchSpec,
+) ([]string, []clusterPatchSpec) {
+
+	if cr.Spec.CloneFrom == nil {
+		return valErrors, patches
+	}
+	sourceName := cr.Spec.CloneFrom.Cluster
+	source, sourceErr := observer.GetCluster(cr.Namespace, sourceName)
+	if sourceErr != nil {
+		valErrors = append(
+			valErrors,
+			fmt.Sprintf(invalidCloneSource, sourceName, sourceErr.Error()),
+		)
+		return valErrors, patches
+	}
+	if source.Spec.AppID != cr.Spec.AppID {
+		valErrors = append(
+			valErrors,
+			fmt.Sprintf(cloneAppMismatch, sourceName, source.Spec.AppID, cr.Spec.AppID),
+		)
+		return valErrors, patches
+	}
+
+	if len(cr.Spec.Roles) == 0 {
+		roles := make([]kdv1.Role, len(source.Spec.Roles))
+		for i := range source.Spec.Roles {
+			source.Spec.Roles[i].DeepCopyInto(&roles[i])
+			for j := range roles[i].SecretKeys {
+				secretKey := &roles[i].SecretKeys[j]
+				if secretKey.EncryptedValue == "" {
+					continue
+				}
+				value, decryptErr := secretkeys.Decrypt(secretKey.EncryptedValue)
+				if decryptErr != nil {
+					valErrors = append(
+						valErrors,
+						fmt.Sprintf(cloneSecretKeyError, secretKey.Name, roles[i].Name, sourceName),
+					)
+					continue
+				}
+				secretKey.Value = value
+				secretKey.EncryptedValue = ""
+			}
+		}
+		cr.Spec.Roles = roles
+		patches = append(
+			patches,
+			clusterPatchSpec{
+				Op:   "add",
+				Path: "/spec/roles",
+				Value: clusterPatchValue{
+					ValueRoles: &roles,
+				},
+			},
+		)
+	}
+
+	if (cr.Spec.ConfigChoices == nil) && (source.Spec.ConfigChoices != nil) {
+		choices := make(dictValue)
+		for id, selection := range source.Spec.ConfigChoices {
+			choices[id] = selection
+		}
+		cr.Spec.ConfigChoices = choices
+		patches = append(
+			patches,
+			clusterPatchSpec{
+				Op:   "add",
+				Path: "/spec/configChoices",
+				Value: clusterPatchValue{
+					ValueDict: &choices,
+				},
+			},
+		)
+	}
+
+	return valErrors, patches
+}
+
 // validateMinResources function checks to see if all specified minimum
-// resource requirements for each role are being met
+// resource requirements for each role are being met. Only the requests of
+// the app container count toward the minimums, since sidecars can't make up
+// for a short app container. The requests of the sidecars declared by the
+// app role are checked separately: each must fit within the sidecar's own
+// limits, or the role's members could never be scheduled.
 func validateMinResources(
 	cr *kdv1.KubeDirectorCluster,
 	appCR *kdv1.KubeDirectorApp,
@@ -676,6 +1235,25 @@ func validateMinResources(
 			continue
 		}
 
+		for _, sidecar := range appRole.Sidecars {
+			for resKey, request := range sidecar.Resources.Requests {
+				limit, ok := sidecar.Resources.Limits[resKey]
+				if ok && (request.Cmp(limit) > 0) {
+					valErrors = append(
+						valErrors,
+						fmt.Sprintf(
+							invalidSidecarResource,
+							resKey.String(),
+							request.String(),
+							sidecar.Name,
+							role.Name,
+							limit.String(),
+						),
+					)
+				}
+			}
+		}
+
 		minResources := catalog.GetRoleMinResources(appRole)
 		if minResources == nil {
 			// No minimum requirements for this role.
@@ -1134,6 +1712,7 @@ func validateVolumeProjections(
 			executor.PvcNamePrefix,
 			nativeSystemdSupport,
 			[]string{},
+			nil,
 		)
 
 		if err != nil {
@@ -1424,6 +2003,12 @@ func admitClusterCR(
 		return &admitResponse
 	}
 
+	// If this is a new clone, check the source cluster and copy its roles
+	// and config choices if they are not given.
+	if ar.Request.Operation == av1beta1.Create {
+		valErrors, patches = validateCloneSource(&clusterCR, valErrors, patches)
+	}
+
 	// Validate that it's OK to change the spec. Note that this check assumes
 	// that the above "shortcut" is in place, i.e. we are only calling this
 	// if the spec is changing.
@@ -1431,12 +2016,22 @@ func admitClusterCR(
 		valErrors, patches = validateSpecChange(&clusterCR, &prevClusterCR, valErrors, patches)
 	}
 
+	// Validate config choices and generate patches for default selections.
+	valErrors, patches = validateClusterConfigChoices(&clusterCR, appCR, valErrors, patches)
+
 	// Validate cardinality and generate patches for defaults members values.
 	valErrors, patches = validateCardinality(&clusterCR, appCR, valErrors, patches)
 
 	// Validate that roles are known & sufficient.
 	valErrors = validateClusterRoles(&clusterCR, appCR, valErrors)
 
+	// Validate that any role image overrides are allowed by the app.
+	valErrors = validateRoleImages(&clusterCR, appCR, valErrors)
+
+	// Validate role security contexts, and whether the app can run in
+	// restricted mode if this is a new cluster or a change of app.
+	valErrors = validateRoleSecurity(&clusterCR, &prevClusterCR, appCR, ar.Request.Operation, valErrors)
+
 	// Validate minimum resources for all roles
 	valErrors = validateMinResources(&clusterCR, appCR, valErrors)
 
@@ -1449,6 +2044,9 @@ func admitClusterCR(
 	// Validate that any specified storage class exists, and handle defaulting.
 	valErrors, patches = validateRoleStorageClass(&clusterCR, valErrors, patches)
 
+	// Validate any named volumes, defaulting their storage class.
+	valErrors, patches = validateRoleVolumes(&clusterCR, appCR, valErrors, patches)
+
 	// Validate the syntax of any specified shared-memory value.
 	valErrors = validateRoleSharedMemory(&clusterCR, valErrors)
 
@@ -1473,7 +2071,7 @@ func admitClusterCR(
 	// If cluster already exists, check for invalid property changes.
 	if ar.Request.Operation == av1beta1.Update {
 		var changeErrors []string
-		changeErrors = validateGeneralClusterChanges(&clusterCR, &prevClusterCR, changeErrors)
+		changeErrors = validateGeneralClusterChanges(&clusterCR, &prevClusterCR, appCR, changeErrors)
 		changeErrors = validateRoleChanges(&clusterCR, &prevClusterCR, changeErrors)
 		// If un-change-able properties are being changed, ignore all other error
 		// messages in favor of those. (The reason we didn't just do this check
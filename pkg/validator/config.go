@@ -27,7 +27,9 @@ import (
 	"github.com/bluek8s/kubedirector/pkg/observer"
 	av1beta1 "k8s.io/api/admission/v1beta1"
 	"k8s.io/apimachinery/pkg/api/equality"
+	corevalidation "k8s.io/apimachinery/pkg/api/validation"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilvalidation "k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 )
 
@@ -103,6 +105,97 @@ func validateConfigStorageClass(
 	return valErrors
 }
 
+// validateDashboardIngress checks the dashboard ingress settings, if any.
+// The host pattern must use the {member} and {namespace} placeholders so
+// that each member gets its own host name, and must form valid DNS names.
+// The gateway properties can only be used in gateway mode, and the ingress
+// properties only in ingress mode. Ingress mode uses the networking.k8s.io/v1
+// Ingress API, which requires K8s 1.19 or later.
+func validateDashboardIngress(
+	dashboardIngress *kdv1.DashboardIngress,
+	valErrors []string,
+) []string {
+
+	if dashboardIngress == nil {
+		return valErrors
+	}
+
+	hostPattern := dashboardIngress.HostPattern
+	if !strings.Contains(hostPattern, "{member}") ||
+		!strings.Contains(hostPattern, "{namespace}") {
+		valErrors = append(
+			valErrors,
+			fmt.Sprintf(dashboardHostPatternPlaceholders, hostPattern),
+		)
+	} else {
+		// Check a host name formed with sample values that are valid DNS
+		// labels, as all of the real values will be.
+		sampleHost := strings.NewReplacer(
+			"{member}", "member",
+			"{role}", "role",
+			"{cluster}", "cluster",
+			"{namespace}", "namespace",
+			"{service}", "service",
+		).Replace(hostPattern)
+		hostErrors := utilvalidation.IsDNS1123Subdomain(sampleHost)
+		if len(hostErrors) != 0 {
+			valErrors = append(
+				valErrors,
+				fmt.Sprintf(
+					invalidDashboardHostPattern,
+					hostPattern,
+					strings.Join(hostErrors, "; "),
+				),
+			)
+		}
+	}
+
+	if dashboardIngress.Mode == kdv1.DashboardIngressModeGateway {
+		if dashboardIngress.GatewayName == "" {
+			valErrors = append(valErrors, dashboardGatewayNameMissing)
+		}
+		if dashboardIngress.IngressClassName != nil {
+			valErrors = append(
+				valErrors,
+				fmt.Sprintf(dashboardIngressPropertyMode, "ingressClassName", kdv1.DashboardIngressModeIngress),
+			)
+		}
+		if dashboardIngress.TLSSecretName != nil {
+			valErrors = append(
+				valErrors,
+				fmt.Sprintf(dashboardIngressPropertyMode, "tlsSecretName", kdv1.DashboardIngressModeIngress),
+			)
+		}
+	} else {
+		k8sVersionOk, _ := shared.K8sVersionIsAtLeast(1, 19)
+		if !k8sVersionOk {
+			valErrors = append(valErrors, dashboardIngressK8sVersion)
+		}
+		if (dashboardIngress.GatewayName != "") || (dashboardIngress.GatewayNamespace != "") {
+			valErrors = append(
+				valErrors,
+				fmt.Sprintf(dashboardIngressPropertyMode, "gatewayName/gatewayNamespace", kdv1.DashboardIngressModeGateway),
+			)
+		}
+		if dashboardIngress.GatewayTLS {
+			valErrors = append(
+				valErrors,
+				fmt.Sprintf(dashboardIngressPropertyMode, "gatewayTLS", kdv1.DashboardIngressModeGateway),
+			)
+		}
+	}
+
+	annotationErrors := corevalidation.ValidateAnnotations(
+		dashboardIngress.Annotations,
+		field.NewPath("spec", "dashboardIngress", "annotations"),
+	)
+	for _, annotationErr := range annotationErrors {
+		valErrors = append(valErrors, annotationErr.Error())
+	}
+
+	return valErrors
+}
+
 // validateOrPopulateMasterEncryptionKey checks key length to be supported by AES (16,24,32)
 // or generates default 32 bytes encryption key for AES-256. Also, if there's
 // an existing non-nil value, we currently don't allow changing the value while
@@ -302,6 +395,9 @@ func admitKDConfigCR(
 		valErrors,
 	)
 
+	// Check the dashboard ingress settings if present.
+	valErrors = validateDashboardIngress(configCR.Spec.DashboardIngress, valErrors)
+
 	// Populate backup-cluster-status and allow-restore-w/o-connections flags
 	// if necessary.
 	if configCR.Spec.BackupClusterStatus == nil {
@@ -321,6 +417,13 @@ func admitKDConfigCR(
 		)
 	}
 
+	// Populate restricted mode flag if necessary.
+	if configCR.Spec.RestrictedMode == nil {
+		patches = append(patches,
+			newBoolPatch("/spec/restrictedMode", defaultRestrictedMode),
+		)
+	}
+
 	if len(valErrors) == 0 {
 		if len(patches) != 0 {
 			patchResult, patchErr := json.Marshal(patches)
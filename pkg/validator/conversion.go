@@ -0,0 +1,244 @@
+// Copyright 2022 Hewlett Packard Enterprise Development LP
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validator
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/bluek8s/kubedirector/pkg/apis"
+	"github.com/bluek8s/kubedirector/pkg/shared"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+)
+
+// conversionScheme knows about all served versions of our CRs.
+var conversionScheme = runtime.NewScheme()
+
+func init() {
+	utilruntime.Must(apis.AddToScheme(conversionScheme))
+}
+
+// convertObject converts a single serialized CR to the desired API version.
+// All conversions go through the hub (storage) version of the CR kind.
+func convertObject(
+	raw []byte,
+	desiredGV schema.GroupVersion,
+) ([]byte, error) {
+
+	typeMeta := metav1.TypeMeta{}
+	if err := json.Unmarshal(raw, &typeMeta); err != nil {
+		return nil, err
+	}
+	srcGVK := typeMeta.GroupVersionKind()
+	if srcGVK.GroupVersion() == desiredGV {
+		return raw, nil
+	}
+	dstGVK := desiredGV.WithKind(srcGVK.Kind)
+
+	src, srcErr := conversionScheme.New(srcGVK)
+	if srcErr != nil {
+		return nil, srcErr
+	}
+	if err := json.Unmarshal(raw, src); err != nil {
+		return nil, err
+	}
+	dst, dstErr := conversionScheme.New(dstGVK)
+	if dstErr != nil {
+		return nil, dstErr
+	}
+
+	var convertErr error
+	srcHub, srcIsHub := src.(conversion.Hub)
+	dstHub, dstIsHub := dst.(conversion.Hub)
+	srcConvertible, srcIsConvertible := src.(conversion.Convertible)
+	dstConvertible, dstIsConvertible := dst.(conversion.Convertible)
+	switch {
+	case srcIsHub && dstIsConvertible:
+		convertErr = dstConvertible.ConvertFrom(srcHub)
+	case srcIsConvertible && dstIsHub:
+		convertErr = srcConvertible.ConvertTo(dstHub)
+	default:
+		convertErr = fmt.Errorf(
+			"no conversion from %s to %s",
+			srcGVK.String(),
+			dstGVK.String(),
+		)
+	}
+	if convertErr != nil {
+		return nil, convertErr
+	}
+	dst.GetObjectKind().SetGroupVersionKind(dstGVK)
+	return json.Marshal(dst)
+}
+
+// convertObjects handles a conversion request. If any object fails to
+// convert, the whole request fails.
+func convertObjects(
+	request *conversionRequest,
+) *conversionResponse {
+
+	response := &conversionResponse{
+		UID:              request.UID,
+		ConvertedObjects: []runtime.RawExtension{},
+		Result:           metav1.Status{Status: metav1.StatusSuccess},
+	}
+	desiredGV, gvErr := schema.ParseGroupVersion(request.DesiredAPIVersion)
+	if gvErr != nil {
+		response.Result = metav1.Status{
+			Status:  metav1.StatusFailure,
+			Message: gvErr.Error(),
+		}
+		return response
+	}
+	for _, obj := range request.Objects {
+		converted, err := convertObject(obj.Raw, desiredGV)
+		if err != nil {
+			validatorLog.Error(err, "conversion failed")
+			response.ConvertedObjects = []runtime.RawExtension{}
+			response.Result = metav1.Status{
+				Status:  metav1.StatusFailure,
+				Message: err.Error(),
+			}
+			return response
+		}
+		response.ConvertedObjects = append(
+			response.ConvertedObjects,
+			runtime.RawExtension{Raw: converted},
+		)
+	}
+	return response
+}
+
+// convert handles the http portion of a CR version conversion request.
+func convert(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+
+	var body []byte
+	if r.Body != nil {
+		if data, err := ioutil.ReadAll(r.Body); err == nil {
+			body = data
+		}
+	}
+
+	if len(body) == 0 {
+		http.Error(w, "empty body", http.StatusBadRequest)
+		return
+	}
+
+	review := conversionReview{}
+	if err := json.Unmarshal(body, &review); err != nil {
+		http.Error(
+			w,
+			fmt.Sprintf("could not decode request: %v", err),
+			http.StatusBadRequest,
+		)
+		return
+	}
+	if review.Request == nil {
+		http.Error(w, "missing conversion request", http.StatusBadRequest)
+		return
+	}
+
+	// Respond with the same ConversionReview version that was sent.
+	review.Response = convertObjects(review.Request)
+	review.Request = nil
+
+	respBytes, err := json.Marshal(review)
+	if err != nil {
+		http.Error(
+			w,
+			fmt.Sprintf("could not encode response: %v", err),
+			http.StatusInternalServerError,
+		)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(respBytes); err != nil {
+		http.Error(
+			w,
+			fmt.Sprintf("could not write response: %v", err),
+			http.StatusInternalServerError,
+		)
+	}
+}
+
+// configureCRDConversion points the conversion strategy of each of our CRDs
+// at our webhook service, using the current signing cert.
+func configureCRDConversion(
+	namespace string,
+	serviceName string,
+	signingCert []byte,
+) error {
+
+	webhookConversion := map[string]interface{}{
+		"strategy": "Webhook",
+		"webhook": map[string]interface{}{
+			"conversionReviewVersions": []interface{}{"v1", "v1beta1"},
+			"clientConfig": map[string]interface{}{
+				"service": map[string]interface{}{
+					"namespace": namespace,
+					"name":      serviceName,
+					"path":      conversionPath,
+				},
+				"caBundle": base64.StdEncoding.EncodeToString(signingCert),
+			},
+		},
+	}
+	for _, crdName := range conversionCRDs {
+		crd := &unstructured.Unstructured{}
+		crd.SetGroupVersionKind(
+			schema.GroupVersionKind{
+				Group:   "apiextensions.k8s.io",
+				Version: "v1",
+				Kind:    "CustomResourceDefinition",
+			},
+		)
+		getErr := shared.Get(
+			context.TODO(),
+			types.NamespacedName{Name: crdName},
+			crd,
+		)
+		if getErr != nil {
+			return fmt.Errorf("failed to get CRD{%s}: %v", crdName, getErr)
+		}
+		patchedCRD := crd.DeepCopy()
+		setErr := unstructured.SetNestedField(
+			patchedCRD.Object,
+			webhookConversion,
+			"spec",
+			"conversion",
+		)
+		if setErr != nil {
+			return setErr
+		}
+		patchErr := shared.Patch(context.TODO(), crd, patchedCRD)
+		if patchErr != nil {
+			return fmt.Errorf("failed to patch CRD{%s}: %v", crdName, patchErr)
+		}
+	}
+	return nil
+}
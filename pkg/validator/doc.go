@@ -19,4 +19,8 @@
 // field validation requests. Then, when resources in kubedirector.hpe.com
 // (KubeDirectorCluster and KubeDirectorApp) are created/changed/deleted,
 // the validation function will be invoked to check the proposed operation.
+//
+// The same webserver also handles conversion of those resources between the
+// served API versions (v1beta1 and v1). The CRDs are updated to point at it
+// by InitValidationServer.
 package validator
@@ -191,6 +191,13 @@ func StartValidationServer() error {
 		},
 	)
 
+	http.HandleFunc(
+		conversionPath,
+		func(w http.ResponseWriter, r *http.Request) {
+			convert(w, r)
+		},
+	)
+
 	http.HandleFunc(
 		healthPath,
 		func(w http.ResponseWriter, r *http.Request) {
@@ -205,8 +212,9 @@ func StartValidationServer() error {
 }
 
 // InitValidationServer creates secret, service and admission validation k8s
-// resources. All these resources are created in the same namespace where
-// KubeDirector is running.
+// resources, and points the conversion webhook of our CRDs at the service.
+// All these resources are created in the same namespace where KubeDirector
+// is running.
 // XXX We could/should move to using the tls module now provided by the SDK.
 // However, its interface requires storing the various certs/keys in two
 // secrets and a configmap, while our current method uses one secret. Since
@@ -286,5 +294,17 @@ func InitValidationServer(
 		)
 	}
 
+	conversionErr := configureCRDConversion(
+		kdNamespace,
+		validatorServiceName,
+		signingCertBytes,
+	)
+	if conversionErr != nil {
+		return fmt.Errorf(
+			"failed to configure CRD conversion: %v",
+			conversionErr,
+		)
+	}
+
 	return nil
 }
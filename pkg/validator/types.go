@@ -17,6 +17,9 @@ package validator
 import (
 	"github.com/bluek8s/kubedirector/pkg/shared"
 	av1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 // admitFunc is used as the type for all the callback validators
@@ -31,10 +34,16 @@ const (
 	webhookHandlerName                    = "validate-cr.kubedirector.hpe.com"
 	validationPort                        = 8443
 	validationPath                        = "/validate"
+	conversionPath                        = "/convert"
 	healthPath                            = "/healthz"
 	defaultNativeSystemd                  = false
 	defaultBackupClusterStatus            = false
 	defaultAllowRestoreWithoutConnections = false
+	defaultRestrictedMode                 = false
+
+	// restrictedModeAllowedCapability is the only capability that apps can
+	// add while KubeDirector is in restricted mode.
+	restrictedModeAllowedCapability = "NET_BIND_SERVICE"
 
 	appCrt  = "app.crt"
 	appKey  = "app.pem"
@@ -51,8 +60,22 @@ const (
 	invalidRole        = "Invalid role(%s) in app(%s) specified. Valid roles: \"%s\""
 	unconfiguredRole   = "Active role(%s) in app(%s) must have its configuration included in the roles array."
 
-	modifiedProperty = "The %s property is read-only."
-	modifiedRole     = "Role(%s) properties other than the members count cannot be modified while role members exist."
+	modifiedProperty     = "The %s property is read-only."
+	modifiedRole         = "Role(%s) properties other than the members count cannot be modified while role members exist."
+	modifiedRoleProperty = "The %s property of role(%s) cannot be modified while role members exist."
+	modifiedPodLabel     = "Existing label(%s) in the podLabels property of role(%s) cannot be changed or removed while role members exist."
+	storageShrink        = "The storage size of role(%s) cannot be decreased."
+	storageNotExpandable = "The storage size of role(%s) cannot be increased because storageClassName(%s) does not allow volume expansion."
+
+	invalidAppUpgrade       = "App(%s) does not list app(%s) in its upgradableFrom property."
+	appUpgradeInProgress    = "Upgrade from app(%s) to app(%s) is in progress. The app property can only be changed back to app(%s) until the upgrade completes."
+	incompatiblePersistDirs = "Role(%s) persists a different set of directories in app(%s) than in app(%s)."
+	selfUpgradableFrom      = "The upgradableFrom property cannot include this app's own ID(%s)."
+	nonUniqueUpgradableFrom = "Each element of the upgradableFrom array must be unique."
+
+	invalidCloneSource  = "Cannot clone from kdcluster(%s): %s"
+	cloneAppMismatch    = "Cannot clone from kdcluster(%s), which uses app(%s) rather than app(%s)."
+	cloneSecretKeyError = "Cannot copy secret key(%s) of role(%s) from kdcluster(%s)."
 
 	invalidNodeRoleID     = "Invalid roleID(%s) in roleServices array in config section. Valid roles: \"%s\""
 	invalidSelectedRoleID = "Invalid element(%s) in selectedRoles array in config section. Valid roles: \"%s\""
@@ -63,6 +86,49 @@ const (
 	nonUniqueSelectedRole = "Each element of selectedRoles array in config section must be unique."
 	nonUniqueServiceRole  = "Each roleID in roleServices array in config section must be unique."
 
+	nonUniqueChoiceID          = "Each id in the configChoices array in config section must be unique."
+	nonUniqueSelectionID       = "Each id in the selections array of config choice(%s) must be unique."
+	invalidChoiceDefault       = "Default(%s) of config choice(%s) is not one of its selections. Valid selections: \"%s\""
+	invalidSelectionRoleID     = "Invalid element(%s) in selectedRoles array of selection(%s) in config choice(%s). Valid roles: \"%s\""
+	invalidSelectionNodeRoleID = "Invalid roleID(%s) in roleServices array of selection(%s) in config choice(%s). Roles selected by this selection: \"%s\""
+	invalidSelectionServiceID  = "Invalid service_id(%s) in roleServices array of selection(%s) in config choice(%s). Valid services: \"%s\""
+	nonUniqueSelectionRole     = "Each element of selectedRoles array of selection(%s) in config choice(%s) must be unique."
+	nonUniqueSelectionService  = "Each roleID in roleServices array of selection(%s) in config choice(%s) must be unique."
+	choiceRoleConflict         = "Role(%s) is selected by config choice(%s) and cannot also be selected by the top-level config or by another config choice."
+	choiceRoleServiceConflict  = "Role(%s) in roleServices array in config section is selected by config choice(%s); its services must be declared in the selections of that choice."
+	invalidClusterChoice       = "Invalid config choice(%s) in configChoices property. Valid choices: \"%s\""
+	invalidClusterSelection    = "Invalid selection(%s) for config choice(%s). Valid selections: \"%s\""
+
+	invalidDependsOnRoleID = "Invalid element(%s) in dependsOn array of role(%s). Valid roles: \"%s\""
+	selfDependsOn          = "The dependsOn array of role(%s) cannot include the role itself."
+	nonUniqueDependsOn     = "Each element of the dependsOn array of role(%s) must be unique."
+	dependsOnCycle         = "The dependsOn arrays of roles form a cycle: %s."
+
+	invalidAllowedImage = "Invalid pattern(%s) in allowedImages array of role(%s)."
+	imageNotAllowed     = "Image(%s) for role(%s) is not allowed by app(%s). Allowed images: \"%s\""
+
+	nonUniqueSidecarName  = "Each name in the sidecars array of role(%s) must be unique."
+	reservedSidecarName   = "Sidecar name(%s) in role(%s) is reserved for KubeDirector containers."
+	unpersistedSidecarDir = "Directory(%s) in the persistDirs of sidecar(%s) in role(%s) is not one of the role's persistDirs, or inside one of them."
+
+	nonUniquePersistVolumeName   = "Each name in the persistVolumes array of role(%s) must be unique."
+	reservedPersistVolumeDir     = "Dir(%s) in persistVolume(%s) of role(%s) cannot hold /etc, which must stay on the main volume."
+	overlappingPersistVolumeDirs = "Dir(%s) in persistVolume(%s) of role(%s) overlaps dir(%s) in persistVolume(%s)."
+
+	probeWithoutPort    = "The %s of service(%s) requires the service endpoint to have a port."
+	probeWithoutCommand = "The %s of service(%s) is an exec probe and requires a command."
+	multipleRoleProbes  = "Role(%s) has more than one service that declares a %s: \"%s\""
+
+	invalidDisruptionBudget      = "The disruptionBudget of role(%s) must set exactly one of minAvailable, maxUnavailable, or quorum."
+	invalidDisruptionBudgetValue = "Invalid %s(%s) in disruptionBudget of role(%s). It must be a non-negative integer or a percentage."
+
+	seccompWithoutProfile      = "The Localhost seccompProfile of role(%s) requires a localhostProfile."
+	restrictedModeRoot         = "Role(%s) cannot run as root while KubeDirector is in restricted mode."
+	restrictedModeUnconfined   = "Role(%s) cannot use an Unconfined seccompProfile while KubeDirector is in restricted mode."
+	restrictedModeSystemd      = "App(%s) requires systemd, which is not supported while KubeDirector is in restricted mode."
+	restrictedModeCapabilities = "App(%s) requires capability(%s), which cannot be added while KubeDirector is in restricted mode."
+	restrictedModeSetupPackage = "Role(%s) has a setup package, which is not supported while KubeDirector is in restricted mode."
+
 	invalidDefaultSecretPrefix = "defaultSecret(%s) does not have the required name prefix(%s)."
 	invalidDefaultSecret       = "Unable to find defaultSecret(%s) in namespace(%s)."
 	invalidSecretPrefix        = "Secret(%s) for role(%s) does not have the required name prefix(%s)."
@@ -73,6 +139,12 @@ const (
 
 	noURLScheme = "The endpoint for service(%s) must include a urlScheme value because isDashboard is true."
 
+	dashboardOnlyNotDashboard = "The endpoint for service(%s) can only have dashboardOnly exposure if isDashboard is true."
+
+	roleServiceWithoutPort = "The endpoint for service(%s) must have a port because hasRoleService is true."
+
+	metricsWithoutPort = "The endpoint for service(%s) must have a port because it declares metrics."
+
 	failedToPatch = "Internal error: failed to populate default values for unspecified properties."
 
 	failedToPatchPVC = "Internal error: failed to apply ownerReference to PVC for kdcluster."
@@ -87,14 +159,21 @@ const (
 	noDefaultStorageClass   = "storageClassName is not specified for one or more roles, and no default storage class is available."
 	badDefaultStorageClass  = "storageClassName is not specified for one or more roles, and default storage class (%s) is not available on the system."
 
+	invalidStorageVolume       = "Volume(%s) in the storage of role(%s) is not one of the persistVolumes of the role in app(%s)."
+	nonUniqueStorageVolumeName = "Each name in the storage volumes array of role(%s) must be unique."
+	invalidStorageVolumeDef    = "Storage size for volume(%s) of role (%s) is incorrectly defined."
+	invalidStorageVolumeSize   = "Storage size for volume(%s) of role (%s) should be greater than zero."
+	invalidVolumeStorageClass  = "Unable to fetch storageClassName(%s) for volume(%s) of role(%s)."
+
 	invalidShmemDef        = "Shared memory size for role (%s) is incorrectly defined."
 	invalidShmemSize       = "Shared memory size for role (%s) should be greater than zero."
 	invalidShmemK8sVersion = "Specifying shared memory size for role (%s) requires K8s version >= 1.22."
 	invalidShmemFeature    = "Specifying shared memory size for role (%s) not allowed; feature disabled by global KubeDirector config."
 
-	invalidResource = "Specified resource(\"%s\") value(\"%s\") for role(\"%s\") is invalid. Minimum value must be \"%s\"."
-	invalidStorage  = "Specified persistent storage size(\"%s\") for role(\"%s\") is invalid. Minimum size must be \"%s\"."
-	invalidSrcURL   = "Unable to access the specified URL(\"%s\") in file injection spec for the role (%s). error: %s."
+	invalidResource        = "Specified resource(\"%s\") value(\"%s\") for role(\"%s\") is invalid. Minimum value must be \"%s\"."
+	invalidSidecarResource = "Requested resource(\"%s\") value(\"%s\") of sidecar(\"%s\") in role(\"%s\") is invalid. It must not exceed the sidecar's limit \"%s\"."
+	invalidStorage         = "Specified persistent storage size(\"%s\") for role(\"%s\") is invalid. Minimum size must be \"%s\"."
+	invalidSrcURL          = "Unable to access the specified URL(\"%s\") in file injection spec for the role (%s). error: %s."
 
 	maxMemberLimit = "Maximum number of total members per KD cluster supported is %d."
 
@@ -105,6 +184,12 @@ const (
 
 	invalidConfigDelete = "kd-global-config cannot be deleted while kdclusters exist"
 
+	dashboardHostPatternPlaceholders = "dashboardIngress hostPattern(%s) must use the {member} and {namespace} placeholders."
+	invalidDashboardHostPattern      = "dashboardIngress hostPattern(%s) does not form valid host names: %s"
+	dashboardGatewayNameMissing      = "dashboardIngress must specify gatewayName in gateway mode."
+	dashboardIngressPropertyMode     = "dashboardIngress property %s can only be used in %s mode."
+	dashboardIngressK8sVersion       = "dashboardIngress ingress mode requires K8s version >= 1.19."
+
 	invalidPVC        = "Unable to find persistentvolumeclaim(%s) in namespace(%s) as specified for role(%s)."
 	invalidVolumeMode = "Specified persistentvolumeclaim(%s) for role (%s) is invalid. VolumeMode(%s) for the underlying volume must be configured as Filesystem."
 	invalidAccessMode = "Specified persistentvolumeclaim(%s) is invalid. AccessModes for this volume must contain either ReadWriteMany or ReadOnlyMany, since its consumed by more than 1 member of the cluster."
@@ -114,4 +199,40 @@ const (
 	failedVolumeMountCheck = "Unexpected error while validating for unique volume mount paths for role(%s)."
 )
 
+// conversionCRDs lists the CRDs whose version conversion is handled by our
+// webhook.
+var conversionCRDs = []string{
+	"kubedirectorapps.kubedirector.hpe.com",
+	"kubedirectorclusters.kubedirector.hpe.com",
+	"kubedirectorconfigs.kubedirector.hpe.com",
+	"kubedirectorstatusbackups.kubedirector.hpe.com",
+	"kubedirectorrolescalers.kubedirector.hpe.com",
+	"kubedirectorbackups.kubedirector.hpe.com",
+}
+
+// conversionReview mirrors the apiextensions.k8s.io/v1 ConversionReview
+// type. The apiextensions API package is not among our dependencies, and we
+// only need these few fields.
+type conversionReview struct {
+	metav1.TypeMeta `json:",inline"`
+	Request         *conversionRequest  `json:"request,omitempty"`
+	Response        *conversionResponse `json:"response,omitempty"`
+}
+
+// conversionRequest describes the objects to be converted and the version
+// to convert them to.
+type conversionRequest struct {
+	UID               types.UID              `json:"uid"`
+	DesiredAPIVersion string                 `json:"desiredAPIVersion"`
+	Objects           []runtime.RawExtension `json:"objects"`
+}
+
+// conversionResponse holds the converted objects, in the same order as the
+// request, and the overall result.
+type conversionResponse struct {
+	UID              types.UID              `json:"uid"`
+	ConvertedObjects []runtime.RawExtension `json:"convertedObjects"`
+	Result           metav1.Status          `json:"result"`
+}
+
 type dictValue map[string]string
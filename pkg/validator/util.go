@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"strings"
 
+	kdv1 "github.com/bluek8s/kubedirector/pkg/apis/kubedirector/v1beta1"
 	"github.com/bluek8s/kubedirector/pkg/cert"
 	"github.com/bluek8s/kubedirector/pkg/observer"
 	"github.com/bluek8s/kubedirector/pkg/shared"
@@ -116,6 +117,10 @@ func createAdmissionService(
 	softFailurePolicy := arv1.Ignore
 	sideEffectsNone := arv1.SideEffectClassNone
 
+	// Requests made through any other served version of our CRs (e.g. v1)
+	// will be converted to v1beta1 before being sent to our handler.
+	equivalentMatchPolicy := arv1.Equivalent
+
 	// Webhook handler with a "fail" failure policy; these operations
 	// will NOT be allowed even when the handler is down.
 	hardWebhookHandler := arv1.MutatingWebhook{
@@ -154,6 +159,7 @@ func createAdmissionService(
 			},
 		},
 		FailurePolicy:           &hardFailurePolicy,
+		MatchPolicy:             &equivalentMatchPolicy,
 		SideEffects:             &sideEffectsNone,
 		AdmissionReviewVersions: []string{"v1beta1"},
 	}
@@ -316,6 +322,95 @@ func validateLabelsAndAnnotations(
 	return valErrors, anyError
 }
 
+// validateSecurityContext is a common subroutine for validating the security
+// context of an app role or a cluster role. A Localhost seccomp profile must
+// name the profile file to use. In restricted mode, the role must also not
+// ask to run as root or without seccomp filtering.
+func validateSecurityContext(
+	securityContext *kdv1.SecurityContext,
+	roleID string,
+	valErrors []string,
+) []string {
+
+	if securityContext == nil {
+		return valErrors
+	}
+	seccompProfile := securityContext.SeccompProfile
+	if (seccompProfile != nil) &&
+		(seccompProfile.Type == kdv1.SeccompProfileLocalhost) &&
+		(seccompProfile.LocalhostProfile == nil) {
+		valErrors = append(
+			valErrors,
+			fmt.Sprintf(seccompWithoutProfile, roleID),
+		)
+	}
+	if !shared.GetRestrictedMode() {
+		return valErrors
+	}
+	if ((securityContext.RunAsUser != nil) && (*securityContext.RunAsUser == 0)) ||
+		((securityContext.RunAsNonRoot != nil) && !*securityContext.RunAsNonRoot) {
+		valErrors = append(
+			valErrors,
+			fmt.Sprintf(restrictedModeRoot, roleID),
+		)
+	}
+	if (seccompProfile != nil) &&
+		(seccompProfile.Type == kdv1.SeccompProfileUnconfined) {
+		valErrors = append(
+			valErrors,
+			fmt.Sprintf(restrictedModeUnconfined, roleID),
+		)
+	}
+	return valErrors
+}
+
+// validateRestrictedApp checks that the app can run while KubeDirector is
+// in restricted mode: it must not require systemd, or any added capabilities
+// other than NET_BIND_SERVICE (which the K8s restricted profile allows), and
+// its roles must have acceptable security contexts. Its roles also must not
+// have a setup package, since app setup writes configmeta and the setup
+// files into root-owned dirs of the app container. This is a no-op if
+// restricted mode is off.
+func validateRestrictedApp(
+	appCR *kdv1.KubeDirectorApp,
+	valErrors []string,
+) []string {
+
+	if !shared.GetRestrictedMode() {
+		return valErrors
+	}
+	if appCR.Spec.SystemdRequired {
+		valErrors = append(
+			valErrors,
+			fmt.Sprintf(restrictedModeSystemd, appCR.Name),
+		)
+	}
+	for _, capability := range appCR.Spec.Capabilities {
+		if capability != restrictedModeAllowedCapability {
+			valErrors = append(
+				valErrors,
+				fmt.Sprintf(restrictedModeCapabilities, appCR.Name, capability),
+			)
+		}
+	}
+	for _, nodeRole := range appCR.Spec.NodeRoles {
+		valErrors = validateSecurityContext(nodeRole.SecurityContext, nodeRole.ID, valErrors)
+		// A role that doesn't specify a setup package uses the app default,
+		// if this is a new app CR that hasn't had its defaults populated.
+		setupPackage := nodeRole.SetupPackage
+		if !setupPackage.IsSet {
+			setupPackage = appCR.Spec.DefaultSetupPackage
+		}
+		if setupPackage.IsSet && !setupPackage.IsNull {
+			valErrors = append(
+				valErrors,
+				fmt.Sprintf(restrictedModeSetupPackage, nodeRole.ID),
+			)
+		}
+	}
+	return valErrors
+}
+
 // createSubjectAccessReview is a utility function to validate if a user is allowed to access
 // a resource in a namespace. It constructs SubjectAccessReviewSpec using the information
 // provided by the caller and makes the SAR request to API Server. It returns an error string